@@ -0,0 +1,22 @@
+// Command fakebooking serves the recorded Booking API fixtures over HTTP.
+// Point rapidapi_base_url in conf/app.conf at it to run the ingest pipeline offline.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"backend_rental/fakeapi"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	fixtures := flag.String("fixtures", "fetched", "directory holding the recorded fixtures")
+	flag.Parse()
+
+	log.Printf("Serving Booking API fixtures from %s on %s", *fixtures, *addr)
+	if err := http.ListenAndServe(*addr, fakeapi.NewHandler(*fixtures)); err != nil {
+		log.Fatalf("fake Booking API stopped: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Command migrate applies, reverts and lists the schema migrations of the database
+// configured in conf/app.conf.
+//
+//	go run ./cmd/migrate up            apply every pending migration
+//	go run ./cmd/migrate down [-steps N]  revert the last N applied migrations (default 1)
+//	go run ./cmd/migrate status        list migrations and when they were applied
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"backend_rental/migrations"
+	"backend_rental/utils"
+	"github.com/beego/beego/v2/client/orm"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: migrate up | down [-steps N] | status\n")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	if err := utils.ConnectDB(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		log.Fatalf("Failed to get database connection: %v", err)
+	}
+
+	switch command {
+	case "up":
+		n, err := migrations.Up(db)
+		if err != nil {
+			log.Fatalf("Migration failed after %d applied: %v", n, err)
+		}
+		fmt.Printf("Applied %d migrations\n", n)
+
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		fs.Parse(args)
+		if *steps < 1 {
+			log.Fatalf("-steps must be at least 1")
+		}
+		n, err := migrations.Down(db, *steps)
+		if err != nil {
+			log.Fatalf("Revert failed after %d reverted: %v", n, err)
+		}
+		fmt.Printf("Reverted %d migrations\n", n)
+
+	case "status":
+		statuses, err := migrations.List(db)
+		if err != nil {
+			log.Fatalf("Failed to read migrations: %v", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", status.Version, status.Name, applied)
+		}
+
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,207 @@
+// Command rentalctl runs the ingest stages that fill data/ and the database, one at a time
+// or chained as a pipeline, using the provider configured in conf/app.conf.
+//
+//	go run ./cmd/rentalctl stages                          list the stages and their files
+//	go run ./cmd/rentalctl usage                           show provider calls and quota left
+//	go run ./cmd/rentalctl rates [file]                    load exchange rates (default currency::rates_file)
+//	go run ./cmd/rentalctl <stage> [flags]                 run one stage
+//	go run ./cmd/rentalctl pipeline run [-from S] [-to S] [flags]
+//
+// Selection flags: -city (ID or name), -ids, -top-reviewed, -missing, -limit N, -budget N.
+// Other flags: -dry-run, -restart (cities), -seed-mode (load), -refresh and -no-cache (response cache).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"backend_rental/migrations"
+	"backend_rental/services"
+	"backend_rental/utils"
+	"github.com/beego/beego/v2/client/orm"
+)
+
+func usage() {
+	var names []string
+	for _, stage := range services.PipelineStages() {
+		names = append(names, stage.Name)
+	}
+	fmt.Fprintf(os.Stderr, "usage: rentalctl stages | usage | rates [file] | %s [flags] | pipeline run [-from stage] [-to stage] [flags]\n",
+		strings.Join(names, " | "))
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	var stages []services.PipelineStage
+	var from, to *string
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	switch command {
+	case "stages":
+		for _, stage := range services.PipelineStages() {
+			fmt.Printf("%-13s %s\n", stage.Name, stage.Description)
+			if len(stage.Inputs) > 0 {
+				fmt.Printf("%-13s   reads  %s\n", "", strings.Join(stage.Inputs, ", "))
+			}
+			if len(stage.Outputs) > 0 {
+				fmt.Printf("%-13s   writes %s\n", "", strings.Join(stage.Outputs, ", "))
+			}
+		}
+		return
+
+	case "usage":
+		printUsage()
+		return
+
+	case "rates":
+		if len(args) > 1 {
+			usage()
+		}
+		path := utils.CurrencyRatesPath()
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if err := connectDB(); err != nil {
+			log.Fatal(err)
+		}
+		stats, err := utils.SeedCurrencyRates(utils.SeedModeUpsert, path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Seeded %s\n", stats)
+		return
+
+	case "pipeline":
+		if len(args) == 0 || args[0] != "run" {
+			usage()
+		}
+		args = args[1:]
+		fs = flag.NewFlagSet("pipeline run", flag.ExitOnError)
+		from = fs.String("from", "", "first stage to run (default: the first stage)")
+		to = fs.String("to", "", "last stage to run (default: the last stage)")
+
+	default:
+		stage, err := services.LookupStage(command)
+		if err != nil {
+			usage()
+		}
+		stages = []services.PipelineStage{*stage}
+	}
+
+	pipeline := &services.Pipeline{}
+	fs.StringVar(&pipeline.City, "city", "", "restrict the stages to one city, by ID or name")
+	ids := fs.String("ids", "", "comma-separated hotel IDs for the per-property stages")
+	fs.BoolVar(&pipeline.TopReviewed, "top-reviewed", false, "pick the most reviewed properties first")
+	fs.BoolVar(&pipeline.Missing, "missing", false, "skip cities and properties a stage already has data for")
+	fs.IntVar(&pipeline.Limit, "limit", 0, "maximum cities or properties per stage (default no limit)")
+	fs.IntVar(&pipeline.Budget, "budget", services.ConfiguredCallBudget(), "maximum provider calls for the run, 0 for unlimited (default ingest::call_budget)")
+	fs.BoolVar(&pipeline.DryRun, "dry-run", false, "print what each stage would do without calling the provider or writing")
+	fs.BoolVar(&pipeline.Restart, "restart", false, "restart the city crawl from A instead of resuming")
+	fs.BoolVar(&pipeline.Refresh, "refresh", false, "ignore cached provider responses and cache the new ones")
+	fs.BoolVar(&pipeline.NoCache, "no-cache", false, "neither read nor write the provider response cache")
+	seedMode := fs.String("seed-mode", "", "seed mode for the load stage (default db::seed_mode)")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		usage()
+	}
+	var err error
+	if pipeline.PropertyIDs, err = services.ParsePropertyIDs(*ids); err != nil {
+		log.Fatal(err)
+	}
+	if command == "pipeline" {
+		if stages, err = services.StageRange(*from, *to); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	pipeline.SeedMode = *seedMode
+	if pipeline.SeedMode == "" {
+		mode, err := utils.SeedModeFromConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		pipeline.SeedMode = mode
+	}
+
+	// Dry runs connect too: the cities stage plans from the crawl state stored in the database
+	if needsDB(stages) {
+		if err := connectDB(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err = pipeline.Run(ctx, stages)
+	// The usage of the last responses is still waiting to be written
+	utils.FlushProviderUsage()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func needsDB(stages []services.PipelineStage) bool {
+	for _, stage := range stages {
+		if stage.NeedsDB {
+			return true
+		}
+	}
+	return false
+}
+
+// connectDB connects to the configured database and refuses to run against an unmigrated schema
+func connectDB() error {
+	if err := utils.ConnectDB(); err != nil {
+		return fmt.Errorf("failed to connect to database: %v", err)
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %v", err)
+	}
+	pending, err := migrations.Pending(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migrations are pending; run `go run ./cmd/migrate up` first", len(pending))
+	}
+	return nil
+}
+
+// printUsage shows the calls each provider made today and this month and the quota the
+// provider reported last, from utils.ProviderUsagePath
+func printUsage() {
+	all, err := utils.LoadProviderUsage(utils.ProviderUsagePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	now := time.Now()
+	for _, provider := range utils.ProviderNames() {
+		usage, ok := all[provider]
+		if !ok {
+			continue
+		}
+		fmt.Printf("%-9s today %d, this month %d", provider, usage.Today(now), usage.Month(now))
+		if usage.Remaining != nil {
+			fmt.Printf(", %d requests left", *usage.Remaining)
+			if usage.Limit > 0 {
+				fmt.Printf(" of %d", usage.Limit)
+			}
+			if !usage.ResetAt.IsZero() {
+				fmt.Printf(", resets %s", usage.ResetAt.Local().Format(time.RFC1123))
+			}
+		}
+		fmt.Println()
+	}
+}
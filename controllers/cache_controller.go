@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+
+	"backend_rental/utils"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+type CacheController struct {
+	beego.Controller
+}
+
+// Get reports the size of the provider response cache and its hits since the server started
+func (c *CacheController) Get() {
+	cache := utils.SharedResponseCache()
+	if cache == nil {
+		c.Data["json"] = map[string]interface{}{"enabled": false}
+		c.ServeJSON()
+		return
+	}
+
+	size, err := cache.Size()
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]interface{}{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	totals := cache.Totals()
+	c.Data["json"] = map[string]interface{}{
+		"enabled":    true,
+		"dir":        cache.Dir,
+		"size_bytes": size,
+		"max_bytes":  cache.MaxBytes,
+		"hit_rate":   totals.HitRate(),
+		"totals":     totals,
+		"endpoints":  cache.Stats(),
+	}
+	c.ServeJSON()
+}
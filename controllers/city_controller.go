@@ -1,7 +1,9 @@
 package controllers
 
 import (
+    "context"
     "net/http"
+    "backend_rental/models"
     "backend_rental/services"
     beego "github.com/beego/beego/v2/server/web"
 )
@@ -15,13 +17,45 @@ func (c *CityController) Prepare() {
     c.cityService = services.NewCityService()
 }
 
+// Get starts the same background job as Post and answers 202 with its id
 func (c *CityController) Get() {
-    data, err := c.cityService.FetchCitiesAlphabetically()
+    c.Post()
+}
+
+// Post starts the city crawl as a background job
+func (c *CityController) Post() {
+    restart, _ := c.GetBool("restart", false)
+    service := c.cityService
+    startJob(&c.Controller, services.JobKindCities, func(ctx context.Context, job *services.Job) error {
+        service.Progress = job
+        _, err := service.FetchCitiesAlphabetically(ctx, restart)
+        return err
+    })
+}
+
+// Progress reports the persisted cursor of the city crawl
+func (c *CityController) Progress() {
+    state, err := c.cityService.LoadCrawlState()
     if err != nil {
         c.Ctx.Output.SetStatus(http.StatusInternalServerError)
         c.Data["json"] = map[string]interface{}{"error": err.Error()}
-    } else {
-        c.Data["json"] = data
+        c.ServeJSON()
+        return
+    }
+
+    total := 0
+    for _, count := range state.QueryCounts {
+        total += count
+    }
+    nextQuery := ""
+    if state.Status != models.CrawlStatusCompleted {
+        nextQuery = services.NextCrawlQuery(state.LastQuery)
+    }
+
+    c.Data["json"] = map[string]interface{}{
+        "crawl":       state,
+        "citiesFound": total,
+        "nextQuery":   nextQuery,
     }
     c.ServeJSON()
 }
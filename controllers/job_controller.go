@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"net/http"
+
+	"backend_rental/services"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+type JobController struct {
+	beego.Controller
+}
+
+// List returns every known job, most recent first
+func (c *JobController) List() {
+	jobs := services.Jobs.List()
+	infos := make([]services.JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		infos = append(infos, job.Snapshot())
+	}
+	c.Data["json"] = infos
+	c.ServeJSON()
+}
+
+// Get reports status, progress, errors and result counts of one job
+func (c *JobController) Get() {
+	job, err := services.Jobs.Get(c.Ctx.Input.Param(":id"))
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusNotFound)
+		c.Data["json"] = map[string]interface{}{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	c.Data["json"] = job.Snapshot()
+	c.ServeJSON()
+}
+
+// Cancel asks a running job to stop
+func (c *JobController) Cancel() {
+	job, err := services.Jobs.Cancel(c.Ctx.Input.Param(":id"))
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusNotFound)
+		c.Data["json"] = map[string]interface{}{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	c.Ctx.Output.SetStatus(http.StatusAccepted)
+	c.Data["json"] = job.Snapshot()
+	c.ServeJSON()
+}
+
+// startJob starts fn in the background and renders the outcome with renderJobStart
+func startJob(c *beego.Controller, kind string, fn services.JobFunc) {
+	job, err := services.Jobs.Start(kind, fn)
+	renderJobStart(c, job, err)
+}
+
+// renderJobStart answers 202 with a started job, or 409 with the job of the same kind
+// that is still running
+func renderJobStart(c *beego.Controller, job *services.Job, err error) {
+	switch {
+	case err == services.ErrJobAlreadyRunning:
+		c.Ctx.Output.SetStatus(http.StatusConflict)
+		c.Data["json"] = map[string]interface{}{"error": err.Error(), "job": job.Snapshot()}
+	case err != nil:
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]interface{}{"error": err.Error()}
+	default:
+		c.Ctx.Output.Header("Location", "/v1/jobs/"+job.Snapshot().ID)
+		c.Ctx.Output.SetStatus(http.StatusAccepted)
+		c.Data["json"] = job.Snapshot()
+	}
+	c.ServeJSON()
+}
+
+// parseSelection reads the batch selection parameters of an ingest request, answering 400
+// and returning false when they are invalid
+func parseSelection(c *beego.Controller) (services.Selection, bool) {
+	values, err := c.Input()
+	if err == nil {
+		var selection services.Selection
+		if selection, err = services.ParseSelection(values); err == nil {
+			return selection, true
+		}
+	}
+	c.Ctx.Output.SetStatus(http.StatusBadRequest)
+	c.Data["json"] = map[string]interface{}{"error": err.Error()}
+	c.ServeJSON()
+	return services.Selection{}, false
+}
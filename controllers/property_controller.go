@@ -1,7 +1,7 @@
 package controllers
 
 import (
-    "net/http"
+    "context"
     "backend_rental/services"
     beego "github.com/beego/beego/v2/server/web"
 )
@@ -15,13 +15,22 @@ func (c *PropertyController) Prepare() {
     c.propertyService = services.NewPropertyService()
 }
 
+// Get starts the same background job as Post and answers 202 with its id
 func (c *PropertyController) Get() {
-    properties, err := c.propertyService.FetchPropertiesForCities()
-    if err != nil {
-        c.Ctx.Output.SetStatus(http.StatusInternalServerError)
-        c.Data["json"] = map[string]interface{}{"error": err.Error()}
-    } else {
-        c.Data["json"] = properties
+    c.Post()
+}
+
+// Post starts the property search for the selected cities as a background job
+func (c *PropertyController) Post() {
+    selection, ok := parseSelection(&c.Controller)
+    if !ok {
+        return
     }
-    c.ServeJSON()
-}
\ No newline at end of file
+    service := c.propertyService
+    service.Selection = selection
+    startJob(&c.Controller, services.JobKindProperties, func(ctx context.Context, job *services.Job) error {
+        service.Progress = job
+        _, err := service.FetchPropertiesForCities(ctx)
+        return err
+    })
+}
@@ -1,7 +1,7 @@
 package controllers
 
 import (
-    "net/http"
+    "context"
     "backend_rental/services"
     beego "github.com/beego/beego/v2/server/web"
 )
@@ -15,13 +15,21 @@ func (c *PropertyDescriptionController) Prepare() {
     c.propertyDescService = services.NewPropertyDescService()
 }
 
+// Get starts the same background job as Post and answers 202 with its id
 func (c *PropertyDescriptionController) Get() {
-    err := c.propertyDescService.FetchAndSavePropertyDescriptions()
-    if err != nil {
-        c.Ctx.Output.SetStatus(http.StatusInternalServerError)
-        c.Data["json"] = map[string]interface{}{"error": err.Error()}
-    } else {
-        c.Data["json"] = map[string]interface{}{"message": "Property descriptions fetched successfully"}
+    c.Post()
+}
+
+// Post starts the description fetch as a background job
+func (c *PropertyDescriptionController) Post() {
+    selection, ok := parseSelection(&c.Controller)
+    if !ok {
+        return
     }
-    c.ServeJSON()
-}
\ No newline at end of file
+    service := c.propertyDescService
+    service.Selection = selection
+    startJob(&c.Controller, services.JobKindPropertyDescriptions, func(ctx context.Context, job *services.Job) error {
+        service.Progress = job
+        return service.FetchAndSavePropertyDescriptions(ctx)
+    })
+}
@@ -1,7 +1,7 @@
 package controllers
 
 import (
-    "net/http"
+    "context"
     "backend_rental/services"
     beego "github.com/beego/beego/v2/server/web"
 )
@@ -15,15 +15,24 @@ func (c *PropertyDetailController) Prepare() {
     c.propertyDetailsService = services.NewPropertyDetailsService()
 }
 
+// Get starts the same background job as Post and answers 202 with its id
 func (c *PropertyDetailController) Get() {
-    propertyDetails, err := c.propertyDetailsService.FetchPropertyDetails()
-    if err != nil {
-        c.Ctx.Output.SetStatus(http.StatusInternalServerError)
-        c.Data["json"] = map[string]interface{}{"error": err.Error()}
-    } else {
-        c.Data["json"] = propertyDetails
+    c.Post()
+}
+
+// Post starts the property details fetch as a background job
+func (c *PropertyDetailController) Post() {
+    selection, ok := parseSelection(&c.Controller)
+    if !ok {
+        return
     }
-    c.ServeJSON()
+    service := c.propertyDetailsService
+    service.Selection = selection
+    startJob(&c.Controller, services.JobKindPropertyDetails, func(ctx context.Context, job *services.Job) error {
+        service.Progress = job
+        _, err := service.FetchPropertyDetails(ctx)
+        return err
+    })
 }
 // package controllers
 
@@ -1,7 +1,12 @@
 package controllers
 
 import (
-	// "backend_rental/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend_rental/services"
 	"backend_rental/models"
     "github.com/beego/beego/v2/client/orm"
 
@@ -29,4 +34,131 @@ func (c *PropertyDetailControllerDB) Get() {
 	// Return property details
 	c.Data["json"] = propertyDetails
 	c.ServeJSON()
-}
\ No newline at end of file
+}
+
+// Show returns one listing joined with its details and city
+func (c *PropertyDetailControllerDB) Show() {
+	propertyID, err := strconv.ParseInt(c.Ctx.Input.Param(":propertyId"), 10, 64)
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": "invalid property id"}
+		c.ServeJSON()
+		return
+	}
+
+	currency, err := services.ParseCurrency(c.Ctx.Request.URL.Query())
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	service := &services.PropertyDetailsServiceDB{}
+	document, err := service.GetPropertyDocument(propertyID, currency)
+	if errors.Is(err, services.ErrUnknownCurrency) {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	if err == services.ErrPropertyNotFound {
+		c.Ctx.Output.SetStatus(http.StatusNotFound)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	c.Data["json"] = document
+	c.ServeJSON()
+}
+
+// Prices returns the price history of one listing for charting
+func (c *PropertyDetailControllerDB) Prices() {
+	propertyID, err := strconv.ParseInt(c.Ctx.Input.Param(":propertyId"), 10, 64)
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": "invalid property id"}
+		c.ServeJSON()
+		return
+	}
+	query, err := services.ParsePriceHistoryQuery(c.Ctx.Request.URL.Query())
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	service := &services.PropertyPriceService{}
+	history, err := service.History(propertyID, query)
+	if errors.Is(err, services.ErrUnknownCurrency) {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	if err == services.ErrPropertyNotFound {
+		c.Ctx.Output.SetStatus(http.StatusNotFound)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	c.Data["json"] = history
+	c.ServeJSON()
+}
+
+// Availability returns the sampled nights of one listing for the booking widget
+func (c *PropertyDetailControllerDB) Availability() {
+	propertyID, err := strconv.ParseInt(c.Ctx.Input.Param(":propertyId"), 10, 64)
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": "invalid property id"}
+		c.ServeJSON()
+		return
+	}
+	query, err := services.ParseAvailabilityQuery(c.Ctx.Request.URL.Query(), time.Now())
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	service := &services.AvailabilityService{}
+	calendar, err := service.Calendar(propertyID, query)
+	if errors.Is(err, services.ErrUnknownCurrency) {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	if err == services.ErrPropertyNotFound {
+		c.Ctx.Output.SetStatus(http.StatusNotFound)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	c.Data["json"] = calendar
+	c.ServeJSON()
+}
@@ -15,7 +15,7 @@ func (c *PropertyListController) Get() {
     var properties []models.RentalProperty
 
     // Query all properties
-    _, err := o.QueryTable("rental_property").All(&properties)
+    _, err := o.QueryTable("rental_property").Filter("deleted_at__isnull", true).All(&properties)
     if err != nil {
         c.Data["json"] = map[string]string{"error": "Failed to retrieve properties"}
         c.ServeJSON()
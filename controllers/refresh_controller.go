@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"net/http"
+
+	"backend_rental/services"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+type RefreshController struct {
+	beego.Controller
+	refreshService *services.PropertyRefreshService
+}
+
+func (c *RefreshController) Prepare() {
+	c.refreshService = services.NewPropertyRefreshService()
+}
+
+// Post starts an incremental listings refresh outside the schedule
+func (c *RefreshController) Post() {
+	job, err := services.StartPropertyRefreshJob()
+	renderJobStart(&c.Controller, job, err)
+}
+
+// History lists recent refresh runs, optionally filtered by ?city_id=
+func (c *RefreshController) History() {
+	limit, _ := c.GetInt("limit", 100)
+	history, err := c.refreshService.RefreshHistory(c.GetString("city_id"), limit)
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]interface{}{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	c.Data["json"] = history
+	c.ServeJSON()
+}
+
+// Cities reports when each city was last synced
+func (c *RefreshController) Cities() {
+	history, err := c.refreshService.LastSyncPerCity()
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]interface{}{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+	c.Data["json"] = history
+	c.ServeJSON()
+}
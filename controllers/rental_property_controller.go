@@ -1,8 +1,9 @@
 package controllers
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"net/http"
+	"strconv"
 
 	beego "github.com/beego/beego/v2/server/web"
 	"backend_rental/models"
@@ -28,62 +29,82 @@ func (c *RentalPropertyController) Prepare() {
 	}
 }
 
-// Get handler to fetch properties from DB or file and generate RentalProperty.json
-func (c *RentalPropertyController) Get() {
-	// Initialize the ORM
-	o := orm.NewOrm()
-
-	// Define the properties variable
-	var properties []models.RentalProperty
-
-	// Try to fetch rental property data from the database
-	_, err := o.QueryTable("rental_property").All(&properties)
+// List serves one page of rental properties with filters, sorting and next/prev links
+func (c *RentalPropertyController) List() {
+	query, err := services.ParsePropertyListQuery(c.Ctx.Request.URL.Query())
 	if err != nil {
-		c.Data["json"] = map[string]string{"error": "Failed to fetch properties from the database"}
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
 		c.ServeJSON()
 		return
 	}
 
-	// If properties are found in the database, serve them as JSON
-	if len(properties) > 0 {
-		c.Data["json"] = properties
+	service := &services.PropertyListService{}
+	page, err := service.List(c.Ctx.Request.Context(), query)
+	if errors.Is(err, services.ErrUnknownCurrency) {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
 		c.ServeJSON()
 		return
 	}
-
-	// If no properties found in DB, fallback to reading properties.json file
-	propertiesData, err := ioutil.ReadFile("data/properties.json")
 	if err != nil {
-		c.Data["json"] = map[string]string{"error": "Failed to read properties file"}
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]string{"error": "Failed to fetch properties from the database"}
 		c.ServeJSON()
 		return
 	}
 
-	var fileProperties []map[string]interface{}
-	if err := json.Unmarshal(propertiesData, &fileProperties); err != nil {
-		c.Data["json"] = map[string]string{"error": "Failed to parse properties JSON"}
-		c.ServeJSON()
-		return
+	if page.Page < page.TotalPages {
+		next := c.pageLink(page.Page + 1)
+		page.Next = &next
 	}
+	if page.Page > 1 && page.TotalPages > 0 {
+		prev := c.pageLink(min(page.Page-1, page.TotalPages))
+		page.Prev = &prev
+	}
+
+	c.Data["json"] = page
+	c.ServeJSON()
+}
+
+// pageLink returns the current request URL with the page parameter replaced
+func (c *RentalPropertyController) pageLink(page int) string {
+	values := c.Ctx.Request.URL.Query()
+	values.Set("page", strconv.Itoa(page))
+	return c.Ctx.Request.URL.Path + "?" + values.Encode()
+}
 
-	// Read property_details.json for additional data
-	detailsData, err := ioutil.ReadFile("data/property_details.json")
+// Get handler to fetch properties from DB or file and generate RentalProperty.json
+func (c *RentalPropertyController) Get() {
+	// Initialize the ORM
+	o := orm.NewOrm()
+
+	// Define the properties variable
+	var properties []models.RentalProperty
+
+	// Try to fetch rental property data from the database
+	_, err := o.QueryTable("rental_property").Filter("deleted_at__isnull", true).All(&properties)
 	if err != nil {
-		c.Data["json"] = map[string]string{"error": "Failed to read property details file"}
+		c.Data["json"] = map[string]string{"error": "Failed to fetch properties from the database"}
 		c.ServeJSON()
 		return
 	}
 
-	var propertyDetails []map[string]interface{}
-	if err := json.Unmarshal(detailsData, &propertyDetails); err != nil {
-		c.Data["json"] = map[string]string{"error": "Failed to parse property details JSON"}
+	// If properties are found in the database, serve them as JSON
+	if len(properties) > 0 {
+		if err := services.AttachAmenities(properties); err != nil {
+			c.Data["json"] = map[string]string{"error": err.Error()}
+			c.ServeJSON()
+			return
+		}
+		c.Data["json"] = properties
 		c.ServeJSON()
 		return
 	}
 
-	// Create service and generate RentalProperty.json
+	// If no properties found in DB, generate RentalProperty.json from the fetched files
 	service := &services.RentalPropertyService{}
-	err = service.GenerateRentalPropertyJSON(fileProperties, propertyDetails)
+	err = service.GenerateFromFiles()
 
 	if err != nil {
 		c.Data["json"] = map[string]string{"error": err.Error()}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend_rental/models"
+	"backend_rental/services"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+type ReservationController struct {
+	beego.Controller
+}
+
+// Post creates a reservation from a JSON body
+func (c *ReservationController) Post() {
+	request, err := services.ParseReservationRequest(c.Ctx.Input.RequestBody, time.Now())
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	service := &services.ReservationService{}
+	reservation, err := service.Create(c.Ctx.Request.Context(), request)
+	if err == nil {
+		c.Ctx.Output.Header("Location", "/v1/reservations/"+strconv.FormatInt(reservation.ID, 10))
+		c.Ctx.Output.SetStatus(http.StatusCreated)
+	}
+	c.render(reservation, err)
+}
+
+// Get returns one reservation
+func (c *ReservationController) Get() {
+	id, ok := c.reservationID()
+	if !ok {
+		return
+	}
+	service := &services.ReservationService{}
+	c.render(service.Get(id))
+}
+
+// Confirm confirms a pending reservation; 409 when it overlaps a confirmed one or has no price
+func (c *ReservationController) Confirm() {
+	id, ok := c.reservationID()
+	if !ok {
+		return
+	}
+	service := &services.ReservationService{}
+	c.render(service.Confirm(id))
+}
+
+// Cancel cancels a reservation
+func (c *ReservationController) Cancel() {
+	id, ok := c.reservationID()
+	if !ok {
+		return
+	}
+	service := &services.ReservationService{}
+	c.render(service.Cancel(id))
+}
+
+// reservationID reads the :id parameter, answering 400 and returning false when it is invalid
+func (c *ReservationController) reservationID() (int64, bool) {
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": "invalid reservation id"}
+		c.ServeJSON()
+		return 0, false
+	}
+	return id, true
+}
+
+// render answers with reservation, or with the status matching err
+func (c *ReservationController) render(reservation *models.Reservation, err error) {
+	switch {
+	case err == nil:
+		c.Data["json"] = reservation
+	case errors.Is(err, services.ErrUnknownCurrency):
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+	case errors.Is(err, services.ErrReservationNotFound), err == services.ErrPropertyNotFound:
+		c.Ctx.Output.SetStatus(http.StatusNotFound)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+	case errors.Is(err, services.ErrReservationOverlap), errors.Is(err, services.ErrReservationStatus),
+		errors.Is(err, services.ErrPropertyUnavailable), errors.Is(err, services.ErrReservationUnpriced):
+		c.Ctx.Output.SetStatus(http.StatusConflict)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+	case errors.Is(err, services.ErrStayUnverified):
+		c.Ctx.Output.SetStatus(http.StatusServiceUnavailable)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+	default:
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+	}
+	c.ServeJSON()
+}
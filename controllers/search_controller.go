@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+
+	"backend_rental/services"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+type SearchController struct {
+	beego.Controller
+}
+
+// Get ranks listings matching ?q= across name, description and amenities
+func (c *SearchController) Get() {
+	query, err := services.ParseSearchQuery(c.Ctx.Request.URL.Query())
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	result, err := services.NewSearchService().Search(query)
+	if err != nil {
+		c.Ctx.Output.SetStatus(http.StatusInternalServerError)
+		c.Data["json"] = map[string]string{"error": err.Error()}
+		c.ServeJSON()
+		return
+	}
+
+	c.Data["json"] = result
+	c.ServeJSON()
+}
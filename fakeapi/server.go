@@ -0,0 +1,142 @@
+// Package fakeapi is an offline stand-in for the RapidAPI Booking endpoints
+// used by the ingest services. Responses are replayed from recorded fixtures
+// (the fetched/*.json files) through utils.FileListingsProvider.
+package fakeapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+
+	"backend_rental/utils"
+)
+
+type server struct {
+	provider *utils.FileListingsProvider
+}
+
+// NewHandler returns an http.Handler serving the Booking endpoints from fixtureDir
+func NewHandler(fixtureDir string) http.Handler {
+	s := &server{provider: utils.NewFileListingsProvider(fixtureDir)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stays/auto-complete", s.autoComplete)
+	mux.HandleFunc("/stays/search", s.search)
+	mux.HandleFunc("/stays/detail", s.detail)
+	mux.HandleFunc("/stays/get-description", s.description)
+	mux.HandleFunc("/web/stays/details", s.webDetails)
+	return mux
+}
+
+// NewServer starts an httptest server backed by fixtureDir; callers must Close it
+func NewServer(fixtureDir string) *httptest.Server {
+	return httptest.NewServer(NewHandler(fixtureDir))
+}
+
+func (s *server) autoComplete(w http.ResponseWriter, r *http.Request) {
+	query, ok := requireParam(w, r, "query")
+	if !ok {
+		return
+	}
+	response, err := s.provider.FetchCityData(query)
+	writeResponse(w, response, err)
+}
+
+func (s *server) search(w http.ResponseWriter, r *http.Request) {
+	locationID, ok := requireParam(w, r, "locationId")
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	page := 1
+	if q.Get("page") != "" {
+		if page, ok = requireIntParam(w, r, "page"); !ok {
+			return
+		}
+	}
+	response, err := s.provider.FetchPropertiesForCity(r.Context(), locationID, q.Get("checkinDate"), q.Get("checkoutDate"), page)
+	writeResponse(w, response, err)
+}
+
+func (s *server) detail(w http.ResponseWriter, r *http.Request) {
+	hotelID, ok := requireIntParam(w, r, "hotelId")
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	response, err := s.provider.FetchPropertyDetails(r.Context(), hotelID, q.Get("checkinDate"), q.Get("checkoutDate"))
+	writeResponse(w, response, err)
+}
+
+func (s *server) description(w http.ResponseWriter, r *http.Request) {
+	hotelID, ok := requireParam(w, r, "hotelId")
+	if !ok {
+		return
+	}
+	response, err := s.provider.FetchPropertyDescription(r.Context(), hotelID)
+	writeResponse(w, response, err)
+}
+
+// webDetails resolves the property from the last segment of the id slug;
+// slugs that do not end in a hotel ID get an empty photo set
+func (s *server) webDetails(w http.ResponseWriter, r *http.Request) {
+	id, ok := requireParam(w, r, "id")
+	if !ok {
+		return
+	}
+	hotelID, err := strconv.Atoi(path.Base(id))
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{}})
+		return
+	}
+	q := r.URL.Query()
+	response, err := s.provider.FetchPropertyPhotos(r.Context(), hotelID, q.Get("checkIn"), q.Get("checkOut"))
+	writeResponse(w, response, err)
+}
+
+func requireParam(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  false,
+			"message": "missing required parameter: " + name,
+		})
+		return "", false
+	}
+	return value, true
+}
+
+func requireIntParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	value, ok := requireParam(w, r, name)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  false,
+			"message": "invalid parameter " + name + ": " + value,
+		})
+		return 0, false
+	}
+	return n, true
+}
+
+func writeResponse(w http.ResponseWriter, response interface{}, err error) {
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"status":  false,
+			"message": err.Error(),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,80 @@
+package fakeapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"backend_rental/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestApiClientAgainstFixtures runs every ApiClient fetcher against the recorded stand-in
+func TestApiClientAgainstFixtures(t *testing.T) {
+	srv := NewServer("../fetched")
+	defer srv.Close()
+
+	// An own HTTP client keeps these calls out of the persisted RapidAPI usage
+	client := &utils.ApiClient{
+		BaseURL:    srv.URL,
+		Headers:    map[string]string{},
+		HTTPClient: &http.Client{Transport: utils.NewRetryTransport(nil, utils.DefaultRetryPolicy)},
+	}
+
+	Convey("Subject: ApiClient served by the fake Booking API\n", t, func() {
+		Convey("Auto-complete returns the recorded cities for a letter", func() {
+			response, err := client.FetchCityData("A")
+			So(err, ShouldBeNil)
+			So(len(response.Data), ShouldBeGreaterThan, 0)
+			So(response.Data[0].CityName, ShouldStartWith, "A")
+		})
+
+		Convey("Search returns the recorded properties of a city", func() {
+			cities, err := client.FetchCityData("Amsterdam")
+			So(err, ShouldBeNil)
+			So(len(cities.Data), ShouldBeGreaterThan, 0)
+
+			response, err := client.FetchPropertiesForCity(context.Background(), cities.Data[0].CityID, "2026-11-03", "2026-11-04", 1)
+			So(err, ShouldBeNil)
+			So(len(response.Data), ShouldBeGreaterThan, 0)
+			So(response.Data[0].CityID, ShouldEqual, cities.Data[0].CityID)
+		})
+
+		Convey("Detail and description are keyed on the hotel ID", func() {
+			details, err := client.FetchPropertyDetails(context.Background(), 3226748, "2026-11-03", "2026-11-04")
+			So(err, ShouldBeNil)
+			So(details.Data.HotelID, ShouldEqual, 3226748)
+			So(details.Data.AccommodationTypeName, ShouldEqual, "Hotels")
+			So(details.Drift.Breaking(), ShouldBeFalse)
+
+			desc, err := client.FetchPropertyDescription(context.Background(), "3226748")
+			So(err, ShouldBeNil)
+			So(len(desc.Data), ShouldEqual, 1)
+			So(desc.Data[0].DescriptionTypeID, ShouldEqual, 6)
+		})
+
+		Convey("Photos are fetched for the requested property via its page slug", func() {
+			details, err := client.FetchPropertyDetails(context.Background(), 3226748, "2026-11-03", "2026-11-04")
+			So(err, ShouldBeNil)
+			slug, err := utils.StaySlug(details.Data.URL)
+			So(err, ShouldBeNil)
+			So(slug, ShouldEqual, "xx/3226748")
+
+			photos, err := client.FetchPropertyPhotos(context.Background(), 3226748, "2026-11-03", "2026-11-10")
+			So(err, ShouldBeNil)
+			So(photos.Data["hotelPhotos"], ShouldNotBeEmpty)
+
+			_, err = client.FetchPropertyPhotos(context.Background(), 1, "2026-11-03", "2026-11-10")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Page URLs reduce to the slug the photo endpoint takes", func() {
+			slug, err := utils.StaySlug("https://www.booking.com/hotel/us/mayfair-new-york.en-gb.html")
+			So(err, ShouldBeNil)
+			So(slug, ShouldEqual, "us/mayfair-new-york")
+
+			_, err = utils.StaySlug("https://www.booking.com/searchresults.html")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
     _ "backend_rental/routers"
     "log"
     beego "github.com/beego/beego/v2/server/web"
+    "backend_rental/services"
     "backend_rental/utils"
 )
 
@@ -20,5 +21,10 @@ func main() {
         log.Fatalf("Failed to initialize database: %v", err)
     }
 
+    // Start periodic jobs configured in app.conf
+    if err := services.StartScheduler(); err != nil {
+        log.Fatalf("Failed to start scheduler: %v", err)
+    }
+
     beego.Run()
 }
@@ -0,0 +1,77 @@
+package migrations
+
+// Baseline matches the schema orm.RunSyncdb used to create, so databases set up before
+// migrations existed are adopted as-is
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "baseline",
+		Up: statements(
+			`CREATE TABLE IF NOT EXISTS location (
+				id bigserial NOT NULL PRIMARY KEY,
+				city_name varchar(128) NOT NULL,
+				city_id varchar(128) NOT NULL,
+				country varchar(128) NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS city_crawl_state (
+				id bigserial NOT NULL PRIMARY KEY,
+				name varchar(64) NOT NULL UNIQUE,
+				status varchar(16) NOT NULL,
+				last_query varchar(16) NOT NULL,
+				last_error text NOT NULL,
+				query_counts text NOT NULL,
+				started_at timestamp with time zone NOT NULL,
+				updated_at timestamp with time zone NOT NULL,
+				completed_at timestamp with time zone
+			)`,
+			`CREATE TABLE IF NOT EXISTS rental_property (
+				id bigserial NOT NULL PRIMARY KEY,
+				city_id varchar(255) NOT NULL,
+				property_id bigint NOT NULL,
+				name varchar(255) NOT NULL,
+				property_type varchar(255) NOT NULL,
+				bedrooms integer NOT NULL,
+				bathrooms integer NOT NULL,
+				deleted_at timestamp with time zone
+			)`,
+			`ALTER TABLE rental_property ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone`,
+			`CREATE INDEX IF NOT EXISTS rental_property_city_id ON rental_property (city_id)`,
+			`CREATE INDEX IF NOT EXISTS rental_property_property_type ON rental_property (property_type)`,
+			`CREATE INDEX IF NOT EXISTS rental_property_bedrooms ON rental_property (bedrooms)`,
+			`CREATE INDEX IF NOT EXISTS rental_property_bathrooms ON rental_property (bathrooms)`,
+			`CREATE INDEX IF NOT EXISTS rental_property_name ON rental_property (name)`,
+			`CREATE TABLE IF NOT EXISTS property_details (
+				id bigserial NOT NULL PRIMARY KEY,
+				property_id bigint NOT NULL,
+				description text NOT NULL,
+				review_score double precision NOT NULL,
+				review_count integer NOT NULL,
+				review_score_word varchar(255) NOT NULL,
+				image_type varchar(255) NOT NULL,
+				image_urls varchar(255) NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS property_refresh (
+				id bigserial NOT NULL PRIMARY KEY,
+				city_id varchar(255) NOT NULL,
+				city_name varchar(128) NOT NULL,
+				status varchar(16) NOT NULL,
+				error text NOT NULL,
+				fetched integer NOT NULL,
+				inserted integer NOT NULL,
+				updated integer NOT NULL,
+				deleted integer NOT NULL,
+				unchanged integer NOT NULL,
+				started_at timestamp with time zone NOT NULL,
+				finished_at timestamp with time zone NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS property_refresh_city_id ON property_refresh (city_id)`,
+		),
+		Down: statements(
+			`DROP TABLE IF EXISTS property_refresh`,
+			`DROP TABLE IF EXISTS property_details`,
+			`DROP TABLE IF EXISTS rental_property`,
+			`DROP TABLE IF EXISTS city_crawl_state`,
+			`DROP TABLE IF EXISTS location`,
+		),
+	})
+}
@@ -0,0 +1,107 @@
+package migrations
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"backend_rental/models"
+)
+
+// Amenities moves the JSON-encoded rental_property.amenities column into the amenity and
+// property_amenity tables
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "amenities",
+		Up: func(tx *sql.Tx) error {
+			err := statements(
+				`CREATE TABLE IF NOT EXISTS amenity (
+					id bigserial NOT NULL PRIMARY KEY,
+					slug varchar(100) NOT NULL UNIQUE,
+					name varchar(255) NOT NULL,
+					category varchar(50) NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS amenity_category ON amenity (category)`,
+				`CREATE TABLE IF NOT EXISTS property_amenity (
+					id bigserial NOT NULL PRIMARY KEY,
+					property_id bigint NOT NULL,
+					amenity_id bigint NOT NULL,
+					UNIQUE (property_id, amenity_id)
+				)`,
+				`CREATE INDEX IF NOT EXISTS property_amenity_amenity_id ON property_amenity (amenity_id)`,
+			)(tx)
+			if err != nil {
+				return err
+			}
+			return migrateLegacyAmenities(tx)
+		},
+		Down: statements(
+			`ALTER TABLE rental_property ADD COLUMN IF NOT EXISTS amenities text NOT NULL DEFAULT '[]'`,
+			`UPDATE rental_property rp SET amenities = coalesce((
+				SELECT json_agg(a.name ORDER BY a.name)::text FROM property_amenity pa
+				JOIN amenity a ON a.id = pa.amenity_id
+				WHERE pa.property_id = rp.property_id), '[]')`,
+			`DROP TABLE IF EXISTS property_amenity`,
+			`DROP TABLE IF EXISTS amenity`,
+		),
+	})
+}
+
+// migrateLegacyAmenities links the amenities of the legacy column, if present, then drops it
+func migrateLegacyAmenities(tx *sql.Tx) error {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM information_schema.columns
+		WHERE table_name = 'rental_property' AND column_name = 'amenities')`).Scan(&exists)
+	if err != nil || !exists {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT property_id, coalesce(amenities, '') FROM rental_property")
+	if err != nil {
+		return err
+	}
+	legacy := map[int64]string{}
+	for rows.Next() {
+		var propertyID int64
+		var raw string
+		if err := rows.Scan(&propertyID, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy[propertyID] = raw
+	}
+	rows.Close()
+
+	for propertyID, raw := range legacy {
+		// AmenityList decodes the JSON-encoded string form of the legacy column
+		encoded, _ := json.Marshal(raw)
+		var amenities models.AmenityList
+		if err := json.Unmarshal(encoded, &amenities); err != nil {
+			return fmt.Errorf("failed to parse amenities of property %d: %v", propertyID, err)
+		}
+
+		for _, amenity := range amenities {
+			_, err := tx.Exec(`
+				WITH saved AS (
+					INSERT INTO amenity (slug, name, category) VALUES ($2, $3, $4)
+					ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name
+					RETURNING id
+				)
+				INSERT INTO property_amenity (property_id, amenity_id)
+				SELECT $1, id FROM saved
+				ON CONFLICT (property_id, amenity_id) DO NOTHING`,
+				propertyID, amenity.Slug, amenity.Name, amenity.Category)
+			if err != nil {
+				return fmt.Errorf("failed to link amenity %s to property %d: %v", amenity.Slug, propertyID, err)
+			}
+		}
+	}
+
+	// A search trigger created before migrations existed lists the column
+	return statements(
+		`DROP TRIGGER IF EXISTS rental_property_search_document_trg ON rental_property`,
+		`ALTER TABLE rental_property DROP COLUMN amenities`,
+	)(tx)
+}
@@ -0,0 +1,71 @@
+package migrations
+
+// SearchIndex maintains rental_property.search_document, a weighted tsvector over the
+// property name (A), its property_details description (B) and its linked amenities (C)
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "search_index",
+		Up: statements(
+			`ALTER TABLE rental_property ADD COLUMN IF NOT EXISTS search_document tsvector`,
+			`CREATE INDEX IF NOT EXISTS rental_property_search_document_idx ON rental_property USING GIN (search_document)`,
+			`CREATE OR REPLACE FUNCTION rental_property_search_document() RETURNS trigger AS $$
+			BEGIN
+				NEW.search_document :=
+					setweight(to_tsvector('english', coalesce(NEW.name, '')), 'A') ||
+					setweight(to_tsvector('english', coalesce((
+						SELECT pd.description FROM property_details pd
+						WHERE pd.property_id = NEW.property_id
+						ORDER BY pd.id LIMIT 1), '')), 'B') ||
+					setweight(to_tsvector('english', coalesce((
+						SELECT string_agg(a.name, ' ') FROM property_amenity pa
+						JOIN amenity a ON a.id = pa.amenity_id
+						WHERE pa.property_id = NEW.property_id), '')), 'C');
+				RETURN NEW;
+			END
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS rental_property_search_document_trg ON rental_property`,
+			`CREATE TRIGGER rental_property_search_document_trg
+				BEFORE INSERT OR UPDATE OF name, property_id ON rental_property
+				FOR EACH ROW EXECUTE PROCEDURE rental_property_search_document()`,
+			// A new or edited description re-touches the listing so its document is rebuilt
+			`CREATE OR REPLACE FUNCTION property_details_search_document() RETURNS trigger AS $$
+			BEGIN
+				UPDATE rental_property SET name = name WHERE property_id = NEW.property_id;
+				RETURN NULL;
+			END
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS property_details_search_document_trg ON property_details`,
+			`CREATE TRIGGER property_details_search_document_trg
+				AFTER INSERT OR UPDATE OF description ON property_details
+				FOR EACH ROW EXECUTE PROCEDURE property_details_search_document()`,
+			// Linking or unlinking an amenity does the same
+			`CREATE OR REPLACE FUNCTION property_amenity_search_document() RETURNS trigger AS $$
+			BEGIN
+				IF TG_OP = 'DELETE' THEN
+					UPDATE rental_property SET name = name WHERE property_id = OLD.property_id;
+				ELSE
+					UPDATE rental_property SET name = name WHERE property_id = NEW.property_id;
+				END IF;
+				RETURN NULL;
+			END
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS property_amenity_search_document_trg ON property_amenity`,
+			`CREATE TRIGGER property_amenity_search_document_trg
+				AFTER INSERT OR DELETE ON property_amenity
+				FOR EACH ROW EXECUTE PROCEDURE property_amenity_search_document()`,
+			// Build the document of every existing listing
+			`UPDATE rental_property SET name = name`,
+		),
+		Down: statements(
+			`DROP TRIGGER IF EXISTS property_amenity_search_document_trg ON property_amenity`,
+			`DROP TRIGGER IF EXISTS property_details_search_document_trg ON property_details`,
+			`DROP TRIGGER IF EXISTS rental_property_search_document_trg ON rental_property`,
+			`DROP FUNCTION IF EXISTS property_amenity_search_document()`,
+			`DROP FUNCTION IF EXISTS property_details_search_document()`,
+			`DROP FUNCTION IF EXISTS rental_property_search_document()`,
+			`DROP INDEX IF EXISTS rental_property_search_document_idx`,
+			`ALTER TABLE rental_property DROP COLUMN IF EXISTS search_document`,
+		),
+	})
+}
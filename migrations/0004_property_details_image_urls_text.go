@@ -0,0 +1,16 @@
+package migrations
+
+// PropertyDetailsImageUrlsText widens image_urls, whose JSON array of URLs rarely fits in
+// the varchar(255) RunSyncdb created
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "property_details_image_urls_text",
+		Up: statements(
+			`ALTER TABLE property_details ALTER COLUMN image_urls TYPE text`,
+		),
+		Down: statements(
+			`ALTER TABLE property_details ALTER COLUMN image_urls TYPE varchar(255)`,
+		),
+	})
+}
@@ -0,0 +1,22 @@
+package migrations
+
+// UniquePropertyIDs keys rental_property and property_details on property_id so seeding can
+// upsert with ON CONFLICT. Duplicates left by earlier reloads keep their oldest row.
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "unique_property_ids",
+		Up: statements(
+			`DELETE FROM rental_property a USING rental_property b
+				WHERE a.property_id = b.property_id AND a.id > b.id`,
+			`DELETE FROM property_details a USING property_details b
+				WHERE a.property_id = b.property_id AND a.id > b.id`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS rental_property_property_id_key ON rental_property (property_id)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS property_details_property_id_key ON property_details (property_id)`,
+		),
+		Down: statements(
+			`DROP INDEX IF EXISTS property_details_property_id_key`,
+			`DROP INDEX IF EXISTS rental_property_property_id_key`,
+		),
+	})
+}
@@ -0,0 +1,28 @@
+package migrations
+
+// PropertyPhoto stores every photo of a property with its metadata instead of only the
+// thumbnail URLs kept in property_details.image_urls
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "property_photo",
+		Up: statements(
+			`CREATE TABLE IF NOT EXISTS property_photo (
+				id bigserial NOT NULL PRIMARY KEY,
+				property_id bigint NOT NULL,
+				photo_id bigint NOT NULL DEFAULT 0,
+				kind varchar(16) NOT NULL DEFAULT '',
+				room_id bigint NOT NULL DEFAULT 0,
+				room_name varchar(255) NOT NULL DEFAULT '',
+				caption text NOT NULL DEFAULT '',
+				large_url text NOT NULL DEFAULT '',
+				thumb_url text NOT NULL DEFAULT '',
+				position integer NOT NULL DEFAULT 0
+			)`,
+			`CREATE INDEX IF NOT EXISTS property_photo_property_id ON property_photo (property_id, kind, position)`,
+		),
+		Down: statements(
+			`DROP TABLE IF EXISTS property_photo`,
+		),
+	})
+}
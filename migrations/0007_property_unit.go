@@ -0,0 +1,31 @@
+package migrations
+
+// PropertyUnit stores the rooms and apartments of each property; rental_property keeps the
+// occupancy of its largest unit next to the bedroom and bathroom summary
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "property_unit",
+		Up: statements(
+			`CREATE TABLE IF NOT EXISTS property_unit (
+				id bigserial NOT NULL PRIMARY KEY,
+				property_id bigint NOT NULL,
+				room_id bigint NOT NULL DEFAULT 0,
+				name varchar(255) NOT NULL DEFAULT '',
+				bedrooms integer NOT NULL DEFAULT 0,
+				bathrooms integer NOT NULL DEFAULT 0,
+				bathroom_type varchar(16) NOT NULL DEFAULT '',
+				bed_configuration text NOT NULL DEFAULT '',
+				max_occupancy integer NOT NULL DEFAULT 0,
+				size_m2 double precision NOT NULL DEFAULT 0,
+				position integer NOT NULL DEFAULT 0
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS property_unit_property_room ON property_unit (property_id, room_id)`,
+			`ALTER TABLE rental_property ADD COLUMN IF NOT EXISTS max_occupancy integer NOT NULL DEFAULT 0`,
+		),
+		Down: statements(
+			`ALTER TABLE rental_property DROP COLUMN IF EXISTS max_occupancy`,
+			`DROP TABLE IF EXISTS property_unit`,
+		),
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+// PropertyPrice keeps every price quoted by a search, one row per property, check-in date
+// and fetch
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "property_price",
+		Up: statements(
+			`CREATE TABLE IF NOT EXISTS property_price (
+				id bigserial NOT NULL PRIMARY KEY,
+				property_id bigint NOT NULL,
+				check_in date NOT NULL,
+				check_out date NOT NULL,
+				fetched_at timestamp with time zone NOT NULL,
+				currency varchar(3) NOT NULL DEFAULT '',
+				gross double precision NOT NULL DEFAULT 0,
+				strikethrough double precision,
+				taxes double precision NOT NULL DEFAULT 0
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS property_price_stay_fetch ON property_price (property_id, check_in, fetched_at)`,
+		),
+		Down: statements(
+			`DROP TABLE IF EXISTS property_price`,
+		),
+	})
+}
@@ -0,0 +1,19 @@
+package migrations
+
+// CurrencyRate holds the exchange rates prices are converted with
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "currency_rate",
+		Up: statements(
+			`CREATE TABLE IF NOT EXISTS currency_rate (
+				currency varchar(3) NOT NULL PRIMARY KEY,
+				rate double precision NOT NULL,
+				updated_at timestamp with time zone NOT NULL DEFAULT now()
+			)`,
+		),
+		Down: statements(
+			`DROP TABLE IF EXISTS currency_rate`,
+		),
+	})
+}
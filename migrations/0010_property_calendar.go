@@ -0,0 +1,26 @@
+package migrations
+
+// PropertyCalendar keeps the latest sampled availability and price of each property per night
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "property_calendar",
+		Up: statements(
+			`CREATE TABLE IF NOT EXISTS property_calendar (
+				id bigserial NOT NULL PRIMARY KEY,
+				property_id bigint NOT NULL,
+				night date NOT NULL,
+				available boolean NOT NULL DEFAULT false,
+				price double precision,
+				currency varchar(3) NOT NULL DEFAULT '',
+				max_occupancy integer NOT NULL DEFAULT 0,
+				fetched_at timestamp with time zone NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS property_calendar_property_night ON property_calendar (property_id, night)`,
+			`CREATE INDEX IF NOT EXISTS property_calendar_night ON property_calendar (night, available)`,
+		),
+		Down: statements(
+			`DROP TABLE IF EXISTS property_calendar`,
+		),
+	})
+}
@@ -0,0 +1,42 @@
+package migrations
+
+// Reservation books a property for a stay. The exclusion constraint keeps two confirmed
+// reservations of one property from sharing a night; checking out and checking in on the
+// same day does not overlap, since the ranges exclude the check-out date.
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "reservation",
+		Up: statements(
+			// btree_gist lets the gist index compare property_id with =
+			`CREATE EXTENSION IF NOT EXISTS btree_gist`,
+			`CREATE TABLE IF NOT EXISTS reservation (
+				id bigserial NOT NULL PRIMARY KEY,
+				property_id bigint NOT NULL,
+				guest_name varchar(255) NOT NULL,
+				guest_email varchar(255) NOT NULL,
+				guests integer NOT NULL DEFAULT 1,
+				check_in date NOT NULL,
+				check_out date NOT NULL,
+				status varchar(16) NOT NULL DEFAULT 'pending',
+				total_price double precision,
+				currency varchar(3) NOT NULL DEFAULT '',
+				created_at timestamp with time zone NOT NULL DEFAULT now(),
+				updated_at timestamp with time zone NOT NULL DEFAULT now(),
+				cancelled_at timestamp with time zone,
+				CONSTRAINT reservation_stay CHECK (check_out > check_in),
+				CONSTRAINT reservation_guests CHECK (guests > 0),
+				CONSTRAINT reservation_status CHECK (status IN ('pending', 'confirmed', 'cancelled')),
+				CONSTRAINT reservation_no_overlap EXCLUDE USING gist (
+					property_id WITH =,
+					daterange(check_in, check_out, '[)') WITH &&
+				) WHERE (status = 'confirmed')
+			)`,
+			`CREATE INDEX IF NOT EXISTS reservation_property ON reservation (property_id, check_in)`,
+		),
+		// btree_gist stays installed; other schemas may use it
+		Down: statements(
+			`DROP TABLE IF EXISTS reservation`,
+		),
+	})
+}
@@ -0,0 +1,18 @@
+package migrations
+
+// RefreshMisses counts the refreshes in a row that missed a listing, so one incomplete
+// search does not soft-delete it, and records those misses per refresh run
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "refresh_misses",
+		Up: statements(
+			`ALTER TABLE rental_property ADD COLUMN IF NOT EXISTS missed_refreshes integer NOT NULL DEFAULT 0`,
+			`ALTER TABLE property_refresh ADD COLUMN IF NOT EXISTS missed integer NOT NULL DEFAULT 0`,
+		),
+		Down: statements(
+			`ALTER TABLE property_refresh DROP COLUMN IF EXISTS missed`,
+			`ALTER TABLE rental_property DROP COLUMN IF EXISTS missed_refreshes`,
+		),
+	})
+}
@@ -0,0 +1,226 @@
+// Package migrations holds the numbered schema migrations of the rental database and
+// the runner that applies them, recording each applied version in schema_migrations.
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Migration is one numbered schema change. Up and Down run inside a transaction.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+}
+
+// Status is a migration together with when it was applied, if it was
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+// lockKey serializes migration runs across processes via pg_advisory_xact_lock
+const lockKey = 72814530
+
+var registry = map[int]Migration{}
+
+// register adds a migration; each migration file calls it from init
+func register(m Migration) {
+	if _, ok := registry[m.Version]; ok {
+		panic(fmt.Sprintf("migrations: duplicate version %d", m.Version))
+	}
+	registry[m.Version] = m
+}
+
+// statements returns a step that executes each SQL statement in order
+func statements(queries ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, query := range queries {
+			if _, err := tx.Exec(query); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// All returns the registered migrations ordered by version
+func All() []Migration {
+	all := make([]Migration, 0, len(registry))
+	for _, m := range registry {
+		all = append(all, m)
+	}
+	sort.Slice(all, func(i, k int) bool {
+		return all[i].Version < all[k].Version
+	})
+	return all
+}
+
+func ensureTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			applied_at timestamp with time zone NOT NULL DEFAULT now()
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+	return nil
+}
+
+func applied(db *sql.DB) (map[int]time.Time, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	versions := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// List returns every known migration with its applied time
+func List(db *sql.DB) ([]Status, error) {
+	versions, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, m := range All() {
+		status := Status{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := versions[m.Version]; ok {
+			appliedAt := appliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet, oldest first
+func Pending(db *sql.DB) ([]Migration, error) {
+	versions, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range All() {
+		if _, ok := versions[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in version order and returns how many ran
+func Up(db *sql.DB) (int, error) {
+	pending, err := Pending(db)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range pending {
+		ran, err := run(db, m, true)
+		if err != nil {
+			return count, err
+		}
+		if ran {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns how many ran
+func Down(db *sql.DB, steps int) (int, error) {
+	versions, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+
+	all := All()
+	count := 0
+	for i := len(all) - 1; i >= 0 && count < steps; i-- {
+		if _, ok := versions[all[i].Version]; !ok {
+			continue
+		}
+		ran, err := run(db, all[i], false)
+		if err != nil {
+			return count, err
+		}
+		if ran {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// run applies or reverts m in its own transaction. It re-checks schema_migrations under
+// the advisory lock, so a migration already handled by a concurrent run is skipped.
+func run(db *sql.DB, m Migration, up bool) (bool, error) {
+	direction, step := "up", m.Up
+	if !up {
+		direction, step = "down", m.Down
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", lockKey); err != nil {
+		return false, fmt.Errorf("failed to lock schema_migrations: %v", err)
+	}
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", m.Version).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	if exists == up {
+		return false, nil
+	}
+
+	if step == nil {
+		return false, fmt.Errorf("migration %04d_%s has no %s step", m.Version, m.Name, direction)
+	}
+	if err := step(tx); err != nil {
+		return false, fmt.Errorf("migration %04d_%s %s failed: %v", m.Version, m.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record migration %04d_%s: %v", m.Version, m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %04d_%s: %v", m.Version, m.Name, err)
+	}
+	fmt.Printf("Migrated %s: %04d_%s\n", direction, m.Version, m.Name)
+	return true, nil
+}
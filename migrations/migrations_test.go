@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestRegistry checks the registered migrations can be applied and reverted in order
+func TestRegistry(t *testing.T) {
+	Convey("Subject: registered schema migrations\n", t, func() {
+		all := All()
+		So(len(all), ShouldBeGreaterThan, 0)
+
+		Convey("Versions are numbered 1..n without gaps", func() {
+			for i, m := range all {
+				So(m.Version, ShouldEqual, i+1)
+			}
+		})
+
+		Convey("Every migration has a name and both steps", func() {
+			for _, m := range all {
+				So(m.Name, ShouldNotBeEmpty)
+				So(m.Up, ShouldNotBeNil)
+				So(m.Down, ShouldNotBeNil)
+			}
+		})
+	})
+}
@@ -0,0 +1,224 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Amenity categories
+const (
+	AmenityCategoryInternet      = "internet"
+	AmenityCategoryParking       = "parking"
+	AmenityCategoryWellness      = "wellness"
+	AmenityCategoryFoodDrink     = "food-drink"
+	AmenityCategoryAccessibility = "accessibility"
+	AmenityCategoryRooms         = "rooms"
+	AmenityCategoryServices      = "services"
+	AmenityCategoryGeneral       = "general"
+)
+
+// Amenity is a canonical facility shared by many properties
+type Amenity struct {
+	ID       int64  `orm:"column(id);auto" json:"-"`
+	Slug     string `orm:"column(slug);size(100);unique" json:"slug"`
+	Name     string `orm:"column(name);size(255)" json:"name"`
+	Category string `orm:"column(category);size(50);index" json:"category"`
+}
+
+func (a *Amenity) TableName() string {
+	return "amenity"
+}
+
+// PropertyAmenity links a rental property to one of its amenities
+type PropertyAmenity struct {
+	ID         int64 `orm:"column(id);auto"`
+	PropertyID int64 `orm:"column(property_id)"`
+	AmenityID  int64 `orm:"column(amenity_id)"`
+}
+
+func (p *PropertyAmenity) TableName() string {
+	return "property_amenity"
+}
+
+func (p *PropertyAmenity) TableUnique() [][]string {
+	return [][]string{{"PropertyID", "AmenityID"}}
+}
+
+func (p *PropertyAmenity) TableIndex() [][]string {
+	return [][]string{{"AmenityID"}}
+}
+
+// canonicalAmenity is the display name and category of a known slug
+type canonicalAmenity struct {
+	Name     string
+	Category string
+}
+
+// amenityCatalog lists the amenities the providers are known to report under several names
+var amenityCatalog = map[string]canonicalAmenity{
+	"wifi":                  {"Wi-Fi", AmenityCategoryInternet},
+	"parking":               {"Parking", AmenityCategoryParking},
+	"private-parking":       {"Private parking", AmenityCategoryParking},
+	"swimming-pool":         {"Swimming pool", AmenityCategoryWellness},
+	"fitness-center":        {"Fitness center", AmenityCategoryWellness},
+	"spa":                   {"Spa", AmenityCategoryWellness},
+	"restaurant":            {"Restaurant", AmenityCategoryFoodDrink},
+	"bar":                   {"Bar", AmenityCategoryFoodDrink},
+	"breakfast":             {"Breakfast", AmenityCategoryFoodDrink},
+	"room-service":          {"Room service", AmenityCategoryServices},
+	"airport-shuttle":       {"Airport shuttle", AmenityCategoryServices},
+	"24-hour-front-desk":    {"24-hour front desk", AmenityCategoryServices},
+	"family-rooms":          {"Family rooms", AmenityCategoryRooms},
+	"non-smoking-rooms":     {"Non-smoking rooms", AmenityCategoryRooms},
+	"air-conditioning":      {"Air conditioning", AmenityCategoryRooms},
+	"accessible-facilities": {"Facilities for disabled guests", AmenityCategoryAccessibility},
+	"elevator":              {"Elevator", AmenityCategoryAccessibility},
+}
+
+// amenityAliases maps lowercased provider names onto catalog slugs
+var amenityAliases = map[string]string{
+	"free wifi":                      "wifi",
+	"wifi in all areas":              "wifi",
+	"wifi":                           "wifi",
+	"wi-fi":                          "wifi",
+	"internet":                       "wifi",
+	"parking":                        "parking",
+	"parking on site":                "parking",
+	"free parking":                   "parking",
+	"private parking":                "private-parking",
+	"swimming pool":                  "swimming-pool",
+	"1 swimming pool":                "swimming-pool",
+	"indoor swimming pool":           "swimming-pool",
+	"outdoor swimming pool":          "swimming-pool",
+	"fitness center":                 "fitness-center",
+	"fitness centre":                 "fitness-center",
+	"gym":                            "fitness-center",
+	"spa and wellness centre":        "spa",
+	"spa":                            "spa",
+	"restaurant":                     "restaurant",
+	"bar":                            "bar",
+	"good breakfast":                 "breakfast",
+	"breakfast":                      "breakfast",
+	"room service":                   "room-service",
+	"airport shuttle":                "airport-shuttle",
+	"24-hour front desk":             "24-hour-front-desk",
+	"family rooms":                   "family-rooms",
+	"non-smoking rooms":              "non-smoking-rooms",
+	"air conditioning":               "air-conditioning",
+	"facilities for disabled guests": "accessible-facilities",
+	"elevator":                       "elevator",
+	"lift":                           "elevator",
+}
+
+// amenityCategoryKeywords guess the category of amenities missing from the catalog
+var amenityCategoryKeywords = []struct {
+	Keyword  string
+	Category string
+}{
+	{"wifi", AmenityCategoryInternet},
+	{"internet", AmenityCategoryInternet},
+	{"parking", AmenityCategoryParking},
+	{"pool", AmenityCategoryWellness},
+	{"spa", AmenityCategoryWellness},
+	{"sauna", AmenityCategoryWellness},
+	{"fitness", AmenityCategoryWellness},
+	{"restaurant", AmenityCategoryFoodDrink},
+	{"bar", AmenityCategoryFoodDrink},
+	{"breakfast", AmenityCategoryFoodDrink},
+	{"kitchen", AmenityCategoryFoodDrink},
+	{"disabled", AmenityCategoryAccessibility},
+	{"wheelchair", AmenityCategoryAccessibility},
+	{"room", AmenityCategoryRooms},
+	{"desk", AmenityCategoryServices},
+	{"shuttle", AmenityCategoryServices},
+	{"service", AmenityCategoryServices},
+}
+
+var (
+	amenitySpace   = regexp.MustCompile(`\s+`)
+	amenityNonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+	// AmenitySlugPattern matches a valid amenity slug
+	AmenitySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// NormalizeAmenity maps a provider facility name onto its canonical amenity
+func NormalizeAmenity(raw string) Amenity {
+	name := strings.TrimSpace(amenitySpace.ReplaceAllString(raw, " "))
+	key := strings.ToLower(name)
+
+	slug, ok := amenityAliases[key]
+	if !ok {
+		slug = strings.Trim(amenityNonSlug.ReplaceAllString(key, "-"), "-")
+	}
+	if canonical, ok := amenityCatalog[slug]; ok {
+		return Amenity{Slug: slug, Name: canonical.Name, Category: canonical.Category}
+	}
+
+	category := AmenityCategoryGeneral
+	for _, rule := range amenityCategoryKeywords {
+		if strings.Contains(key, rule.Keyword) {
+			category = rule.Category
+			break
+		}
+	}
+	return Amenity{Slug: slug, Name: name, Category: category}
+}
+
+// NormalizeAmenities normalizes names and drops duplicates and blanks, keeping first-seen order
+func NormalizeAmenities(names []string) []Amenity {
+	amenities := []Amenity{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		amenity := NormalizeAmenity(name)
+		if amenity.Slug == "" || seen[amenity.Slug] {
+			continue
+		}
+		seen[amenity.Slug] = true
+		amenities = append(amenities, amenity)
+	}
+	return amenities
+}
+
+// AmenityList is the amenities of a listing. It also decodes the legacy forms found in
+// older data/RentalProperty.json files: a JSON-encoded string or a plain array of names.
+type AmenityList []Amenity
+
+func (l *AmenityList) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err == nil {
+		data = []byte(encoded)
+		if strings.TrimSpace(encoded) == "" {
+			data = []byte("[]")
+		}
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err == nil {
+		*l = NormalizeAmenities(names)
+		return nil
+	}
+
+	var amenities []Amenity
+	if err := json.Unmarshal(data, &amenities); err != nil {
+		return err
+	}
+	*l = amenities
+	return nil
+}
+
+// Names returns the display names of the amenities
+func (l AmenityList) Names() []string {
+	names := make([]string, len(l))
+	for i, amenity := range l {
+		names[i] = amenity.Name
+	}
+	return names
+}
+
+func init() {
+	orm.RegisterModel(new(Amenity), new(PropertyAmenity))
+}
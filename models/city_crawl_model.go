@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Status values of a CityCrawlState
+const (
+	CrawlStatusRunning   = "running"
+	CrawlStatusFailed    = "failed"
+	CrawlStatusCompleted = "completed"
+)
+
+// CityCrawlState is the persisted cursor of the alphabetical city crawl
+type CityCrawlState struct {
+	Id             int            `orm:"column(id);auto" json:"-"`
+	Name           string         `orm:"column(name);size(64);unique" json:"name"`
+	Status         string         `orm:"column(status);size(16)" json:"status"`
+	LastQuery      string         `orm:"column(last_query);size(16)" json:"lastQuery"`
+	LastError      string         `orm:"column(last_error);type(text)" json:"lastError,omitempty"`
+	QueryCountsRaw string         `orm:"column(query_counts);type(text)" json:"-"`
+	QueryCounts    map[string]int `orm:"-" json:"queryCounts"`
+	StartedAt      time.Time      `orm:"column(started_at);type(datetime)" json:"startedAt"`
+	UpdatedAt      time.Time      `orm:"column(updated_at);type(datetime)" json:"updatedAt"`
+	CompletedAt    *time.Time     `orm:"column(completed_at);type(datetime);null" json:"completedAt,omitempty"`
+}
+
+func (s *CityCrawlState) TableName() string {
+	return "city_crawl_state"
+}
+
+func init() {
+	orm.RegisterModel(new(CityCrawlState))
+}
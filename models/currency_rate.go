@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// CurrencyRate is what one unit of the anchor currency of the loaded rates file buys in
+// Currency. Only ratios between rows matter, so any anchor works as long as one file set
+// them all.
+type CurrencyRate struct {
+	Currency  string    `orm:"column(currency);pk;size(3)" json:"currency"`
+	Rate      float64   `orm:"column(rate)" json:"rate"`
+	UpdatedAt time.Time `orm:"column(updated_at);type(datetime)" json:"updatedAt"`
+}
+
+func (r *CurrencyRate) TableName() string {
+	return "currency_rate"
+}
+
+func init() {
+	orm.RegisterModel(new(CurrencyRate))
+}
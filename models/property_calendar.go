@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// CalendarNight is the sampled availability of one property for a one-night stay. A night
+// is sampled again by every crawl that covers it; FetchedAt tells how fresh it is.
+type CalendarNight struct {
+	ID         int64 `orm:"column(id);auto" json:"-"`
+	PropertyID int64 `orm:"column(property_id)" json:"propertyId"`
+	// Night is the check-in date such as 2026-11-17
+	Night     string `orm:"column(night)" json:"date"`
+	Available bool   `orm:"column(available)" json:"available"`
+	// Price is the cheapest offer for the night, nil when the property is not available or
+	// the provider did not price it
+	Price    *float64 `orm:"column(price);null" json:"price"`
+	Currency string   `orm:"column(currency);size(3)" json:"currency,omitempty"`
+	// Unconverted is set when no rate converts Price to the currency asked for
+	Unconverted bool `orm:"-" json:"unconverted,omitempty"`
+	// MaxOccupancy is how many guests the largest room offered for the night sleeps
+	MaxOccupancy int       `orm:"column(max_occupancy)" json:"maxOccupancy"`
+	FetchedAt    time.Time `orm:"column(fetched_at);type(datetime)" json:"fetchedAt"`
+}
+
+// StayQuote is the total price of a stay at one property, attached to the listings of a
+// list request with check_in and check_out
+type StayQuote struct {
+	CheckIn  string `json:"checkIn"`
+	CheckOut string `json:"checkOut"`
+	Nights   int    `json:"nights"`
+	// Total is nil when a night of the stay has no price
+	Total    *float64 `json:"total"`
+	Currency string   `json:"currency,omitempty"`
+	// Unconverted is set when no rate converts Total to the currency asked for
+	Unconverted bool `json:"unconverted,omitempty"`
+	// Source is "calendar" when the stored nights answered and "live" for a provider lookup
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+func (n *CalendarNight) TableName() string {
+	return "property_calendar"
+}
+
+func init() {
+	orm.RegisterModel(new(CalendarNight))
+}
@@ -4,10 +4,27 @@ type PropertyDetail struct {
     HotelID              int      `json:"hotel_id"`
     CityID               string   `json:"city_id"`
     PropertyType         string   `json:"property_type"`
+    // Bedrooms, Bathrooms and MaxOccupancy summarize Units with SummarizeUnits
     Bedrooms             int      `json:"bedrooms"`
     Bathrooms            int      `json:"bathrooms"`
+    MaxOccupancy         int      `json:"max_occupancy,omitempty"`
+    Units                []PropertyUnit `json:"units,omitempty"`
     Amenities            []string `json:"amenities"`
     Description          string   `json:"description"`
     Address              string   `json:"address"`
     HotelName            string   `json:"hotel_name"`
+    // URL is the Booking page of the property; its path holds the slug the photo endpoint takes
+    URL                  string   `json:"url,omitempty"`
+    City                 string   `json:"city,omitempty"`
+    Zip                  string   `json:"zip,omitempty"`
+    CountryCode          string   `json:"country_code,omitempty"`
+    Latitude             float64  `json:"latitude,omitempty"`
+    Longitude            float64  `json:"longitude,omitempty"`
+    // StarRating is 0 for properties without an official rating
+    StarRating           float64  `json:"star_rating,omitempty"`
+    CheckInFrom          string   `json:"check_in_from,omitempty"`
+    CheckInUntil         string   `json:"check_in_until,omitempty"`
+    CheckOutFrom         string   `json:"check_out_from,omitempty"`
+    CheckOutUntil        string   `json:"check_out_until,omitempty"`
+    Policies             []string `json:"policies,omitempty"`
 }
@@ -0,0 +1,35 @@
+package models
+
+// PropertyReview is the review summary of a property document
+type PropertyReview struct {
+	Score float64 `json:"score"`
+	Count int     `json:"count"`
+	Word  string  `json:"word"`
+}
+
+// PropertyImages is the image set of a property document
+type PropertyImages struct {
+	Type string   `json:"type"`
+	Urls []string `json:"urls"`
+}
+
+// PropertyDocument joins rental_property, property_details and location for one listing
+type PropertyDocument struct {
+	PropertyID   int64           `json:"propertyId"`
+	Name         string          `json:"name"`
+	PropertyType string          `json:"propertyType"`
+	Bedrooms     int             `json:"bedrooms"`
+	Bathrooms    int             `json:"bathrooms"`
+	MaxOccupancy int             `json:"maxOccupancy"`
+	Amenities    AmenityList     `json:"amenities"`
+	CityID       string          `json:"cityId"`
+	CityName     string          `json:"cityName"`
+	Country      string          `json:"country"`
+	Description  string          `json:"description"`
+	Review       *PropertyReview `json:"review"`
+	Images       *PropertyImages `json:"images"`
+	Photos       []PropertyPhoto `json:"photos"`
+	Units        []PropertyUnit  `json:"units"`
+	// Price is the latest quote, nil when no search priced the property yet
+	Price *PropertyPrice `json:"price"`
+}
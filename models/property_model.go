@@ -1,9 +1,14 @@
 package models
 
+import "time"
+
 type PropertyResponse struct {
-    Data []Property `json:"data"`
+    // Status is false when the provider could not run the search; nil when it was not sent
+    Status *bool      `json:"status,omitempty"`
+    Data   []Property `json:"data"`
     Meta struct {
-        // Add any metadata fields if needed
+        // TotalPages is how many pages the search has; 0 when the provider does not say
+        TotalPages int `json:"totalPages"`
     } `json:"meta"`
 }
 
@@ -12,7 +17,52 @@ type Property struct {
     HotelID          int     `json:"id"`
     ReviewScoreWord  string  `json:"reviewScoreWord"`
     ReviewScore      float64 `json:"reviewScore"`
-    AmountRounded    string  `json:"priceBreakdown.grossPrice.amountRounded"`
+    // PriceBreakdown is the total price of the searched stay
+    PriceBreakdown   *PriceBreakdown `json:"priceBreakdown,omitempty"`
     ReviewCount      int     `json:"reviewCount"`
     CityID           string  `json:"cityId,omitempty"`
-}
\ No newline at end of file
+    // QuotedCheckIn, QuotedCheckOut and QuotedAt are the stay dates and time of the search
+    // that returned PriceBreakdown; the search payload does not echo them
+    QuotedCheckIn    string     `json:"quotedCheckIn,omitempty"`
+    QuotedCheckOut   string     `json:"quotedCheckOut,omitempty"`
+    QuotedAt         *time.Time `json:"quotedAt,omitempty"`
+}
+
+// PriceBreakdown is the priceBreakdown object of a stays/search result
+type PriceBreakdown struct {
+    GrossPrice         *Money `json:"grossPrice,omitempty"`
+    // StrikethroughPrice is the price before a discount, absent when there is none
+    StrikethroughPrice *Money `json:"strikethroughPrice,omitempty"`
+    // ExcludedPrice is the taxes and charges payable on top of GrossPrice
+    ExcludedPrice      *Money `json:"excludedPrice,omitempty"`
+}
+
+type Money struct {
+    Currency      string  `json:"currency"`
+    Value         float64 `json:"value"`
+    AmountRounded string  `json:"amountRounded,omitempty"`
+}
+
+// Quote returns the price row of a search result, or nil when the search carried no price
+func (p Property) Quote() *PropertyPrice {
+    if p.PriceBreakdown == nil || p.PriceBreakdown.GrossPrice == nil || p.QuotedCheckIn == "" || p.QuotedAt == nil {
+        return nil
+    }
+    gross := p.PriceBreakdown.GrossPrice
+    price := &PropertyPrice{
+        PropertyID: int64(p.HotelID),
+        CheckIn:    p.QuotedCheckIn,
+        CheckOut:   p.QuotedCheckOut,
+        FetchedAt:  *p.QuotedAt,
+        Currency:   gross.Currency,
+        Gross:      gross.Value,
+    }
+    if strikethrough := p.PriceBreakdown.StrikethroughPrice; strikethrough != nil && strikethrough.Value > 0 {
+        value := strikethrough.Value
+        price.Strikethrough = &value
+    }
+    if excluded := p.PriceBreakdown.ExcludedPrice; excluded != nil {
+        price.Taxes = excluded.Value
+    }
+    return price
+}
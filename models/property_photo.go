@@ -0,0 +1,34 @@
+package models
+
+import (
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Kinds of PropertyPhoto
+const (
+	PhotoKindHotel = "hotel"
+	PhotoKindRoom  = "room"
+)
+
+// PropertyPhoto is one photo of a property with its metadata. Room photos carry the
+// room they show.
+type PropertyPhoto struct {
+	ID         int64  `orm:"column(id);auto" json:"-"`
+	PropertyID int64  `orm:"column(property_id);index" json:"propertyId"`
+	PhotoID    int64  `orm:"column(photo_id)" json:"photoId"`
+	Kind       string `orm:"column(kind);size(16)" json:"kind"`
+	RoomID     int64  `orm:"column(room_id)" json:"roomId,omitempty"`
+	RoomName   string `orm:"column(room_name);size(255)" json:"roomName,omitempty"`
+	Caption    string `orm:"column(caption);type(text)" json:"caption,omitempty"`
+	LargeURL   string `orm:"column(large_url);type(text)" json:"largeUrl"`
+	ThumbURL   string `orm:"column(thumb_url);type(text)" json:"thumbUrl"`
+	Position   int    `orm:"column(position)" json:"position"`
+}
+
+func (p *PropertyPhoto) TableName() string {
+	return "property_photo"
+}
+
+func init() {
+	orm.RegisterModel(new(PropertyPhoto))
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// PropertyPrice is the total price a search quoted for one stay of a property. Every fetch
+// adds a row, so the rows of one check-in date chart how its price moved.
+type PropertyPrice struct {
+	ID         int64 `orm:"column(id);auto" json:"-"`
+	PropertyID int64 `orm:"column(property_id)" json:"propertyId"`
+	// CheckIn and CheckOut are dates such as 2026-11-17
+	CheckIn   string    `orm:"column(check_in)" json:"checkIn"`
+	CheckOut  string    `orm:"column(check_out)" json:"checkOut"`
+	FetchedAt time.Time `orm:"column(fetched_at);type(datetime)" json:"fetchedAt"`
+	Currency  string    `orm:"column(currency);size(3)" json:"currency"`
+	// Gross is the price shown, taxes excluded from it are in Taxes
+	Gross float64 `orm:"column(gross)" json:"gross"`
+	// Strikethrough is the price before a discount, nil when the stay was not discounted
+	Strikethrough *float64 `orm:"column(strikethrough);null" json:"strikethrough"`
+	Taxes         float64  `orm:"column(taxes)" json:"taxes"`
+	// QuotedCurrency is the currency of the search when the amounts were converted to Currency
+	QuotedCurrency string `orm:"-" json:"quotedCurrency,omitempty"`
+	// Unconverted is set when no rate converts the quote to the currency asked for, so the
+	// amounts stay in Currency
+	Unconverted bool `orm:"-" json:"unconverted,omitempty"`
+}
+
+func (p *PropertyPrice) TableName() string {
+	return "property_price"
+}
+
+func init() {
+	orm.RegisterModel(new(PropertyPrice))
+}
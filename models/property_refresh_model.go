@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Status values of a PropertyRefresh
+const (
+	RefreshStatusSucceeded = "succeeded"
+	RefreshStatusFailed    = "failed"
+)
+
+// PropertyRefresh records one incremental sync of a city's listings
+type PropertyRefresh struct {
+	Id         int64     `orm:"column(id);auto" json:"id"`
+	CityID     string    `orm:"column(city_id);size(255);index" json:"cityId"`
+	CityName   string    `orm:"column(city_name);size(128)" json:"cityName"`
+	Status     string    `orm:"column(status);size(16)" json:"status"`
+	Error      string    `orm:"column(error);type(text)" json:"error,omitempty"`
+	Fetched    int       `orm:"column(fetched)" json:"fetched"`
+	Inserted   int       `orm:"column(inserted)" json:"inserted"`
+	Updated    int       `orm:"column(updated)" json:"updated"`
+	Deleted    int       `orm:"column(deleted)" json:"deleted"`
+	Missed     int       `orm:"column(missed)" json:"missed"`
+	Unchanged  int       `orm:"column(unchanged)" json:"unchanged"`
+	StartedAt  time.Time `orm:"column(started_at);type(datetime)" json:"startedAt"`
+	FinishedAt time.Time `orm:"column(finished_at);type(datetime)" json:"finishedAt"`
+}
+
+func (r *PropertyRefresh) TableName() string {
+	return "property_refresh"
+}
+
+func init() {
+	orm.RegisterModel(new(PropertyRefresh))
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Bathroom types of PropertyUnit; empty when the payload does not say
+const (
+	BathroomPrivate = "private"
+	BathroomShared  = "shared"
+)
+
+// PropertyUnit is one bookable room or apartment of a property, parsed from the rooms and
+// block lists of stays/detail
+type PropertyUnit struct {
+	ID         int64  `orm:"column(id);auto" json:"-"`
+	PropertyID int64  `orm:"column(property_id);index" json:"propertyId"`
+	RoomID     int64  `orm:"column(room_id)" json:"roomId"`
+	Name       string `orm:"column(name);size(255)" json:"name"`
+	Bedrooms   int    `orm:"column(bedrooms)" json:"bedrooms"`
+	Bathrooms  int    `orm:"column(bathrooms)" json:"bathrooms"`
+	// BathroomType is BathroomPrivate, BathroomShared or empty
+	BathroomType string `orm:"column(bathroom_type);size(16)" json:"bathroomType,omitempty"`
+	// BedConfiguration reads like "1 large double bed"; alternatives are joined with " or "
+	BedConfiguration string  `orm:"column(bed_configuration);type(text)" json:"bedConfiguration,omitempty"`
+	MaxOccupancy     int     `orm:"column(max_occupancy)" json:"maxOccupancy"`
+	SizeM2           float64 `orm:"column(size_m2)" json:"sizeM2,omitempty"`
+	Position         int     `orm:"column(position)" json:"position"`
+}
+
+func (u *PropertyUnit) TableName() string {
+	return "property_unit"
+}
+
+// UnitSummary is what a listing shows of its units
+type UnitSummary struct {
+	Bedrooms     int
+	Bathrooms    int
+	MaxOccupancy int
+}
+
+// SummarizeUnits returns the largest unit of a property. A hotel offering seventy double
+// rooms is a one-bedroom listing, not a seventy-bedroom one.
+func SummarizeUnits(units []PropertyUnit) UnitSummary {
+	var summary UnitSummary
+	for _, unit := range units {
+		summary.Bedrooms = max(summary.Bedrooms, unit.Bedrooms)
+		summary.Bathrooms = max(summary.Bathrooms, unit.Bathrooms)
+		summary.MaxOccupancy = max(summary.MaxOccupancy, unit.MaxOccupancy)
+	}
+	return summary
+}
+
+func init() {
+	orm.RegisterModel(new(PropertyUnit))
+}
@@ -5,8 +5,10 @@ type PropertyImageResponse struct {
 }
 
 type PropertyImage struct {
-    PropertyID   int      `json:"property_id"`
-    PropertyName string   `json:"property_name"`
-    ImageType    string   `json:"image_type"`
-    ImageURLs    []string `json:"image_urls"`
+    PropertyID   int             `json:"property_id"`
+    PropertyName string          `json:"property_name"`
+    ImageType    string          `json:"image_type"`
+    ImageURLs    []string        `json:"image_urls"`
+    // Photos carries the metadata of each photo in ImageURLs
+    Photos       []PropertyPhoto `json:"photos,omitempty"`
 }
\ No newline at end of file
@@ -1,18 +1,42 @@
 package models
 
 import (
+    "time"
+
     "github.com/beego/beego/v2/client/orm"
 )
 
 type RentalProperty struct {
     ID            int64    `orm:"column(id);auto" json:"-"`
     CityID        string   `orm:"column(city_id)" json:"cityId"`
-    PropertyID    int64    `orm:"column(property_id)" json:"propertyId"`
+    PropertyID    int64    `orm:"column(property_id);unique" json:"propertyId"`
     Name          string   `orm:"column(name)" json:"name"`
     PropertyType  string   `orm:"column(property_type)" json:"propertyType"`
     Bedrooms      int      `orm:"column(bedrooms)" json:"bedrooms"`
     Bathrooms     int      `orm:"column(bathrooms)" json:"bathrooms"`
-    Amenities     string   `orm:"column(amenities);type(text)" json:"amenities"`
+    // Bedrooms, Bathrooms and MaxOccupancy are those of the largest unit in property_unit
+    MaxOccupancy  int      `orm:"column(max_occupancy)" json:"maxOccupancy"`
+    // Amenities live in the amenity and property_amenity tables
+    Amenities     AmenityList `orm:"-" json:"amenities"`
+    // Price is the latest quote from property_price, attached by the list endpoint
+    Price         *PropertyPrice `orm:"-" json:"price,omitempty"`
+    // Stay is the total price of the requested stay, attached when the list is searched by dates
+    Stay          *StayQuote `orm:"-" json:"stay,omitempty"`
+    // DeletedAt is set when refreshes no longer find the property upstream
+    DeletedAt     *time.Time `orm:"column(deleted_at);type(datetime);null" json:"-"`
+    // MissedRefreshes counts the refreshes in a row whose search did not return the property
+    MissedRefreshes int      `orm:"column(missed_refreshes);default(0)" json:"-"`
+}
+
+// TableIndex declares the indexes backing the filters and sorts of /v1/property/list
+func (p *RentalProperty) TableIndex() [][]string {
+    return [][]string{
+        {"CityID"},
+        {"PropertyType"},
+        {"Bedrooms"},
+        {"Bathrooms"},
+        {"Name"},
+    }
 }
 
 func init() {
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Reservation statuses. Only confirmed reservations hold their nights; the database
+// rejects a confirmed reservation overlapping another one of the same property.
+const (
+	ReservationPending   = "pending"
+	ReservationConfirmed = "confirmed"
+	ReservationCancelled = "cancelled"
+)
+
+// Reservation is a booking of one property for a stay
+type Reservation struct {
+	ID         int64  `orm:"column(id);auto" json:"id"`
+	PropertyID int64  `orm:"column(property_id)" json:"propertyId"`
+	GuestName  string `orm:"column(guest_name)" json:"guestName"`
+	GuestEmail string `orm:"column(guest_email)" json:"guestEmail"`
+	Guests     int    `orm:"column(guests)" json:"guests"`
+	// CheckIn and CheckOut are dates such as 2026-11-03; the guest leaves on CheckOut
+	CheckIn  string `orm:"column(check_in)" json:"checkIn"`
+	CheckOut string `orm:"column(check_out)" json:"checkOut"`
+	Status   string `orm:"column(status);size(16)" json:"status"`
+	// TotalPrice is the stay price when the reservation was made, nil when it was not known
+	TotalPrice  *float64   `orm:"column(total_price);null" json:"totalPrice"`
+	Currency    string     `orm:"column(currency);size(3)" json:"currency,omitempty"`
+	CreatedAt   time.Time  `orm:"column(created_at);type(datetime)" json:"createdAt"`
+	UpdatedAt   time.Time  `orm:"column(updated_at);type(datetime)" json:"updatedAt"`
+	CancelledAt *time.Time `orm:"column(cancelled_at);type(datetime);null" json:"cancelledAt,omitempty"`
+}
+
+func (r *Reservation) TableName() string {
+	return "reservation"
+}
+
+func init() {
+	orm.RegisterModel(new(Reservation))
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StayDetailResponse is the stays/detail payload. Fields tagged schema:"required" are
+// reported as missing when a response lacks them.
+type StayDetailResponse struct {
+	Status  bool       `json:"status"`
+	Message string     `json:"message"`
+	Data    StayDetail `json:"data" schema:"required"`
+	// Drift is how the payload differed from these types; set by the provider that decoded it
+	Drift SchemaDrift `json:"-"`
+}
+
+// StayDetail is the property part of a stays/detail response
+type StayDetail struct {
+	HotelID   int    `json:"hotel_id" schema:"required"`
+	HotelName string `json:"hotel_name" schema:"required"`
+	// URL is the Booking page of the property; its path holds the slug the photo endpoint takes
+	URL                   string  `json:"url" schema:"required"`
+	AccommodationTypeName string  `json:"accommodation_type_name" schema:"required"`
+	Address               string  `json:"address"`
+	City                  string  `json:"city"`
+	Zip                   string  `json:"zip"`
+	CountryCode           string  `json:"countrycode"`
+	Latitude              float64 `json:"latitude"`
+	Longitude             float64 `json:"longitude"`
+	// Class is the star rating, 0 when the property has none
+	Class float64 `json:"class"`
+	// BlockCount counts the offers for the stay dates, not rooms
+	BlockCount int `json:"block_count"`
+	// SoldOut is 1 when nothing is left for the stay dates
+	SoldOut int `json:"soldout"`
+	// ProductPriceBreakdown is the price of the cheapest offer for the stay dates
+	ProductPriceBreakdown StayPriceBreakdown `json:"product_price_breakdown"`
+
+	Checkin         StayTimeWindow      `json:"checkin"`
+	Checkout        StayTimeWindow      `json:"checkout"`
+	Facilities      []StayFacility      `json:"facilities"`
+	FacilitiesBlock StayFacilitiesBlock `json:"facilities_block"`
+	// ImportantInformation holds the house policies, one phrase each
+	ImportantInformation []StayPolicy `json:"hotel_important_information_with_codes"`
+	// Rooms describes each room type by room ID; Block lists the offers for the stay dates,
+	// several per room type when it has more than one rate
+	Rooms map[string]StayRoom `json:"rooms"`
+	Block []StayBlock         `json:"block"`
+}
+
+// StayRoom is a room type of a property
+type StayRoom struct {
+	Description string `json:"description"`
+	// BedroomCount and BathroomCount are only sent for apartments and holiday homes
+	BedroomCount             int                    `json:"bedroom_count"`
+	BathroomCount            int                    `json:"bathroom_count"`
+	BedConfigurations        []StayBedConfiguration `json:"bed_configurations"`
+	PrivateBathroomHighlight StayHighlight          `json:"private_bathroom_highlight"`
+	Facilities               []StayRoomFacility     `json:"facilities"`
+}
+
+// StayBedConfiguration is one way the beds of a room are set up; rooms may offer several
+type StayBedConfiguration struct {
+	BedTypes []StayBedType `json:"bed_types"`
+}
+
+type StayBedType struct {
+	Name          string `json:"name"`
+	NameWithCount string `json:"name_with_count"`
+	Count         int    `json:"count"`
+	BedType       int    `json:"bed_type"`
+	Description   string `json:"description"`
+}
+
+type StayHighlight struct {
+	HasHighlight int    `json:"has_highlight"`
+	Text         string `json:"text"`
+}
+
+type StayRoomFacility struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// StayBlock is one offer of a room type for the stay dates
+type StayBlock struct {
+	BlockID         string  `json:"block_id"`
+	RoomID          int64   `json:"room_id"`
+	RoomName        string  `json:"room_name"`
+	Name            string  `json:"name"`
+	MaxOccupancy    int     `json:"max_occupancy"`
+	NrAdults        int     `json:"nr_adults"`
+	NrChildren      int     `json:"nr_children"`
+	RoomSurfaceInM2 float64 `json:"room_surface_in_m2"`
+}
+
+type StayPriceBreakdown struct {
+	GrossAmount StayAmount `json:"gross_amount"`
+	// ExcludedAmount is the taxes and charges payable on top of GrossAmount
+	ExcludedAmount StayAmount `json:"excluded_amount"`
+}
+
+type StayAmount struct {
+	Currency string  `json:"currency"`
+	Value    float64 `json:"value"`
+}
+
+// StayTimeWindow is a check-in or check-out window such as 15:00 to 23:00; either end may be empty
+type StayTimeWindow struct {
+	From  string `json:"from"`
+	Until string `json:"until"`
+}
+
+type StayFacility struct {
+	Name string `json:"name"`
+	Icon string `json:"icon"`
+}
+
+type StayFacilitiesBlock struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Facilities []StayFacility `json:"facilities"`
+}
+
+type StayPolicy struct {
+	Phrase         string `json:"phrase"`
+	SentenceID     int    `json:"sentence_id"`
+	ExecutingPhase int    `json:"executing_phase"`
+}
+
+// SchemaDrift lists the JSON paths where a response differed from the type it was decoded
+// into, e.g. "data.facilities[].name"
+type SchemaDrift struct {
+	// Unknown fields are in the response but not in the type
+	Unknown []string `json:"unknown,omitempty"`
+	// Missing fields are required by the type but absent or null in the response
+	Missing []string `json:"missing,omitempty"`
+	// Mismatched fields have a JSON type the Go field cannot hold; they decode as zero values
+	Mismatched []string `json:"mismatched,omitempty"`
+}
+
+// Empty reports whether the response matched its type
+func (d SchemaDrift) Empty() bool {
+	return len(d.Unknown) == 0 && len(d.Missing) == 0 && len(d.Mismatched) == 0
+}
+
+// Breaking reports whether fields the services read are missing or unreadable; unknown
+// fields alone do not lose data
+func (d SchemaDrift) Breaking() bool {
+	return len(d.Missing) > 0 || len(d.Mismatched) > 0
+}
+
+func (d SchemaDrift) String() string {
+	var parts []string
+	if len(d.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(d.Missing, ", "))
+	}
+	if len(d.Mismatched) > 0 {
+		parts = append(parts, "mismatched "+strings.Join(d.Mismatched, ", "))
+	}
+	if len(d.Unknown) > 0 {
+		parts = append(parts, fmt.Sprintf("%d unknown fields", len(d.Unknown)))
+	}
+	if len(parts) == 0 {
+		return "no drift"
+	}
+	return strings.Join(parts, "; ")
+}
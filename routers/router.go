@@ -10,6 +10,7 @@ import (
 func init() {
 	fmt.Printf("Registering routes...\n")
 	beego.Router("/v1/city", &controllers.CityController{})
+	beego.Router("/v1/city/progress", &controllers.CityController{}, "get:Progress")
 	beego.Router("/v1/properties", &controllers.PropertyController{})
 	beego.Router("/v1/property-details", &controllers.PropertyDetailController{})
 	beego.Router("/v1/property-description", &controllers.PropertyDescriptionController{})
@@ -21,8 +22,25 @@ func init() {
 	beego.Router("/generate-property-details", &controllers.PropertyDetailsControllerJSON{}, "get:Get")
 	// beego.Router("/v1/property/list", &controllers.PropertyListController{})
 	// In your main.go or router configuration file
-	beego.Router("/v1/property/list", &controllers.RentalPropertyController{}, "get:Get;options:Options")
+	beego.Router("/v1/property/list", &controllers.RentalPropertyController{}, "get:List;options:Options")
 	beego.Router("/v1/property/details", &controllers.PropertyDetailControllerDB{})
+	beego.Router("/v1/properties/:propertyId:int", &controllers.PropertyDetailControllerDB{}, "get:Show")
+	beego.Router("/v1/properties/:propertyId:int/prices", &controllers.PropertyDetailControllerDB{}, "get:Prices")
+	beego.Router("/v1/properties/:propertyId:int/availability", &controllers.PropertyDetailControllerDB{}, "get:Availability")
+	beego.Router("/v1/search", &controllers.SearchController{}, "get:Get")
+
+	beego.Router("/v1/jobs", &controllers.JobController{}, "get:List")
+	beego.Router("/v1/jobs/:id", &controllers.JobController{}, "get:Get;delete:Cancel")
+
+	beego.Router("/v1/refresh", &controllers.RefreshController{}, "post:Post")
+	beego.Router("/v1/refresh/history", &controllers.RefreshController{}, "get:History")
+	beego.Router("/v1/refresh/cities", &controllers.RefreshController{}, "get:Cities")
+
+	beego.Router("/v1/cache", &controllers.CacheController{}, "get:Get")
+
+	beego.Router("/v1/reservations", &controllers.ReservationController{}, "post:Post")
+	beego.Router("/v1/reservations/:id:int", &controllers.ReservationController{}, "get:Get;delete:Cancel")
+	beego.Router("/v1/reservations/:id:int/confirm", &controllers.ReservationController{}, "post:Confirm")
 
 
 }
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"backend_rental/models"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/lib/pq"
+)
+
+// AmenityFacet is the number of matching listings that have an amenity
+type AmenityFacet struct {
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+// loadPropertyAmenities returns the amenities of each property, ordered by category and name
+func loadPropertyAmenities(propertyIDs []int64) (map[int64]models.AmenityList, error) {
+	amenities := make(map[int64]models.AmenityList, len(propertyIDs))
+	if len(propertyIDs) == 0 {
+		return amenities, nil
+	}
+
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT pa.property_id, a.slug, a.name, a.category
+		FROM property_amenity pa
+		JOIN amenity a ON a.id = pa.amenity_id
+		WHERE pa.property_id = ANY($1)
+		ORDER BY a.category, a.name`, pq.Array(propertyIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load amenities: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var propertyID int64
+		var amenity models.Amenity
+		if err := rows.Scan(&propertyID, &amenity.Slug, &amenity.Name, &amenity.Category); err != nil {
+			return nil, fmt.Errorf("failed to read amenity: %v", err)
+		}
+		amenities[propertyID] = append(amenities[propertyID], amenity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read amenities: %v", err)
+	}
+	return amenities, nil
+}
+
+// AttachAmenities fills in the Amenities of each property
+func AttachAmenities(properties []models.RentalProperty) error {
+	ids := make([]int64, len(properties))
+	for i, property := range properties {
+		ids[i] = property.PropertyID
+	}
+
+	amenities, err := loadPropertyAmenities(ids)
+	if err != nil {
+		return err
+	}
+	for i := range properties {
+		properties[i].Amenities = amenities[properties[i].PropertyID]
+		if properties[i].Amenities == nil {
+			properties[i].Amenities = models.AmenityList{}
+		}
+	}
+	return nil
+}
+
+// amenityFacets counts the amenities across the given properties, most common first
+func amenityFacets(propertyIDs []int64) ([]AmenityFacet, error) {
+	facets := []AmenityFacet{}
+	if len(propertyIDs) == 0 {
+		return facets, nil
+	}
+
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT a.slug, a.name, a.category, count(*)
+		FROM property_amenity pa
+		JOIN amenity a ON a.id = pa.amenity_id
+		WHERE pa.property_id = ANY($1)
+		GROUP BY a.slug, a.name, a.category
+		ORDER BY count(*) DESC, a.slug`, pq.Array(propertyIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count amenities: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet AmenityFacet
+		if err := rows.Scan(&facet.Slug, &facet.Name, &facet.Category, &facet.Count); err != nil {
+			return nil, fmt.Errorf("failed to read amenity facet: %v", err)
+		}
+		facets = append(facets, facet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read amenity facets: %v", err)
+	}
+	return facets, nil
+}
+
+// amenityFilterSQL is a property_id subquery matching listings that have every slug.
+// FilterRaw takes no bind parameters, so the slugs are looked up first and only the ids of
+// the matching amenity rows reach the SQL; an unknown slug matches no listing.
+func amenityFilterSQL(slugs []string) (string, error) {
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return "", fmt.Errorf("failed to get database connection: %v", err)
+	}
+	rows, err := db.Query(`SELECT id FROM amenity WHERE slug = ANY($1)`, pq.Array(slugs))
+	if err != nil {
+		return "", fmt.Errorf("failed to look up amenities: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return "", fmt.Errorf("failed to read amenity: %v", err)
+		}
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read amenities: %v", err)
+	}
+	if len(ids) < len(slugs) {
+		return "IN (NULL)", nil
+	}
+	return fmt.Sprintf(`IN (SELECT property_id FROM property_amenity
+		WHERE amenity_id IN (%s)
+		GROUP BY property_id
+		HAVING count(DISTINCT amenity_id) = %d)`, strings.Join(ids, ", "), len(ids)), nil
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+	"github.com/lib/pq"
+)
+
+// JobKindAvailabilityCrawl is the job kind of the scheduled availability crawl
+const JobKindAvailabilityCrawl = "availability-crawl"
+
+// Availability window used when [availability] is not configured
+const (
+	DefaultAvailabilityNights      = 90
+	DefaultAvailabilityStartOffset = 1
+	DefaultAvailabilityStep        = 1
+	// MaxAvailabilityRange caps the nights one availability request returns
+	MaxAvailabilityRange = 366
+)
+
+// AvailabilityService samples one-night stays of each property across a window of upcoming
+// nights and stores their availability and price in property_calendar. Every sampled night
+// is one stays/detail call.
+type AvailabilityService struct {
+	Provider       utils.ListingsProvider
+	PropertiesPath string
+	Progress       ProgressReporter
+	Selection      Selection
+	Workers        int
+	// Nights is the length of the window, starting StartOffset days from today
+	Nights      int
+	StartOffset int
+	// Step samples every Step-th night of the window; nights in between are not stored
+	Step int
+
+	now func() time.Time
+	// save stores sampled nights; nil saves them in property_calendar
+	save func(nights []models.CalendarNight) error
+}
+
+// NewAvailabilityService reads the window from availability::nights, start_offset and step
+func NewAvailabilityService() *AvailabilityService {
+	return &AvailabilityService{
+		Provider:       utils.NewListingsProvider(),
+		PropertiesPath: PropertiesFilePath,
+		Workers:        IngestWorkers(),
+		Nights:         beego.AppConfig.DefaultInt("availability::nights", DefaultAvailabilityNights),
+		StartOffset:    beego.AppConfig.DefaultInt("availability::start_offset", DefaultAvailabilityStartOffset),
+		Step:           beego.AppConfig.DefaultInt("availability::step", DefaultAvailabilityStep),
+		now:            time.Now,
+	}
+}
+
+// WindowNights returns the check-in dates the crawl samples
+func (s *AvailabilityService) WindowNights() []string {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	step := max(s.Step, 1)
+	first := now().AddDate(0, 0, s.StartOffset)
+	var nights []string
+	for i := 0; i < s.Nights; i += step {
+		nights = append(nights, first.AddDate(0, 0, i).Format("2006-01-02"))
+	}
+	return nights
+}
+
+func (s *AvailabilityService) LoadProperties() ([]models.Property, error) {
+	data, err := os.ReadFile(s.PropertiesPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading properties file: %v", err)
+	}
+
+	var properties []models.Property
+	if err := json.Unmarshal(data, &properties); err != nil {
+		return nil, fmt.Errorf("error unmarshaling properties data: %v", err)
+	}
+	return properties, nil
+}
+
+// SelectProperties returns the properties the next Crawl covers; with Missing set, those
+// whose every window night is already sampled are skipped
+func (s *AvailabilityService) SelectProperties() ([]models.Property, error) {
+	properties, err := s.LoadProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	var done map[int]bool
+	if s.Selection.Missing {
+		if done, err = s.sampledProperties(s.WindowNights()); err != nil {
+			return nil, err
+		}
+	}
+	return s.Selection.SelectProperties(properties, done), nil
+}
+
+// sampledProperties returns the properties with a stored sample for every one of nights
+func (s *AvailabilityService) sampledProperties(nights []string) (map[int]bool, error) {
+	done := map[int]bool{}
+	if len(nights) == 0 {
+		return done, nil
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+	rows, err := db.Query(`
+		SELECT property_id FROM property_calendar
+		WHERE night = ANY($1::date[])
+		GROUP BY property_id
+		HAVING count(*) = $2`, pq.Array(nights), len(nights))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sampled properties: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read sampled property: %v", err)
+		}
+		done[id] = true
+	}
+	return done, rows.Err()
+}
+
+// availabilitySample is one property and night of a crawl
+type availabilitySample struct {
+	Property models.Property
+	Night    string
+}
+
+// Crawl samples the window for the selected properties and stores the nights; it returns
+// how many nights were stored. Nights sampled before the crawl is cancelled or aborted are
+// stored too.
+func (s *AvailabilityService) Crawl(ctx context.Context) (int, error) {
+	// Availability changes by the hour, and cached details are not keyed on their dates
+	utils.SetCacheMode(s.Provider, utils.CacheOff)
+
+	properties, err := s.SelectProperties()
+	if err != nil {
+		return 0, err
+	}
+	nights := s.WindowNights()
+
+	samples := make([]availabilitySample, 0, len(properties)*len(nights))
+	for _, property := range properties {
+		for _, night := range nights {
+			samples = append(samples, availabilitySample{Property: property, Night: night})
+		}
+	}
+
+	progress := progressOrNoop(s.Progress)
+	progress.SetTotal(len(samples))
+	fmt.Printf("Sampling %d nights from %s for %d properties\n", len(nights), firstOf(nights), len(properties))
+
+	// fetched keeps every sampled night as it arrives, since runPool returns no results once
+	// ctx is cancelled
+	var fetched []models.CalendarNight
+	var mu sync.Mutex
+
+	pool := workerPool{Workers: s.Workers, Budget: s.Selection.Budget, Progress: progress}
+	results, err := runPool(ctx, pool, samples, func(ctx context.Context, sample availabilitySample) (models.CalendarNight, error) {
+		checkOut, err := nextDay(sample.Night)
+		if err != nil {
+			return models.CalendarNight{}, err
+		}
+		response, err := s.Provider.FetchPropertyDetails(ctx, sample.Property.HotelID, sample.Night, checkOut)
+		if err != nil {
+			return models.CalendarNight{}, err
+		}
+		night := calendarNight(int64(sample.Property.HotelID), sample.Night, &response.Data, time.Now())
+		mu.Lock()
+		fetched = append(fetched, night)
+		mu.Unlock()
+		return night, nil
+	})
+	if err != nil {
+		if saveErr := s.saveNights(fetched); saveErr != nil {
+			return 0, fmt.Errorf("availability crawl aborted: %v; the %d nights sampled before were not stored: %v", err, len(fetched), saveErr)
+		}
+		progress.SetCount("nights", len(fetched))
+		return len(fetched), fmt.Errorf("availability crawl aborted after storing %d nights: %v", len(fetched), err)
+	}
+
+	var sampled []models.CalendarNight
+	for i, sample := range samples {
+		if !results[i].Attempted {
+			fmt.Printf("Stopped early: call budget or provider quota exhausted; %d nights left for the next run\n", len(samples)-i)
+			break
+		}
+		if err := results[i].Err; err != nil {
+			fmt.Printf("Error sampling %s (ID: %d) on %s: %v\n", sample.Property.PropertyName, sample.Property.HotelID, sample.Night, err)
+			progress.AddError(fmt.Errorf("property %d night %s: %v", sample.Property.HotelID, sample.Night, err))
+			continue
+		}
+		sampled = append(sampled, results[i].Value)
+	}
+
+	if err := s.saveNights(sampled); err != nil {
+		return 0, err
+	}
+	progress.SetCount("nights", len(sampled))
+	fmt.Printf("Availability stored for %d nights\n", len(sampled))
+	return len(sampled), nil
+}
+
+func (s *AvailabilityService) saveNights(nights []models.CalendarNight) error {
+	if s.save != nil {
+		return s.save(nights)
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %v", err)
+	}
+	return utils.SaveCalendarNights(db, nights)
+}
+
+// calendarNight reads the availability of a one-night stays/detail response. A property
+// is available when the provider offers at least one room and does not report it sold out.
+func calendarNight(propertyID int64, night string, data *models.StayDetail, fetchedAt time.Time) models.CalendarNight {
+	calendar := models.CalendarNight{
+		PropertyID: propertyID,
+		Night:      night,
+		Available:  data.SoldOut == 0 && (len(data.Block) > 0 || data.BlockCount > 0),
+		FetchedAt:  fetchedAt,
+	}
+	if !calendar.Available {
+		return calendar
+	}
+	for _, block := range data.Block {
+		occupancy := block.MaxOccupancy
+		if occupancy == 0 {
+			occupancy = block.NrAdults + block.NrChildren
+		}
+		calendar.MaxOccupancy = max(calendar.MaxOccupancy, occupancy)
+	}
+	if gross := data.ProductPriceBreakdown.GrossAmount; gross.Value > 0 {
+		price := gross.Value
+		calendar.Price = &price
+		calendar.Currency = gross.Currency
+	}
+	return calendar
+}
+
+func nextDay(date string) (string, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q", date)
+	}
+	return day.AddDate(0, 0, 1).Format("2006-01-02"), nil
+}
+
+func firstOf(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return values[0]
+}
+
+// AvailabilityQuery is the night range of /v1/properties/{id}/availability
+type AvailabilityQuery struct {
+	// From and To are the first and last night, both inclusive
+	From     string
+	To       string
+	Currency string
+}
+
+// AvailabilityCalendar is the response of /v1/properties/{id}/availability. Nights never
+// sampled are absent from Data.
+type AvailabilityCalendar struct {
+	PropertyID int64                  `json:"propertyId"`
+	From       string                 `json:"from"`
+	To         string                 `json:"to"`
+	Data       []models.CalendarNight `json:"data"`
+}
+
+// ParseAvailabilityQuery validates from and to, which default to today and 30 nights later
+func ParseAvailabilityQuery(values url.Values, today time.Time) (*AvailabilityQuery, error) {
+	q := &AvailabilityQuery{}
+	var err error
+	if q.From, err = optionalDate(values, "from"); err != nil {
+		return nil, err
+	}
+	if q.To, err = optionalDate(values, "to"); err != nil {
+		return nil, err
+	}
+	if q.Currency, err = ParseCurrency(values); err != nil {
+		return nil, err
+	}
+	if q.From == "" {
+		q.From = today.Format("2006-01-02")
+	}
+	from, _ := time.Parse("2006-01-02", q.From)
+	if q.To == "" {
+		q.To = from.AddDate(0, 0, 29).Format("2006-01-02")
+	}
+	to, _ := time.Parse("2006-01-02", q.To)
+	switch {
+	case to.Before(from):
+		return nil, fmt.Errorf("to must not be before from")
+	case to.Sub(from) >= MaxAvailabilityRange*24*time.Hour:
+		return nil, fmt.Errorf("at most %d nights can be requested", MaxAvailabilityRange)
+	}
+	return q, nil
+}
+
+// Calendar returns the stored nights of a property between q.From and q.To; a night a
+// confirmed reservation holds is unavailable whatever was sampled
+func (s *AvailabilityService) Calendar(propertyID int64, q *AvailabilityQuery) (*AvailabilityCalendar, error) {
+	if !propertyListed(propertyID) {
+		return nil, ErrPropertyNotFound
+	}
+	converter, err := NewCurrencyConverter(q.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+	rows, err := db.Query(`
+		SELECT to_char(night, 'YYYY-MM-DD'),
+			available AND NOT EXISTS (SELECT 1 FROM reservation r
+				WHERE r.property_id = c.property_id AND r.status = 'confirmed'
+				AND daterange(r.check_in, r.check_out, '[)') && daterange(c.night, c.night + 1, '[)')),
+			price, currency, max_occupancy, fetched_at
+		FROM property_calendar c
+		WHERE property_id = $1 AND night BETWEEN $2::date AND $3::date
+		ORDER BY night`, propertyID, q.From, q.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve availability: %v", err)
+	}
+	defer rows.Close()
+
+	calendar := &AvailabilityCalendar{PropertyID: propertyID, From: q.From, To: q.To, Data: []models.CalendarNight{}}
+	for rows.Next() {
+		night := models.CalendarNight{PropertyID: propertyID}
+		err := rows.Scan(&night.Night, &night.Available, &night.Price, &night.Currency, &night.MaxOccupancy, &night.FetchedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read availability of property %d: %v", propertyID, err)
+		}
+		// A reserved night is priced no more than a sold out one
+		if !night.Available {
+			night.Price, night.Currency = nil, ""
+		}
+		if night.Price != nil {
+			price, currency, converted := converter.Amount(*night.Price, night.Currency)
+			night.Price, night.Currency, night.Unconverted = &price, currency, !converted
+		}
+		calendar.Data = append(calendar.Data, night)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read availability: %v", err)
+	}
+	return calendar, nil
+}
+
+// StartAvailabilityJob runs an availability crawl over every property as a background job
+func StartAvailabilityJob() (*Job, error) {
+	return Jobs.Start(JobKindAvailabilityCrawl, func(ctx context.Context, job *Job) error {
+		service := NewAvailabilityService()
+		service.Progress = job
+		service.Selection.Budget = NewCallBudget(ConfiguredCallBudget())
+		_, err := service.Crawl(ctx)
+		return err
+	})
+}
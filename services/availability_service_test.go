@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// detailsProvider answers stays/detail with an available room, calling onCall first
+type detailsProvider struct {
+	utils.ListingsProvider
+	onCall func(ctx context.Context) error
+}
+
+func (p *detailsProvider) FetchPropertyDetails(ctx context.Context, hotelID int, checkIn, checkOut string) (*models.StayDetailResponse, error) {
+	if err := p.onCall(ctx); err != nil {
+		return nil, err
+	}
+	return &models.StayDetailResponse{Data: models.StayDetail{BlockCount: 1, Block: []models.StayBlock{{RoomID: 1, MaxOccupancy: 2}}}}, nil
+}
+
+// TestAvailability checks the crawl window, how a one-night response reads as a calendar
+// night and the range of the availability endpoint
+func TestAvailability(t *testing.T) {
+	Convey("Subject: availability calendar\n", t, func() {
+		today := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+		Convey("The window starts after the offset and samples every step", func() {
+			service := &AvailabilityService{Nights: 7, StartOffset: 1, Step: 3, now: func() time.Time { return today }}
+			So(service.WindowNights(), ShouldResemble, []string{"2026-10-19", "2026-10-22", "2026-10-25"})
+		})
+
+		Convey("A cancelled crawl stores the nights sampled before", func() {
+			path := filepath.Join(t.TempDir(), "properties.json")
+			So(os.WriteFile(path, []byte(`[{"name":"Canal House","id":26263},{"name":"Dam Loft","id":26264}]`), 0644), ShouldBeNil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var calls int32
+			provider := &detailsProvider{onCall: func(ctx context.Context) error {
+				if atomic.AddInt32(&calls, 1) == 3 {
+					cancel()
+				}
+				return ctx.Err()
+			}}
+			var saved []models.CalendarNight
+			service := &AvailabilityService{
+				Provider: provider, PropertiesPath: path, Workers: 1, Nights: 3, StartOffset: 1, Step: 1,
+				now:  func() time.Time { return today },
+				save: func(nights []models.CalendarNight) error { saved = nights; return nil },
+			}
+
+			stored, err := service.Crawl(ctx)
+			So(err, ShouldNotBeNil)
+			So(stored, ShouldEqual, 2)
+			So(len(saved), ShouldEqual, 2)
+		})
+
+		Convey("An offered room makes the night available at the cheapest price", func() {
+			night := calendarNight(26263, "2026-11-17", &models.StayDetail{
+				BlockCount: 2,
+				Block:      []models.StayBlock{{RoomID: 1, MaxOccupancy: 2}, {RoomID: 2, NrAdults: 3, NrChildren: 1}},
+				ProductPriceBreakdown: models.StayPriceBreakdown{
+					GrossAmount: models.StayAmount{Currency: "EUR", Value: 189.5},
+				},
+			}, today)
+			So(night.Available, ShouldBeTrue)
+			So(*night.Price, ShouldEqual, 189.5)
+			So(night.Currency, ShouldEqual, "EUR")
+			So(night.MaxOccupancy, ShouldEqual, 4)
+		})
+
+		Convey("A sold out night has no price", func() {
+			night := calendarNight(26263, "2026-11-17", &models.StayDetail{
+				SoldOut:               1,
+				ProductPriceBreakdown: models.StayPriceBreakdown{GrossAmount: models.StayAmount{Currency: "EUR", Value: 189.5}},
+			}, today)
+			So(night.Available, ShouldBeFalse)
+			So(night.Price, ShouldBeNil)
+		})
+
+		Convey("The endpoint defaults to 30 nights from today and caps the range", func() {
+			q, err := ParseAvailabilityQuery(url.Values{}, today)
+			So(err, ShouldBeNil)
+			So(q.From, ShouldEqual, "2026-10-18")
+			So(q.To, ShouldEqual, "2026-11-16")
+
+			_, err = ParseAvailabilityQuery(url.Values{"from": {"2026-11-07"}, "to": {"2026-11-03"}}, today)
+			So(err, ShouldNotBeNil)
+			_, err = ParseAvailabilityQuery(url.Values{"from": {"2026-01-01"}, "to": {"2027-01-02"}}, today)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
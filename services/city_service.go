@@ -6,26 +6,26 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	//"strings"
+	"strings"
 	// "sync"
 	"time"
     "context"
-	"golang.org/x/time/rate"
 	"backend_rental/models"
 	"backend_rental/utils"
 	"github.com/beego/beego/v2/client/orm"
 )
 
+// cityCrawlName identifies the alphabetical city crawl in city_crawl_state
+const cityCrawlName = "cities"
+
 type CityService struct {
-	RateLimiter *rate.Limiter
-	ApiClient   *utils.ApiClient
+	Provider    utils.ListingsProvider
 	StoragePath string
+	Progress    ProgressReporter
 }
 
-// NewCityService initializes a new CityService with rate limiting, API client, and a storage path
+// NewCityService initializes a new CityService with an API client and a storage path
 func NewCityService() *CityService {
-	limiter := rate.NewLimiter(rate.Every(6*time.Second), 10)
-
 	dataDir := "data"
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		fmt.Printf("Error creating data directory: %v\n", err)
@@ -41,8 +41,7 @@ func NewCityService() *CityService {
 	}
 
 	return &CityService{
-		RateLimiter: limiter,
-		ApiClient:   utils.NewApiClient(),
+		Provider:    utils.NewListingsProvider(),
 		StoragePath: filepath.Join(dataDir, "cities.json"),
 	}
 }
@@ -132,13 +131,26 @@ func (s *CityService) SaveCitiesToDB(cities []models.Location) error {
             // Check if city already exists
             existing := models.Location{CityID: city.CityID}
             err := txOrm.Read(&existing, "CityID")
-            if err == orm.ErrNoRows {
+            switch {
+            case err == orm.ErrNoRows:
                 // City doesn't exist, insert it
+                city.ID = 0
                 _, err := txOrm.Insert(&city)
                 if err != nil {
                     txOrm.Rollback()
                     return fmt.Errorf("failed to insert city: %v", err)
                 }
+            case err != nil:
+                txOrm.Rollback()
+                return fmt.Errorf("failed to read city %s: %v", city.CityID, err)
+            case existing.CityName != city.CityName || existing.Country != city.Country:
+                // City exists with stale values, update it in place
+                existing.CityName = city.CityName
+                existing.Country = city.Country
+                if _, err := txOrm.Update(&existing, "CityName", "Country"); err != nil {
+                    txOrm.Rollback()
+                    return fmt.Errorf("failed to update city: %v", err)
+                }
             }
         }
     }
@@ -166,58 +178,58 @@ func (s *CityService) LoadCitiesFromDB() ([]models.Location, error) {
     return cities, nil
 }
 
-func (s *CityService) FetchCitiesAlphabetically() ([]models.Location, error) {
-    // Clear existing data first
-    if err := s.clearExistingCities(); err != nil {
-        fmt.Printf("Warning: Failed to clear existing cities: %v\n", err)
+// FetchCitiesAlphabetically walks the auto-complete endpoint from A to Z, upserting the cities
+// of every letter. Progress is checkpointed after each letter so a rerun resumes after the last
+// completed one; restart (or a previously completed crawl) begins again from A.
+func (s *CityService) FetchCitiesAlphabetically(ctx context.Context, restart bool) ([]models.Location, error) {
+    progress := progressOrNoop(s.Progress)
+
+    state, err := s.LoadCrawlState()
+    if err != nil {
+        return nil, err
     }
 
-    ctx := context.Background()
-    rateLimiter := utils.NewRateLimiter(
-        utils.LenientRateLimiter.Limit, 
-        utils.LenientRateLimiter.BurstSize,
-    )
+    if restart || state.Status == models.CrawlStatusCompleted || state.LastQuery == "" {
+        s.resetCrawlState(state)
+        fmt.Println("Starting city crawl from letter A")
+    } else {
+        fmt.Printf("Resuming city crawl after letter %s\n", state.LastQuery)
+    }
+    state.Status = models.CrawlStatusRunning
+    state.LastError = ""
+    if err := s.SaveCrawlState(state); err != nil {
+        return nil, err
+    }
+
+    // Previously stored cities stay in place; every letter is upserted on top of them
+    allCities, err := s.LoadCitiesFromDB()
+    if err != nil {
+        return nil, err
+    }
+
+    startLetter := nextCrawlLetter(state.LastQuery)
+    progress.SetTotal(int('Z' - startLetter + 1))
 
-    var allCities []models.Location
-    
     // Sequential fetching with careful delays and detailed logging
-    for letter := 'A'; letter <= 'Z'; letter++ {
+    for letter := startLetter; letter <= 'Z'; letter++ {
         query := string(letter)
         fmt.Printf("\n=== Processing letter %s ===\n", query)
 
-        // Wait for rate limit
-        if err := utils.WaitForRateLimit(ctx, rateLimiter); err != nil {
-            return nil, fmt.Errorf("rate limit error for letter %s: %v", query, err)
+        if err := ctx.Err(); err != nil {
+            return nil, s.failCrawl(state, fmt.Errorf("crawl stopped at letter %s: %v", query, err))
         }
 
-        // Try up to 3 times with exponential backoff
-        var response *models.ApiResponse
-        var err error
-        for attempt := 0; attempt < 3; attempt++ {
-            fmt.Printf("Attempt %d: Fetching cities for letter %s...\n", attempt+1, query)
-            
-            sleepDuration := time.Second * time.Duration(3+attempt*2)
-            time.Sleep(sleepDuration)
-
-            response, err = s.ApiClient.FetchCityData(query)
-            if err == nil {
-                break
-            }
-            
-            fmt.Printf("Attempt %d failed for letter %s: %v\n", attempt+1, query, err)
-            if attempt < 2 {
-                fmt.Printf("Waiting before retry...\n")
-            }
-        }
+        // The API client paces its calls on the provider limiter and retries transient failures itself
+        fmt.Printf("Fetching cities for letter %s...\n", query)
+        response, err := s.Provider.FetchCityData(query)
 
+        // Stop here so that the next run resumes at this letter
         if err != nil {
-            fmt.Printf("Failed to fetch cities for letter %s after all attempts: %v\n", query, err)
-            continue
+            progress.AddError(fmt.Errorf("letter %s: %v", query, err))
+            return nil, s.failCrawl(state, fmt.Errorf("failed to fetch cities for letter %s: %v", query, err))
         }
-
         if response == nil {
-            fmt.Printf("No response received for letter %s\n", query)
-            continue
+            return nil, s.failCrawl(state, fmt.Errorf("no response received for letter %s", query))
         }
 
         // Log API response details
@@ -235,8 +247,8 @@ func (s *CityService) FetchCitiesAlphabetically() ([]models.Location, error) {
                     CityID:   item.CityID,
                     Country:  item.Country,
                 }
-                letterCities = append(letterCities, city)
-                allCities = append(allCities, city)
+                letterCities = mergeCity(letterCities, city)
+                allCities = mergeCity(allCities, city)
                 validCount++
             } else {
                 invalidCount++
@@ -246,25 +258,42 @@ func (s *CityService) FetchCitiesAlphabetically() ([]models.Location, error) {
         }
 
         // Save progress after each successful letter
+        fmt.Printf("Letter %s summary:\n", query)
+        fmt.Printf("- Valid cities found: %d\n", validCount)
+        fmt.Printf("- Invalid entries skipped: %d\n", invalidCount)
+        fmt.Printf("- Running total of all cities: %d\n", len(allCities))
+
         if len(letterCities) > 0 {
-            fmt.Printf("Letter %s summary:\n", query)
-            fmt.Printf("- Valid cities found: %d\n", validCount)
-            fmt.Printf("- Invalid entries skipped: %d\n", invalidCount)
-            fmt.Printf("- Running total of all cities: %d\n", len(allCities))
-            
-            // Save current progress
-            if err := s.SaveCities(allCities); err != nil {
-                fmt.Printf("Warning: Failed to save progress: %v\n", err)
-            } else {
-                fmt.Printf("Successfully saved progress for letter %s\n", query)
+            if err := s.saveNewCities(letterCities, allCities); err != nil {
+                return nil, s.failCrawl(state, fmt.Errorf("failed to save cities for letter %s: %v", query, err))
             }
         } else {
             fmt.Printf("No valid cities found for letter %s\n", query)
         }
 
-        sleepDuration := time.Second * 5
-        fmt.Printf("Waiting %v before next letter...\n", sleepDuration)
-        time.Sleep(sleepDuration)
+        state.LastQuery = query
+        state.QueryCounts[query] = validCount
+        if err := s.SaveCrawlState(state); err != nil {
+            return nil, err
+        }
+        fmt.Printf("Successfully saved progress for letter %s\n", query)
+        progress.Advance(1)
+        progress.SetCount("cities", len(allCities))
+
+        if letter < 'Z' {
+            sleepDuration := time.Second * 5
+            fmt.Printf("Waiting %v before next letter...\n", sleepDuration)
+            if err := utils.SleepContext(ctx, sleepDuration); err != nil {
+                return nil, s.failCrawl(state, err)
+            }
+        }
+    }
+
+    completedAt := time.Now()
+    state.Status = models.CrawlStatusCompleted
+    state.CompletedAt = &completedAt
+    if err := s.SaveCrawlState(state); err != nil {
+        return nil, err
     }
 
     if len(allCities) == 0 {
@@ -275,19 +304,144 @@ func (s *CityService) FetchCitiesAlphabetically() ([]models.Location, error) {
     fmt.Printf("Successfully fetched a total of %d cities\n", len(allCities))
     return allCities, nil
 }
-func (s *CityService) clearExistingCities() error {
+
+// FetchCity looks up one city by name with a single auto-complete query and upserts the
+// exact matches, leaving the alphabetical crawl state untouched
+func (s *CityService) FetchCity(ctx context.Context, name string) ([]models.Location, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+
+    response, err := s.Provider.FetchCityData(name)
+    if err != nil {
+        return nil, fmt.Errorf("error fetching city %s: %v", name, err)
+    }
+
+    allCities, err := s.LoadCitiesFromDB()
+    if err != nil {
+        return nil, err
+    }
+
+    var matched []models.Location
+    for _, item := range response.Data {
+        if item.CityName == "" || item.CityID == "" || !strings.EqualFold(item.CityName, name) {
+            continue
+        }
+        city := models.Location{
+            CityName: item.CityName,
+            CityID:   item.CityID,
+            Country:  item.Country,
+        }
+        matched = mergeCity(matched, city)
+        allCities = mergeCity(allCities, city)
+    }
+    if len(matched) == 0 {
+        return nil, fmt.Errorf("no city named %q found", name)
+    }
+
+    if err := s.saveNewCities(matched, allCities); err != nil {
+        return nil, err
+    }
+    fmt.Printf("Saved %d cities named %s\n", len(matched), name)
+    return matched, nil
+}
+
+// saveNewCities upserts only the fetched cities to the database and rewrites the file
+// with the whole set, so a crawl doesn't re-upsert every city it already saved
+func (s *CityService) saveNewCities(fetched, all []models.Location) error {
+    if err := s.SaveCitiesToDB(fetched); err != nil {
+        return err
+    }
+    return s.SaveCitiesToFile(all)
+}
+
+// mergeCity replaces the entry with the same CityID or appends city
+func mergeCity(cities []models.Location, city models.Location) []models.Location {
+    for i := range cities {
+        if cities[i].CityID == city.CityID {
+            city.ID = cities[i].ID
+            cities[i] = city
+            return cities
+        }
+    }
+    return append(cities, city)
+}
+
+// nextCrawlLetter returns the letter following the last completed query
+func nextCrawlLetter(lastQuery string) rune {
+    if lastQuery == "" {
+        return 'A'
+    }
+    return rune(lastQuery[0]) + 1
+}
+
+// NextCrawlQuery returns the query a resumed crawl starts with, or "" once Z is done
+func NextCrawlQuery(lastQuery string) string {
+    if letter := nextCrawlLetter(lastQuery); letter <= 'Z' {
+        return string(letter)
+    }
+    return ""
+}
+
+// LoadCrawlState returns the persisted city crawl cursor, or a fresh one if none exists
+func (s *CityService) LoadCrawlState() (*models.CityCrawlState, error) {
     o := orm.NewOrm()
-    _, err := o.Raw("TRUNCATE TABLE location").Exec()
+    state := &models.CityCrawlState{Name: cityCrawlName}
+    err := o.Read(state, "Name")
+    if err == orm.ErrNoRows {
+        s.resetCrawlState(state)
+        return state, nil
+    }
     if err != nil {
-        return fmt.Errorf("failed to clear existing cities: %v", err)
+        return nil, fmt.Errorf("error loading city crawl state: %v", err)
     }
-    
-    // Also remove the JSON file if it exists
-    if err := os.Remove(s.StoragePath); err != nil && !os.IsNotExist(err) {
-        return fmt.Errorf("failed to remove cities file: %v", err)
+
+    state.QueryCounts = map[string]int{}
+    if state.QueryCountsRaw != "" {
+        if err := json.Unmarshal([]byte(state.QueryCountsRaw), &state.QueryCounts); err != nil {
+            return nil, fmt.Errorf("error unmarshaling city crawl counts: %v", err)
+        }
+    }
+    return state, nil
+}
+
+// SaveCrawlState inserts or updates the city crawl cursor
+func (s *CityService) SaveCrawlState(state *models.CityCrawlState) error {
+    counts, err := json.Marshal(state.QueryCounts)
+    if err != nil {
+        return fmt.Errorf("error marshaling city crawl counts: %v", err)
+    }
+    state.QueryCountsRaw = string(counts)
+    state.UpdatedAt = time.Now()
+
+    o := orm.NewOrm()
+    if state.Id == 0 {
+        _, err = o.Insert(state)
+    } else {
+        _, err = o.Update(state)
+    }
+    if err != nil {
+        return fmt.Errorf("error saving city crawl state: %v", err)
     }
-    
-    fmt.Println("Successfully cleared existing cities from database and file")
     return nil
 }
 
+func (s *CityService) resetCrawlState(state *models.CityCrawlState) {
+    state.Name = cityCrawlName
+    state.LastQuery = ""
+    state.LastError = ""
+    state.QueryCounts = map[string]int{}
+    state.StartedAt = time.Now()
+    state.CompletedAt = nil
+}
+
+// failCrawl records err on the crawl cursor and returns it
+func (s *CityService) failCrawl(state *models.CityCrawlState, err error) error {
+    fmt.Printf("City crawl stopped: %v\n", err)
+    state.Status = models.CrawlStatusFailed
+    state.LastError = err.Error()
+    if saveErr := s.SaveCrawlState(state); saveErr != nil {
+        fmt.Printf("Warning: Failed to save city crawl state: %v\n", saveErr)
+    }
+    return err
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+)
+
+// ErrUnknownCurrency is returned for a requested currency without a rate in currency_rate
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// ParseCurrency reads the optional currency parameter of the list and detail endpoints
+func ParseCurrency(values url.Values) (string, error) {
+	if values.Get("currency") == "" {
+		return "", nil
+	}
+	return utils.NormalizeCurrency(values.Get("currency"))
+}
+
+// CurrencyConverter converts stored prices to one currency with the rates in currency_rate
+type CurrencyConverter struct {
+	Rates    utils.CurrencyRates
+	Currency string
+}
+
+// NewCurrencyConverter converts to requested, or to currency::base when requested is empty.
+// Prices whose currency has no rate stay as quoted and are flagged unconverted.
+func NewCurrencyConverter(requested string) (*CurrencyConverter, error) {
+	rates, err := utils.CachedCurrencyRates()
+	if err != nil {
+		return nil, err
+	}
+	// the base currency needs no rate of its own, so it's accepted even without a row
+	if requested == "" || requested == utils.BaseCurrency() {
+		return &CurrencyConverter{Rates: rates, Currency: utils.BaseCurrency()}, nil
+	}
+	if _, ok := rates[requested]; !ok {
+		return nil, fmt.Errorf("%w %s; loaded rates cover %d currencies", ErrUnknownCurrency, requested, len(rates))
+	}
+	return &CurrencyConverter{Rates: rates, Currency: requested}, nil
+}
+
+// Convert converts the amounts of price in place
+func (c *CurrencyConverter) Convert(price *models.PropertyPrice) {
+	if price.Currency == c.Currency {
+		return
+	}
+	gross, ok := c.Rates.Convert(price.Gross, price.Currency, c.Currency)
+	if !ok {
+		price.Unconverted = true
+		return
+	}
+	price.Gross = gross
+	price.Taxes, _ = c.Rates.Convert(price.Taxes, price.Currency, c.Currency)
+	if price.Strikethrough != nil {
+		strikethrough, _ := c.Rates.Convert(*price.Strikethrough, price.Currency, c.Currency)
+		price.Strikethrough = &strikethrough
+	}
+	price.QuotedCurrency = price.Currency
+	price.Currency = c.Currency
+}
+
+// Amount converts value from currency. Without a rate for currency it returns value
+// unchanged with its own currency and converted false.
+func (c *CurrencyConverter) Amount(value float64, currency string) (amount float64, in string, converted bool) {
+	if amount, ok := c.Rates.Convert(value, currency, c.Currency); ok {
+		return amount, c.Currency, true
+	}
+	return value, currency, false
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job kinds of the ingest endpoints
+const (
+	JobKindCities               = "cities"
+	JobKindProperties           = "properties"
+	JobKindPropertyDetails      = "property-details"
+	JobKindPropertyDescriptions = "property-descriptions"
+	JobKindPropertyImages       = "property-images"
+)
+
+// Job status values
+const (
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// maxFinishedJobs bounds how many finished jobs are kept for status queries
+const maxFinishedJobs = 100
+
+var (
+	ErrJobNotFound       = errors.New("job not found")
+	ErrJobAlreadyRunning = errors.New("a job of this kind is already running")
+)
+
+// ProgressReporter receives progress from long-running service calls
+type ProgressReporter interface {
+	SetTotal(total int)
+	Advance(n int)
+	AddError(err error)
+	SetCount(key string, n int)
+}
+
+type noopProgress struct{}
+
+func (noopProgress) SetTotal(int)         {}
+func (noopProgress) Advance(int)          {}
+func (noopProgress) AddError(error)       {}
+func (noopProgress) SetCount(string, int) {}
+
+// progressOrNoop lets services report progress without checking for a nil reporter
+func progressOrNoop(p ProgressReporter) ProgressReporter {
+	if p == nil {
+		return noopProgress{}
+	}
+	return p
+}
+
+// JobInfo is the serializable state of a job
+type JobInfo struct {
+	ID         string         `json:"id"`
+	Kind       string         `json:"kind"`
+	Status     string         `json:"status"`
+	Total      int            `json:"total"`
+	Done       int            `json:"done"`
+	Errors     []string       `json:"errors"`
+	Counts     map[string]int `json:"counts"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+}
+
+// Job is a background run of one ingest stage; it implements ProgressReporter
+type Job struct {
+	mu     sync.Mutex
+	info   JobInfo
+	cancel context.CancelFunc
+}
+
+func (j *Job) SetTotal(total int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Total = total
+}
+
+func (j *Job) Advance(n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Done += n
+}
+
+func (j *Job) AddError(err error) {
+	if err == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Errors = append(j.info.Errors, err.Error())
+}
+
+func (j *Job) SetCount(key string, n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Counts[key] = n
+}
+
+// Snapshot returns a copy of the job state that is safe to serialize while it keeps running
+func (j *Job) Snapshot() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info := j.info
+	info.Errors = append([]string{}, j.info.Errors...)
+	info.Counts = make(map[string]int, len(j.info.Counts))
+	for k, v := range j.info.Counts {
+		info.Counts[k] = v
+	}
+	return info
+}
+
+func (j *Job) finish(ctx context.Context, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	j.info.FinishedAt = &now
+	switch {
+	case ctx.Err() == context.Canceled:
+		j.info.Status = JobStatusCancelled
+	case err != nil:
+		j.info.Status = JobStatusFailed
+		j.info.Errors = append(j.info.Errors, err.Error())
+	default:
+		j.info.Status = JobStatusSucceeded
+	}
+}
+
+// JobFunc is the body of a job; it must return promptly once ctx is cancelled
+type JobFunc func(ctx context.Context, job *Job) error
+
+// JobManager runs jobs in the background, allowing at most one running job per kind
+type JobManager struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	running map[string]*Job
+}
+
+func NewJobManager() *JobManager {
+	return &JobManager{
+		jobs:    map[string]*Job{},
+		running: map[string]*Job{},
+	}
+}
+
+// Jobs is the process-wide manager used by the HTTP controllers
+var Jobs = NewJobManager()
+
+// Start launches fn as a job of the given kind. If a job of that kind is still running,
+// it is returned together with ErrJobAlreadyRunning.
+func (m *JobManager) Start(kind string, fn JobFunc) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if running, ok := m.running[kind]; ok {
+		return running, ErrJobAlreadyRunning
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, fmt.Errorf("error generating job id: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		info: JobInfo{
+			ID:        id,
+			Kind:      kind,
+			Status:    JobStatusRunning,
+			Errors:    []string{},
+			Counts:    map[string]int{},
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+	m.jobs[id] = job
+	m.running[kind] = job
+	m.pruneLocked()
+
+	go func() {
+		defer cancel()
+		err := runJob(ctx, job, fn)
+		job.finish(ctx, err)
+
+		m.mu.Lock()
+		delete(m.running, kind)
+		m.mu.Unlock()
+		fmt.Printf("Job %s (%s) finished with status %s\n", id, kind, job.Snapshot().Status)
+	}()
+
+	fmt.Printf("Started job %s (%s)\n", id, kind)
+	return job, nil
+}
+
+// runJob converts a panic in fn into a job error so the manager state stays consistent
+func runJob(ctx context.Context, job *Job, fn JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return fn(ctx, job)
+}
+
+// Get returns the job with the given id
+func (m *JobManager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// List returns all known jobs, most recent first
+func (m *JobManager) List() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].info.StartedAt.After(jobs[k].info.StartedAt)
+	})
+	return jobs
+}
+
+// Cancel requests cancellation of a running job; finished jobs are left untouched
+func (m *JobManager) Cancel(id string) (*Job, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	job.cancel()
+	return job, nil
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxFinishedJobs
+func (m *JobManager) pruneLocked() {
+	var finished []*Job
+	for _, job := range m.jobs {
+		if job.Snapshot().FinishedAt != nil {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, k int) bool {
+		return finished[i].info.StartedAt.Before(finished[k].info.StartedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, job.info.ID)
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
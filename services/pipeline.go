@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+)
+
+// Ingest pipeline stages, in run order
+const (
+	StageCities       = "cities"
+	StageProperties   = "properties"
+	StageDetails      = "details"
+	StageDescriptions = "descriptions"
+	StageImages       = "images"
+	StageGenerate     = "generate"
+	StageLoad         = "load"
+	StageAvailability = "availability"
+)
+
+// PipelineStage is one step of the ingest pipeline with the files it reads and writes
+type PipelineStage struct {
+	Name        string
+	Description string
+	Inputs      []string
+	Outputs     []string
+	// NeedsDB is set for stages that read or write the database
+	NeedsDB bool
+	// Optional stages come after the default end of a pipeline run and run only when named
+	Optional bool
+
+	run  func(ctx context.Context, p *Pipeline) error
+	plan func(p *Pipeline) (string, error)
+}
+
+var pipelineStages = []PipelineStage{
+	{
+		Name:        StageCities,
+		Description: "crawl cities from the auto-complete endpoint",
+		Outputs:     []string{"data/cities.json"},
+		NeedsDB:     true,
+		run:         runCitiesStage,
+		plan:        planCitiesStage,
+	},
+	{
+		Name:        StageProperties,
+		Description: "search the properties of each city",
+		Inputs:      []string{"data/cities.json"},
+		Outputs:     []string{PropertiesFilePath},
+		run: func(ctx context.Context, p *Pipeline) error {
+			_, err := p.propertyService().FetchPropertiesForCities(ctx)
+			return err
+		},
+		plan: func(p *Pipeline) (string, error) {
+			cities, err := p.propertyService().SelectCities()
+			if err != nil {
+				return "", err
+			}
+			return p.describe("search properties", len(cities), "cities"), nil
+		},
+	},
+	{
+		Name:        StageDetails,
+		Description: "fetch type, rooms and amenities of each property",
+		Inputs:      []string{PropertiesFilePath},
+		Outputs:     []string{"data/property_details.json"},
+		run: func(ctx context.Context, p *Pipeline) error {
+			_, err := p.detailsService().FetchPropertyDetails(ctx)
+			return err
+		},
+		plan: func(p *Pipeline) (string, error) {
+			return p.planProperties("fetch details", p.detailsService().SelectProperties)
+		},
+	},
+	{
+		Name:        StageDescriptions,
+		Description: "fetch the description of each property",
+		Inputs:      []string{PropertiesFilePath},
+		Outputs:     []string{"data/property_desc_image.json"},
+		run: func(ctx context.Context, p *Pipeline) error {
+			return p.descService().FetchAndSavePropertyDescriptions(ctx)
+		},
+		plan: func(p *Pipeline) (string, error) {
+			return p.planProperties("fetch descriptions", p.descService().SelectProperties)
+		},
+	},
+	{
+		Name:        StageImages,
+		Description: "fetch the photos of each property",
+		Inputs:      []string{PropertiesFilePath},
+		Outputs:     []string{PropertyImagesFilePath},
+		run: func(ctx context.Context, p *Pipeline) error {
+			service, err := p.imageService()
+			if err != nil {
+				return err
+			}
+			_, err = service.FetchAndSavePropertyImages(ctx)
+			return err
+		},
+		plan: func(p *Pipeline) (string, error) {
+			service, err := p.imageService()
+			if err != nil {
+				return "", err
+			}
+			return p.planProperties("fetch photos", service.SelectProperties)
+		},
+	},
+	{
+		Name:        StageGenerate,
+		Description: "build RentalProperty.json and PropertyDetails.json from the fetched files",
+		Inputs: []string{
+			PropertiesFilePath,
+			"data/property_details.json",
+			"data/property_desc_image.json",
+			PropertyImagesFilePath,
+		},
+		Outputs: []string{utils.RentalPropertySeedPath, utils.PropertyDetailsSeedPath},
+		run: func(ctx context.Context, p *Pipeline) error {
+			if err := (&RentalPropertyService{}).GenerateFromFiles(); err != nil {
+				return fmt.Errorf("failed to generate %s: %v", utils.RentalPropertySeedPath, err)
+			}
+			if err := (&PropertyDetailsServiceJSON{}).GenerateFromFiles(); err != nil {
+				return fmt.Errorf("failed to generate %s: %v", utils.PropertyDetailsSeedPath, err)
+			}
+			return nil
+		},
+		plan: func(p *Pipeline) (string, error) {
+			return fmt.Sprintf("would write %s and %s", utils.RentalPropertySeedPath, utils.PropertyDetailsSeedPath), nil
+		},
+	},
+	{
+		Name:        StageLoad,
+		Description: "seed rental_property and property_details from the generated files",
+		Inputs:      []string{utils.RentalPropertySeedPath, utils.PropertyDetailsSeedPath},
+		NeedsDB:     true,
+		run: func(ctx context.Context, p *Pipeline) error {
+			_, err := utils.SeedDatabase(p.SeedMode)
+			return err
+		},
+		plan: func(p *Pipeline) (string, error) {
+			return fmt.Sprintf("would seed the database in %s mode", p.SeedMode), nil
+		},
+	},
+	{
+		Name:        StageAvailability,
+		Description: "sample nightly availability and price of each property into property_calendar",
+		Inputs:      []string{PropertiesFilePath},
+		NeedsDB:     true,
+		Optional:    true,
+		run: func(ctx context.Context, p *Pipeline) error {
+			_, err := p.availabilityService().Crawl(ctx)
+			return err
+		},
+		plan: func(p *Pipeline) (string, error) {
+			service := p.availabilityService()
+			properties, err := service.SelectProperties()
+			if err != nil {
+				return "", err
+			}
+			nights := service.WindowNights()
+			return p.describe(fmt.Sprintf("sample %d nights from %s", len(nights), firstOf(nights)),
+				len(properties)*len(nights), "property nights"), nil
+		},
+	},
+}
+
+// PipelineStages returns the stages in run order
+func PipelineStages() []PipelineStage {
+	return append([]PipelineStage{}, pipelineStages...)
+}
+
+// LookupStage returns the stage with the given name
+func LookupStage(name string) (*PipelineStage, error) {
+	for i := range pipelineStages {
+		if pipelineStages[i].Name == name {
+			return &pipelineStages[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown stage %q", name)
+}
+
+// StageRange returns the stages from..to inclusive; empty names mean the first and the last
+// stage that is not optional
+func StageRange(from, to string) ([]PipelineStage, error) {
+	start, end := 0, 0
+	for i, stage := range pipelineStages {
+		if !stage.Optional {
+			end = i
+		}
+	}
+	for i, stage := range pipelineStages {
+		if stage.Name == from {
+			start = i
+		}
+		if stage.Name == to {
+			end = i
+		}
+	}
+	if to == "" && start > end {
+		end = start
+	}
+	if _, err := LookupStage(from); from != "" && err != nil {
+		return nil, err
+	}
+	if _, err := LookupStage(to); to != "" && err != nil {
+		return nil, err
+	}
+	if start > end {
+		return nil, fmt.Errorf("stage %s comes after %s", from, to)
+	}
+	return append([]PipelineStage{}, pipelineStages[start:end+1]...), nil
+}
+
+// Pipeline runs ingest stages in order, checking that each stage's inputs exist first
+type Pipeline struct {
+	// City restricts the stages to one city, given by ID or name
+	City string
+	// PropertyIDs restricts the per-property stages to these hotel IDs
+	PropertyIDs []int
+	// TopReviewed picks the most reviewed properties first
+	TopReviewed bool
+	// Missing skips cities and properties a stage already has data for
+	Missing bool
+	// Limit caps how many cities or properties each stage selects; 0 means no cap
+	Limit int
+	// Budget caps the provider calls of the whole run; 0 means unlimited
+	Budget int
+	// DryRun reports what each stage would do without calling the provider or writing
+	DryRun bool
+	// Restart starts the city crawl over from A
+	Restart bool
+	// SeedMode is the utils.SeedMode* used by the load stage
+	SeedMode string
+	// Refresh calls the provider for every request and stores the responses in the cache
+	Refresh bool
+	// NoCache neither reads nor writes the response cache
+	NoCache bool
+
+	selection Selection
+	resolved  bool
+}
+
+// Run executes stages in order. Inputs written by an earlier stage of the same run count as
+// present, so a dry run of the whole pipeline plans every stage.
+func (p *Pipeline) Run(ctx context.Context, stages []PipelineStage) error {
+	produced := map[string]string{}
+	for _, stage := range stages {
+		for _, input := range stage.Inputs {
+			if _, ok := produced[input]; ok {
+				continue
+			}
+			if _, err := os.Stat(input); err != nil {
+				return fmt.Errorf("stage %s needs %s; run the %s stage first", stage.Name, input, producerOf(input))
+			}
+		}
+
+		if err := p.runStage(ctx, stage, produced); err != nil {
+			return fmt.Errorf("stage %s failed: %v", stage.Name, err)
+		}
+		for _, output := range stage.Outputs {
+			produced[output] = stage.Name
+		}
+	}
+
+	if cache := utils.SharedResponseCache(); cache != nil && !p.DryRun {
+		stats := cache.Totals()
+		if lookups := stats.Hits + stats.Misses + stats.Expired; lookups > 0 {
+			fmt.Printf("Response cache: %d of %d lookups hit (%.0f%%), %d expired, %d stored, %d evicted\n",
+				stats.Hits, lookups, stats.HitRate()*100, stats.Expired, stats.Stores, stats.Evictions)
+		}
+	}
+	return nil
+}
+
+func (p *Pipeline) runStage(ctx context.Context, stage PipelineStage, produced map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if stage.Name != StageCities {
+		// In a dry run the city may only be known once the cities stage has really run
+		if _, pending := produced["data/cities.json"]; !(p.DryRun && pending) {
+			if err := p.resolveSelection(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.DryRun {
+		summary := "would run"
+		if _, pending := produced["data/cities.json"]; !pending || stage.Name == StageCities {
+			var err error
+			if summary, err = stage.plan(p); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("[dry-run] %-13s %s\n", stage.Name, summary)
+		return nil
+	}
+
+	fmt.Printf("=== Stage %s: %s ===\n", stage.Name, stage.Description)
+	return stage.run(ctx, p)
+}
+
+// resolveSelection turns City into a city ID using data/cities.json
+func (p *Pipeline) resolveSelection() error {
+	if p.resolved {
+		return nil
+	}
+	p.selection = Selection{
+		PropertyIDs: p.PropertyIDs,
+		TopReviewed: p.TopReviewed,
+		Missing:     p.Missing,
+		Limit:       p.Limit,
+		Budget:      NewCallBudget(p.Budget),
+		Cache:       p.cacheMode(),
+	}
+	if p.City != "" {
+		cityID, err := resolveCityID(p.City)
+		if err != nil {
+			return err
+		}
+		p.selection.CityID = cityID
+	}
+	p.resolved = true
+	return nil
+}
+
+// producerOf names the stage that writes path
+func producerOf(path string) string {
+	for _, stage := range pipelineStages {
+		for _, output := range stage.Outputs {
+			if output == path {
+				return stage.Name
+			}
+		}
+	}
+	return "previous"
+}
+
+// cacheMode is the utils.CacheMode selected by NoCache and Refresh
+func (p *Pipeline) cacheMode() utils.CacheMode {
+	switch {
+	case p.NoCache:
+		return utils.CacheOff
+	case p.Refresh:
+		return utils.CacheRefresh
+	}
+	return utils.CacheUse
+}
+
+func runCitiesStage(ctx context.Context, p *Pipeline) error {
+	service := NewCityService()
+	utils.SetCacheMode(service.Provider, p.cacheMode())
+	if p.City != "" {
+		_, err := service.FetchCity(ctx, p.City)
+		return err
+	}
+	_, err := service.FetchCitiesAlphabetically(ctx, p.Restart)
+	return err
+}
+
+func planCitiesStage(p *Pipeline) (string, error) {
+	if p.City != "" {
+		return fmt.Sprintf("would look up the city %q", p.City), nil
+	}
+
+	state, err := NewCityService().LoadCrawlState()
+	if err != nil {
+		return "", err
+	}
+	if p.Restart || state.LastQuery == "" || state.Status == models.CrawlStatusCompleted {
+		return "would crawl cities for letters A-Z", nil
+	}
+	return fmt.Sprintf("would resume the city crawl at letter %s", NextCrawlQuery(state.LastQuery)), nil
+}
+
+func (p *Pipeline) propertyService() *PropertyService {
+	service := NewPropertyService()
+	service.Selection = p.selection
+	return service
+}
+
+func (p *Pipeline) detailsService() *PropertyDetailsService {
+	service := NewPropertyDetailsService()
+	service.Selection = p.selection
+	return service
+}
+
+func (p *Pipeline) descService() *PropertyDescService {
+	service := NewPropertyDescService()
+	service.Selection = p.selection
+	return service
+}
+
+func (p *Pipeline) imageService() (*PropertyImageService, error) {
+	service, err := NewPropertyImageService(utils.NewListingsProvider())
+	if err != nil {
+		return nil, err
+	}
+	service.Selection = p.selection
+	return service, nil
+}
+
+func (p *Pipeline) availabilityService() *AvailabilityService {
+	service := NewAvailabilityService()
+	service.Selection = p.selection
+	return service
+}
+
+// planProperties describes a per-property stage over the properties selectFn picks
+func (p *Pipeline) planProperties(action string, selectFn func() ([]models.Property, error)) (string, error) {
+	properties, err := selectFn()
+	if err != nil {
+		return "", err
+	}
+	return p.describe(action, len(properties), "properties"), nil
+}
+
+// describe summarizes a planned stage. Dry runs spend no budget, so every stage is
+// measured against the whole budget.
+func (p *Pipeline) describe(action string, n int, noun string) string {
+	summary := fmt.Sprintf("would %s for %d %s (%s)", action, n, noun, p.selection)
+	if p.selection.Budget.Limited() && n > p.selection.Budget.Remaining() {
+		summary += fmt.Sprintf("; the budget stops it after %d", p.selection.Budget.Remaining())
+	}
+	return summary
+}
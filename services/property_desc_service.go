@@ -8,16 +8,17 @@ import (
     "os"
     "path/filepath"
     "strconv"
-    "time"
-    "golang.org/x/time/rate"
     "backend_rental/models"
     "backend_rental/utils"
 )
 
 type PropertyDescService struct {
-    RateLimiter *rate.Limiter
-    ApiClient   *utils.ApiClient
+    Provider    utils.ListingsProvider
     StoragePath string
+    Progress    ProgressReporter
+    Selection   Selection
+    // Workers is how many description requests run at once
+    Workers     int
 }
 
 type PropertyDescriptionDetail struct {
@@ -27,59 +28,77 @@ type PropertyDescriptionDetail struct {
 }
 
 func NewPropertyDescService() *PropertyDescService {
-    limiter := rate.NewLimiter(rate.Every(6*time.Second), 10)
-    
     dataDir := "data"
     if err := os.MkdirAll(dataDir, 0755); err != nil {
         fmt.Printf("Error creating data directory: %v\n", err)
     }
     
     return &PropertyDescService{
-        RateLimiter: limiter,
-        ApiClient:   utils.NewApiClient(),
+        Provider:    utils.NewListingsProvider(),
+        Workers:     IngestWorkers(),
         StoragePath: filepath.Join(dataDir, "property_desc_image.json"),
     }
 }
-// Modify the FetchAndSavePropertyDescriptions method
-func (s *PropertyDescService) FetchAndSavePropertyDescriptions() error {
+// SelectProperties returns the properties the next FetchAndSavePropertyDescriptions run covers
+func (s *PropertyDescService) SelectProperties() ([]models.Property, error) {
     // Load properties from file
     propertiesData, err := os.ReadFile("data/properties.json")
     if err != nil {
-        return fmt.Errorf("error reading properties file: %v", err)
+        return nil, fmt.Errorf("error reading properties file: %v", err)
     }
 
     var properties []models.Property
     err = json.Unmarshal(propertiesData, &properties)
     if err != nil {
-        return fmt.Errorf("error unmarshaling properties: %v", err)
+        return nil, fmt.Errorf("error unmarshaling properties: %v", err)
     }
 
-    // Limit to first 10 properties
-    if len(properties) > 10 {
-        properties = properties[:10]
+    var done map[int]bool
+    if s.Selection.Missing {
+        done, err = storedIDs(s.StoragePath, func(d PropertyDescriptionDetail) int { return d.PropertyID })
+        if err != nil {
+            return nil, err
+        }
     }
+    return s.Selection.SelectProperties(properties, done), nil
+}
+
+// Modify the FetchAndSavePropertyDescriptions method
+func (s *PropertyDescService) FetchAndSavePropertyDescriptions(ctx context.Context) error {
+    utils.SetCacheMode(s.Provider, s.Selection.Cache)
+    properties, err := s.SelectProperties()
+    if err != nil {
+        return err
+    }
+
+    progress := progressOrNoop(s.Progress)
+    progress.SetTotal(len(properties))
 
     var propertyDescriptions []PropertyDescriptionDetail
-    ctx := context.Background()
 
-    for _, property := range properties {
-        // Rate limit
-        startWait := time.Now()
-        err := s.RateLimiter.Wait(ctx)
-        waitDuration := time.Since(startWait)
-        
-        fmt.Printf("Rate limiter wait for %s: %v\n", property.PropertyName, waitDuration)
-        
-        if err != nil {
-            return fmt.Errorf("rate limiter error: %v", err)
+    pool := workerPool{Workers: s.Workers, Budget: s.Selection.Budget, Progress: progress}
+    results, err := runPool(ctx, pool, properties, func(ctx context.Context, property models.Property) (*utils.PropertyDescriptionResponse, error) {
+        return s.Provider.FetchPropertyDescription(ctx, strconv.Itoa(property.HotelID))
+    })
+    if err != nil {
+        return fmt.Errorf("property description fetch aborted: %v", err)
+    }
+
+    fetched := map[int]bool{}
+    for i, property := range properties {
+        if !results[i].Attempted {
+            fmt.Printf("Stopped early: call budget or provider quota exhausted; %d properties left for the next run\n", len(properties)-i)
+            break
         }
 
-        // Fetch description
-        response, err := s.ApiClient.FetchPropertyDescription(strconv.Itoa(property.HotelID))
+        response, err := results[i].Value, results[i].Err
         if err != nil {
             fmt.Printf("Error fetching description for %s: %v\n", property.PropertyName, err)
+            progress.AddError(fmt.Errorf("property %d: %v", property.HotelID, err))
             continue
         }
+        // Only a fetched property replaces its stored description
+        fetched[property.HotelID] = true
 
         // Find the main description (typically the first one with descriptiontype_id 6)
         var mainDescription string
@@ -98,9 +117,19 @@ func (s *PropertyDescService) FetchAndSavePropertyDescriptions() error {
         })
 
         fmt.Printf("Fetched description for %s\n", property.PropertyName)
+        progress.SetCount("descriptions", len(propertyDescriptions))
     }
 
     // Save to file
+    if s.Selection.Partial() {
+        propertyDescriptions, err = mergeRecords(s.StoragePath, propertyDescriptions, func(d PropertyDescriptionDetail) bool {
+            return fetched[d.PropertyID]
+        })
+        if err != nil {
+            return err
+        }
+    }
+
     return s.SavePropertyDescriptionsToFile(propertyDescriptions)
 }
 // func (s *PropertyDescService) FetchAndSavePropertyDescriptions() error {
@@ -7,30 +7,33 @@ import (
     "fmt"
     "os"
     "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
     "time"
-    "golang.org/x/time/rate"
     "backend_rental/models"
     "backend_rental/utils"
 )
 
 type PropertyDetailsService struct {
-    RateLimiter *rate.Limiter
-    ApiClient   *utils.ApiClient
+    Provider    utils.ListingsProvider
     StoragePath string
     PropertiesPath string
+    Progress    ProgressReporter
+    Selection   Selection
+    // Workers is how many details requests run at once
+    Workers     int
 }
 
 func NewPropertyDetailsService() *PropertyDetailsService {
-    limiter := rate.NewLimiter(rate.Every(6*time.Second), 10)
-    
     dataDir := "data"
     if err := os.MkdirAll(dataDir, 0755); err != nil {
         fmt.Printf("Error creating data directory: %v\n", err)
     }
     
     return &PropertyDetailsService{
-        RateLimiter: limiter,
-        ApiClient:   utils.NewApiClient(),
+        Provider:    utils.NewListingsProvider(),
+        Workers:     IngestWorkers(),
         StoragePath: filepath.Join(dataDir, "property_details.json"),
         PropertiesPath: filepath.Join(dataDir, "properties.json"),
     }
@@ -50,59 +53,88 @@ func (s *PropertyDetailsService) LoadProperties() ([]models.Property, error) {
 
     return properties, nil
 }
-func (s *PropertyDetailsService) FetchPropertyDetails() ([]models.PropertyDetail, error) {
+// SelectProperties returns the properties the next FetchPropertyDetails run covers
+func (s *PropertyDetailsService) SelectProperties() ([]models.Property, error) {
     properties, err := s.LoadProperties()
     if err != nil {
         return nil, err
     }
 
+    var done map[int]bool
+    if s.Selection.Missing {
+        done, err = storedIDs(s.StoragePath, func(d models.PropertyDetail) int { return d.HotelID })
+        if err != nil {
+            return nil, err
+        }
+    }
+    return s.Selection.SelectProperties(properties, done), nil
+}
+
+func (s *PropertyDetailsService) FetchPropertyDetails(ctx context.Context) ([]models.PropertyDetail, error) {
+    utils.SetCacheMode(s.Provider, s.Selection.Cache)
+    properties, err := s.SelectProperties()
+    if err != nil {
+        return nil, err
+    }
+
+    progress := progressOrNoop(s.Progress)
     var allPropertyDetails []models.PropertyDetail
-    ctx := context.Background()
     checkIn := time.Now().AddDate(0, 0, 30).Format("2006-01-02")
     checkOut := time.Now().AddDate(0, 0, 31).Format("2006-01-02")
 
-    // Limit to 10 properties
-    limit := 10
-    if len(properties) < limit {
-        limit = len(properties)
+    progress.SetTotal(len(properties))
+
+    pool := workerPool{Workers: s.Workers, Budget: s.Selection.Budget, Progress: progress}
+    results, err := runPool(ctx, pool, properties, func(ctx context.Context, property models.Property) (*models.StayDetailResponse, error) {
+        return s.Provider.FetchPropertyDetails(ctx, property.HotelID, checkIn, checkOut)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("property details fetch aborted: %v", err)
     }
 
-    for _, property := range properties[:limit] {
-        startWait := time.Now()
-        err := s.RateLimiter.Wait(ctx)
-        waitDuration := time.Since(startWait)
-        
-        fmt.Printf("Rate limiter wait for %s: %v\n", property.PropertyName, waitDuration)
-        
-        if err != nil {
-            return nil, fmt.Errorf("rate limiter error: %v", err)
+    fetched := map[int]bool{}
+    drift := utils.NewDriftSummary()
+    for i, property := range properties {
+        if !results[i].Attempted {
+            fmt.Printf("Stopped early: call budget or provider quota exhausted; %d properties left for the next run\n", len(properties)-i)
+            break
         }
 
-        response, err := s.ApiClient.FetchPropertyDetails(property.HotelID, checkIn, checkOut)
+        response, err := results[i].Value, results[i].Err
         if err != nil {
             fmt.Printf("Error fetching details for %s (ID: %d): %v\n", property.PropertyName, property.HotelID, err)
+            progress.AddError(fmt.Errorf("property %d: %v", property.HotelID, err))
             continue
         }
+        // Only a fetched property replaces its stored details
+        fetched[property.HotelID] = true
+
+        drift.Add(response.Drift)
+        if response.Drift.Breaking() {
+            fmt.Printf("Schema drift in details of %s (ID: %d): %v\n", property.PropertyName, property.HotelID, response.Drift)
+            progress.SetCount("schemaDrift", drift.Drifted)
+        }
 
-        // Debug print the entire response
-        fmt.Printf("Full API Response for %s: %+v\n", property.PropertyName, response)
-
-        // Extract required details with explicit type checking
-        propertyDetail := models.PropertyDetail{
-			HotelID:       property.HotelID,
-			CityID:        property.CityID,
-			PropertyType:  s.extractPropertyType(response),
-			Bedrooms:      s.extractBedrooms(response),
-			Bathrooms:     s.extractBathrooms(response),
-			Amenities:     s.extractAmenities(response),
-		}
+        propertyDetail := s.extractDetail(property, &response.Data)
         allPropertyDetails = append(allPropertyDetails, propertyDetail)
+        progress.SetCount("propertyDetails", len(allPropertyDetails))
     }
 
     fmt.Printf("Property details fetched: %d\n", len(allPropertyDetails))
+    drift.Report("stays/detail")
 
     // Save to file
-    err = s.SavePropertyDetailsToFile(allPropertyDetails)
+    saved := allPropertyDetails
+    if s.Selection.Partial() {
+        saved, err = mergeRecords(s.StoragePath, allPropertyDetails, func(d models.PropertyDetail) bool {
+            return fetched[d.HotelID]
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    err = s.SavePropertyDetailsToFile(saved)
     if err != nil {
         fmt.Printf("Warning: Failed to save property details: %v\n", err)
     }
@@ -164,71 +196,183 @@ func (s *PropertyDetailsService) FetchPropertyDetails() ([]models.PropertyDetail
 //     return allPropertyDetails, nil
 // }
 
-func (s *PropertyDetailsService) extractPropertyType(details *map[string]interface{}) string {
-    if data, ok := (*details)["data"].(map[string]interface{}); ok {
-        if typeName, ok := data["accommodation_type_name"].(string); ok {
-            return typeName
-        }
+// extractDetail copies the fields the later stages use out of a stays/detail payload
+func (s *PropertyDetailsService) extractDetail(property models.Property, data *models.StayDetail) models.PropertyDetail {
+    units := s.extractUnits(property.HotelID, data)
+    summary := models.SummarizeUnits(units)
+    return models.PropertyDetail{
+        HotelID:       property.HotelID,
+        CityID:        property.CityID,
+        HotelName:     data.HotelName,
+        PropertyType:  data.AccommodationTypeName,
+        Bedrooms:      summary.Bedrooms,
+        Bathrooms:     summary.Bathrooms,
+        MaxOccupancy:  summary.MaxOccupancy,
+        Units:         units,
+        Amenities:     s.extractAmenities(data),
+        URL:           data.URL,
+        Address:       strings.TrimSpace(data.Address),
+        City:          data.City,
+        Zip:           data.Zip,
+        CountryCode:   strings.ToUpper(data.CountryCode),
+        Latitude:      data.Latitude,
+        Longitude:     data.Longitude,
+        StarRating:    data.Class,
+        CheckInFrom:   data.Checkin.From,
+        CheckInUntil:  data.Checkin.Until,
+        CheckOutFrom:  data.Checkout.From,
+        CheckOutUntil: data.Checkout.Until,
+        Policies:      s.extractPolicies(data),
     }
-    fmt.Println("Failed to extract accommodation_type_name")
-    return ""
 }
 
-func (s *PropertyDetailsService) extractBedrooms(details *map[string]interface{}) int {
-    if data, ok := (*details)["data"].(map[string]interface{}); ok {
-        if blocks, ok := data["block_count"].(float64); ok {
-            return int(blocks)
+// extractUnits builds one unit per room type, in the order the block list first offers
+// them, followed by room types without an offer for the stay dates. Occupancy and size
+// come from the offers, beds and bathrooms from the room type.
+func (s *PropertyDetailsService) extractUnits(hotelID int, data *models.StayDetail) []models.PropertyUnit {
+    var units []models.PropertyUnit
+    index := map[int64]int{}
+    unit := func(roomID int64) *models.PropertyUnit {
+        if i, ok := index[roomID]; ok {
+            return &units[i]
         }
+        index[roomID] = len(units)
+        units = append(units, models.PropertyUnit{PropertyID: int64(hotelID), RoomID: roomID, Position: len(units)})
+        return &units[len(units)-1]
     }
-    fmt.Println("Failed to extract block_count")
-    return 0
-}
 
-func (s *PropertyDetailsService) extractBathrooms(details *map[string]interface{}) int {
-    if data, ok := (*details)["data"].(map[string]interface{}); ok {
-        // Try multiple potential ways to extract bathroom count
-        if bathrooms, ok := data["number_of_bathrooms"].(float64); ok && bathrooms > 0 {
-            return int(bathrooms)
+    for _, block := range data.Block {
+        if block.RoomID == 0 {
+            continue
+        }
+        u := unit(block.RoomID)
+        if u.Name == "" {
+            u.Name = strings.TrimSpace(block.RoomName)
+        }
+        if u.Name == "" {
+            u.Name = strings.TrimSpace(block.Name)
+        }
+        occupancy := block.MaxOccupancy
+        if occupancy == 0 {
+            occupancy = block.NrAdults + block.NrChildren
         }
-        // Fallback to block count if no specific bathroom count
-        if blocks, ok := data["block_count"].(float64); ok {
-            return int(blocks)
+        u.MaxOccupancy = max(u.MaxOccupancy, occupancy)
+        if u.SizeM2 == 0 {
+            u.SizeM2 = block.RoomSurfaceInM2
         }
     }
-    return 0
+
+    var unoffered []int64
+    for key := range data.Rooms {
+        roomID, err := strconv.ParseInt(key, 10, 64)
+        if err != nil {
+            continue
+        }
+        if _, ok := index[roomID]; !ok {
+            unoffered = append(unoffered, roomID)
+        }
+    }
+    sort.Slice(unoffered, func(i, j int) bool { return unoffered[i] < unoffered[j] })
+    for _, roomID := range unoffered {
+        unit(roomID)
+    }
+
+    for i := range units {
+        room, ok := data.Rooms[strconv.FormatInt(units[i].RoomID, 10)]
+        if !ok {
+            continue
+        }
+        units[i].BedConfiguration = bedConfiguration(room.BedConfigurations)
+        units[i].Bedrooms = room.BedroomCount
+        if units[i].Bedrooms == 0 && units[i].BedConfiguration != "" {
+            // A hotel room or studio is one bedroom
+            units[i].Bedrooms = 1
+        }
+        units[i].BathroomType = bathroomType(room)
+        units[i].Bathrooms = room.BathroomCount
+        if units[i].Bathrooms == 0 && units[i].BathroomType == models.BathroomPrivate {
+            units[i].Bathrooms = 1
+        }
+    }
+    return units
 }
 
-func (s *PropertyDetailsService) extractAmenities(details *map[string]interface{}) []string {
-    var amenities []string
-    if data, ok := (*details)["data"].(map[string]interface{}); ok {
-        // Try multiple sources for amenities
-        if facilities, ok := data["facilities"].([]interface{}); ok {
-            for _, facility := range facilities {
-                if facilityMap, ok := facility.(map[string]interface{}); ok {
-                    if name, ok := facilityMap["name"].(string); ok {
-                        amenities = append(amenities, name)
-                    }
-                }
+// bedConfiguration describes the beds of a room, e.g. "1 large double bed or 2 single beds"
+func bedConfiguration(configurations []models.StayBedConfiguration) string {
+    var alternatives []string
+    for _, configuration := range configurations {
+        var beds []string
+        for _, bed := range configuration.BedTypes {
+            switch {
+            case bed.NameWithCount != "":
+                beds = append(beds, bed.NameWithCount)
+            case bed.Name != "" && bed.Count > 0:
+                beds = append(beds, fmt.Sprintf("%d %s", bed.Count, bed.Name))
+            case bed.Name != "":
+                beds = append(beds, bed.Name)
             }
         }
-        
-        // Fallback to facilities_block
-        if facilitiesBlock, ok := data["facilities_block"].(map[string]interface{}); ok {
-            if facilities, ok := facilitiesBlock["facilities"].([]interface{}); ok {
-                for _, facility := range facilities {
-                    if facilityMap, ok := facility.(map[string]interface{}); ok {
-                        if name, ok := facilityMap["name"].(string); ok {
-                            amenities = append(amenities, name)
-                        }
-                    }
-                }
-            }
+        if len(beds) > 0 {
+            alternatives = append(alternatives, strings.Join(beds, ", "))
         }
     }
-    
+    return strings.Join(alternatives, " or ")
+}
+
+// bathroomType reads the bathroom from the private bathroom highlight or the room facilities
+func bathroomType(room models.StayRoom) string {
+    if room.PrivateBathroomHighlight.HasHighlight > 0 {
+        return models.BathroomPrivate
+    }
+    for _, facility := range room.Facilities {
+        name := strings.ToLower(facility.Name)
+        switch {
+        case strings.Contains(name, "shared bathroom"), strings.Contains(name, "shared toilet"):
+            return models.BathroomShared
+        case strings.Contains(name, "private bathroom"):
+            return models.BathroomPrivate
+        }
+    }
+    return ""
+}
+
+// extractAmenities collects facility names from facilities and facilities_block, skipping
+// names already seen in either list
+func (s *PropertyDetailsService) extractAmenities(data *models.StayDetail) []string {
+    var amenities []string
+    seen := map[string]bool{}
+    add := func(name string) {
+        key := strings.ToLower(strings.TrimSpace(name))
+        if key == "" || seen[key] {
+            return
+        }
+        seen[key] = true
+        amenities = append(amenities, strings.TrimSpace(name))
+    }
+    for _, facility := range data.Facilities {
+        add(facility.Name)
+    }
+    for _, facility := range data.FacilitiesBlock.Facilities {
+        add(facility.Name)
+    }
     return amenities
 }
 
+// extractPolicies returns the house policy phrases in payload order, without duplicates
+func (s *PropertyDetailsService) extractPolicies(data *models.StayDetail) []string {
+    var policies []string
+    seen := map[string]bool{}
+    for _, policy := range data.ImportantInformation {
+        phrase := strings.TrimSpace(policy.Phrase)
+        if phrase == "" || seen[phrase] {
+            continue
+        }
+        seen[phrase] = true
+        policies = append(policies, phrase)
+    }
+    return policies
+}
+
 func (s *PropertyDetailsService) SavePropertyDetailsToFile(propertyDetails []models.PropertyDetail) error {
     data, err := json.MarshalIndent(propertyDetails, "", "    ")
     if err != nil {
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestExtractDetailUnits checks that rooms and offers become units and that a listing is
+// summarized by its largest unit instead of its offer count
+func TestExtractDetailUnits(t *testing.T) {
+	Convey("Subject: units of a stays/detail payload\n", t, func() {
+		var response models.StayDetailResponse
+		_, err := utils.DecodeChecked([]byte(`{"data":{
+			"hotel_id":26263,"hotel_name":"Canal House","url":"https://www.booking.com/hotel/nl/canal.html",
+			"accommodation_type_name":"Hotels","block_count":70,
+			"rooms":{
+				"2626303":{"bed_configurations":[
+					{"bed_types":[{"name":"large double bed","name_with_count":"1 large double bed","count":1}]},
+					{"bed_types":[{"name_with_count":"2 single beds","count":2},{"name":"sofa bed","count":1}]}],
+					"private_bathroom_highlight":{"has_highlight":1}},
+				"2626301":{"bed_configurations":[{"bed_types":[{"name_with_count":"1 bunk bed"}]}],
+					"facilities":[{"id":1,"name":"Shared bathroom"}]},
+				"2626309":{"bedroom_count":2,"bathroom_count":2,
+					"bed_configurations":[{"bed_types":[{"name_with_count":"2 queen beds"}]}]}},
+			"block":[
+				{"block_id":"2626303_1","room_id":2626303,"room_name":"Double Room","max_occupancy":2,"room_surface_in_m2":18},
+				{"block_id":"2626303_2","room_id":2626303,"room_name":"Double Room - Breakfast","max_occupancy":3},
+				{"block_id":"2626301_1","room_id":2626301,"name":"Bed in Dormitory","nr_adults":1}]}}`), &response)
+		So(err, ShouldBeNil)
+
+		service := &PropertyDetailsService{}
+		detail := service.extractDetail(models.Property{HotelID: 26263}, &response.Data)
+
+		Convey("Each room type is one unit, offered rooms first", func() {
+			So(len(detail.Units), ShouldEqual, 3)
+			double := detail.Units[0]
+			So(double.RoomID, ShouldEqual, 2626303)
+			So(double.Name, ShouldEqual, "Double Room")
+			So(double.MaxOccupancy, ShouldEqual, 3)
+			So(double.SizeM2, ShouldEqual, 18)
+			So(double.BedConfiguration, ShouldEqual, "1 large double bed or 2 single beds, 1 sofa bed")
+			So(double.Bedrooms, ShouldEqual, 1)
+			So(double.Bathrooms, ShouldEqual, 1)
+			So(double.BathroomType, ShouldEqual, models.BathroomPrivate)
+
+			dorm := detail.Units[1]
+			So(dorm.Name, ShouldEqual, "Bed in Dormitory")
+			So(dorm.MaxOccupancy, ShouldEqual, 1)
+			So(dorm.BathroomType, ShouldEqual, models.BathroomShared)
+			So(dorm.Bathrooms, ShouldEqual, 0)
+
+			So(detail.Units[2].RoomID, ShouldEqual, 2626309)
+			So(detail.Units[2].Position, ShouldEqual, 2)
+		})
+
+		Convey("The listing shows its largest unit, not the block count", func() {
+			So(detail.Bedrooms, ShouldEqual, 2)
+			So(detail.Bathrooms, ShouldEqual, 2)
+			So(detail.MaxOccupancy, ShouldEqual, 3)
+		})
+	})
+}
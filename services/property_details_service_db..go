@@ -2,95 +2,144 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"backend_rental/utils"
 	"backend_rental/models"
 	"github.com/beego/beego/v2/client/orm"
 )
 
+// ErrPropertyNotFound is returned when no listing exists for a property ID
+var ErrPropertyNotFound = errors.New("property not found")
+
+// propertyListed reports whether a listing exists for propertyID and was not soft-deleted
+func propertyListed(propertyID int64) bool {
+	return orm.NewOrm().QueryTable("rental_property").Filter("property_id", propertyID).Filter("deleted_at__isnull", true).Exist()
+}
+
 type PropertyDetailsServiceDB struct{}
 
+// LoadPropertyDetailsFromJSON upserts data/PropertyDetails.json keyed on property_id
 func (s *PropertyDetailsServiceDB) LoadPropertyDetailsFromJSON() error {
-	// Read the PropertyDetails.json file
-	data, err := ioutil.ReadFile("data/PropertyDetails.json")
+	_, err := utils.SeedPropertyDetails(utils.SeedModeUpsert)
+	return err
+}
+
+func (s *PropertyDetailsServiceDB) GetPropertyDetails(propertyID int64) (*models.PropertyDetails, error) {
+	o := orm.NewOrm()
+	propertyDetail := &models.PropertyDetails{PropertyID: propertyID}
+	
+	err := o.QueryTable("property_details").Filter("property_id", propertyID).One(propertyDetail)
+	if err == orm.ErrNoRows {
+		return nil, ErrPropertyNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read PropertyDetails.json: %v", err)
+		return nil, fmt.Errorf("failed to retrieve property details: %v", err)
 	}
 
-	var propertyDetails []models.PropertyDetails
-	err = json.Unmarshal(data, &propertyDetails)
-	if err != nil {
-		return fmt.Errorf("failed to parse PropertyDetails.json: %v", err)
+	propertyDetail.ImageUrls = []string{}
+	if propertyDetail.ImageUrlsRaw != "" {
+		if err := json.Unmarshal([]byte(propertyDetail.ImageUrlsRaw), &propertyDetail.ImageUrls); err != nil {
+			return nil, fmt.Errorf("failed to parse image URLs of property %d: %v", propertyID, err)
+		}
 	}
+	
+	return propertyDetail, nil
+}
 
-	fmt.Printf("Loaded %d property details from JSON\n", len(propertyDetails))
+// GetPropertyDocument returns one listing with its details and city, its latest price in
+// currency (currency::base when empty). Missing details or location leave the corresponding
+// fields empty; a missing listing is ErrPropertyNotFound.
+func (s *PropertyDetailsServiceDB) GetPropertyDocument(propertyID int64, currency string) (*models.PropertyDocument, error) {
+	converter, err := NewCurrencyConverter(currency)
+	if err != nil {
+		return nil, err
+	}
+	o := orm.NewOrm()
 
-	// Get database connection
-	db, err := orm.GetDB("default")
+	var property models.RentalProperty
+	err = o.QueryTable("rental_property").
+		Filter("property_id", propertyID).
+		Filter("deleted_at__isnull", true).
+		One(&property)
+	if err == orm.ErrNoRows {
+		return nil, ErrPropertyNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get database connection: %v", err)
+		return nil, fmt.Errorf("failed to retrieve property: %v", err)
 	}
 
-	// Start a transaction
-	tx, err := db.Begin()
+	document := &models.PropertyDocument{
+		PropertyID:   property.PropertyID,
+		Name:         property.Name,
+		PropertyType: property.PropertyType,
+		Bedrooms:     property.Bedrooms,
+		Bathrooms:    property.Bathrooms,
+		MaxOccupancy: property.MaxOccupancy,
+		CityID:       property.CityID,
+	}
+	amenities, err := loadPropertyAmenities([]int64{propertyID})
 	if err != nil {
-		return fmt.Errorf("failed to start transaction: %v", err)
+		return nil, err
+	}
+	document.Amenities = amenities[propertyID]
+	if document.Amenities == nil {
+		document.Amenities = models.AmenityList{}
 	}
 
-	// Prepare to defer transaction rollback or commit
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-			return
-		}
-		tx.Commit()
-	}()
+	document.Photos = []models.PropertyPhoto{}
+	_, err = o.QueryTable("property_photo").
+		Filter("property_id", propertyID).
+		OrderBy("kind", "position").
+		All(&document.Photos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve photos: %v", err)
+	}
 
-	// Clear existing data
-	_, err = tx.Exec("DELETE FROM property_details")
+	document.Units = []models.PropertyUnit{}
+	_, err = o.QueryTable("property_unit").
+		Filter("property_id", propertyID).
+		OrderBy("position").
+		All(&document.Units)
 	if err != nil {
-		return fmt.Errorf("failed to clear existing data: %v", err)
+		return nil, fmt.Errorf("failed to retrieve units: %v", err)
 	}
 
-	// Prepare insert statement
-	stmt, err := tx.Prepare(`
-		INSERT INTO property_details 
-		(property_id, description, review_score, review_count, review_score_word, image_type, image_urls) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`)
+	prices, err := latestPrices([]int64{propertyID})
 	if err != nil {
-		return fmt.Errorf("failed to prepare insert statement: %v", err)
+		return nil, err
 	}
-	defer stmt.Close()
-
-	// Insert new data
-	for _, detail := range propertyDetails {
-		_, err = stmt.Exec(
-			detail.PropertyID, 
-			detail.Description, 
-			detail.ReviewScore, 
-			detail.ReviewCount, 
-			detail.ReviewScoreWord, 
-			detail.ImageType, 
-			detail.ImageUrls,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert property detail %v: %v", detail.PropertyID, err)
-		}
+	if price, ok := prices[propertyID]; ok {
+		converter.Convert(&price)
+		document.Price = &price
 	}
 
-	fmt.Printf("Successfully inserted %d property details\n", len(propertyDetails))
-	return nil
-}
+	details, err := s.GetPropertyDetails(propertyID)
+	switch {
+	case err == nil:
+		document.Description = details.Description
+		document.Review = &models.PropertyReview{
+			Score: details.ReviewScore,
+			Count: details.ReviewCount,
+			Word:  details.ReviewScoreWord,
+		}
+		document.Images = &models.PropertyImages{
+			Type: details.ImageType,
+			Urls: details.ImageUrls,
+		}
+	case err != ErrPropertyNotFound:
+		return nil, err
+	}
 
-func (s *PropertyDetailsServiceDB) GetPropertyDetails(propertyID int64) (*models.PropertyDetails, error) {
-	o := orm.NewOrm()
-	propertyDetail := &models.PropertyDetails{PropertyID: propertyID}
-	
-	err := o.QueryTable("property_details").Filter("property_id", propertyID).One(propertyDetail)
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve property details: %v", err)
+	var location models.Location
+	err = o.QueryTable("location").Filter("city_id", property.CityID).One(&location)
+	switch {
+	case err == nil:
+		document.CityName = location.CityName
+		document.Country = location.Country
+	case err != orm.ErrNoRows:
+		return nil, fmt.Errorf("failed to retrieve location: %v", err)
 	}
-	
-	return propertyDetail, nil
-}
\ No newline at end of file
+
+	return document, nil
+}
@@ -70,6 +70,26 @@ func (s *PropertyDetailsServiceJSON) GeneratePropertyDetailsJSON(
 // 	return result
 // }
 
+// GenerateFromFiles combines the description, property and image files into data/PropertyDetails.json
+func (s *PropertyDetailsServiceJSON) GenerateFromFiles() error {
+	var descImages []map[string]interface{}
+	if err := readJSONFile("data/property_desc_image.json", &descImages); err != nil {
+		return err
+	}
+
+	var properties []map[string]interface{}
+	if err := readJSONFile("data/properties.json", &properties); err != nil {
+		return err
+	}
+
+	var propertyImages []map[string]interface{}
+	if err := readJSONFile("data/property_images.json", &propertyImages); err != nil {
+		return err
+	}
+
+	return s.GeneratePropertyDetailsJSON(descImages, properties, propertyImages)
+}
+
 func (s *PropertyDetailsServiceJSON) writeJSONFile(data []models.PropertyDetails) error {
 	file, err := json.MarshalIndent(data, "", " ")
 	if err != nil {
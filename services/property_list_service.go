@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"backend_rental/models"
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Page size bounds of the property list
+const (
+	DefaultPropertyPageSize = 20
+	MaxPropertyPageSize     = 100
+)
+
+// propertySortFields maps the sort keys accepted by the list endpoint to columns
+var propertySortFields = map[string]string{
+	"property_id":   "property_id",
+	"name":          "name",
+	"property_type": "property_type",
+	"bedrooms":      "bedrooms",
+	"bathrooms":     "bathrooms",
+	"city_id":       "city_id",
+}
+
+// PropertyListQuery holds the filters, sort order and page of a property list request
+type PropertyListQuery struct {
+	CityID       string
+	PropertyType string
+	MinBedrooms  *int
+	MaxBedrooms  *int
+	MinBathrooms *int
+	Amenities    []string
+	// CheckIn and CheckOut keep the listings that can be booked for the stay, priced in Stay
+	CheckIn      string
+	CheckOut     string
+	// Guests is matched against the nightly occupancy of a stay, or the largest unit without one
+	Guests       *int
+	// Currency the prices are converted to; currency::base when empty
+	Currency     string
+	Sort         []string
+	Page         int
+	PageSize     int
+}
+
+// PropertyListPage is the response envelope of the property list
+type PropertyListPage struct {
+	Data       []models.RentalProperty `json:"data"`
+	Total      int64                   `json:"total"`
+	Page       int                     `json:"page"`
+	PageSize   int                     `json:"pageSize"`
+	TotalPages int                     `json:"totalPages"`
+	Next       *string                 `json:"next"`
+	Prev       *string                 `json:"prev"`
+	Facets     []AmenityFacet          `json:"facets"`
+	Stay       *StaySearch             `json:"stay,omitempty"`
+}
+
+// ParsePropertyListQuery validates the query string of /v1/property/list
+func ParsePropertyListQuery(values url.Values) (*PropertyListQuery, error) {
+	q := &PropertyListQuery{
+		CityID:       strings.TrimSpace(values.Get("city_id")),
+		PropertyType: strings.TrimSpace(values.Get("property_type")),
+		Page:         1,
+		PageSize:     DefaultPropertyPageSize,
+	}
+
+	// amenity takes slugs or provider names, comma-separated or repeated; all must match
+	seen := map[string]bool{}
+	for _, value := range values["amenity"] {
+		for _, name := range strings.Split(value, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			slug := models.NormalizeAmenity(name).Slug
+			if !models.AmenitySlugPattern.MatchString(slug) {
+				return nil, fmt.Errorf("invalid amenity %q", name)
+			}
+			if !seen[slug] {
+				seen[slug] = true
+				q.Amenities = append(q.Amenities, slug)
+			}
+		}
+	}
+
+	var err error
+	if q.MinBedrooms, err = optionalInt(values, "min_bedrooms"); err != nil {
+		return nil, err
+	}
+	if q.MaxBedrooms, err = optionalInt(values, "max_bedrooms"); err != nil {
+		return nil, err
+	}
+	if q.MinBathrooms, err = optionalInt(values, "min_bathrooms"); err != nil {
+		return nil, err
+	}
+	if q.MinBedrooms != nil && q.MaxBedrooms != nil && *q.MinBedrooms > *q.MaxBedrooms {
+		return nil, fmt.Errorf("min_bedrooms must not exceed max_bedrooms")
+	}
+	if q.Currency, err = ParseCurrency(values); err != nil {
+		return nil, err
+	}
+	if err := parseStay(values, q); err != nil {
+		return nil, err
+	}
+
+	if page, err := optionalInt(values, "page"); err != nil {
+		return nil, err
+	} else if page != nil {
+		if *page < 1 {
+			return nil, fmt.Errorf("page must be at least 1")
+		}
+		q.Page = *page
+	}
+	if pageSize, err := optionalInt(values, "page_size"); err != nil {
+		return nil, err
+	} else if pageSize != nil {
+		if *pageSize < 1 || *pageSize > MaxPropertyPageSize {
+			return nil, fmt.Errorf("page_size must be between 1 and %d", MaxPropertyPageSize)
+		}
+		q.PageSize = *pageSize
+	}
+
+	if sort := strings.TrimSpace(values.Get("sort")); sort != "" {
+		for _, key := range strings.Split(sort, ",") {
+			key = strings.TrimSpace(key)
+			column, ok := propertySortFields[strings.TrimPrefix(key, "-")]
+			if !ok {
+				return nil, fmt.Errorf("unsupported sort field %q", key)
+			}
+			if strings.HasPrefix(key, "-") {
+				column = "-" + column
+			}
+			q.Sort = append(q.Sort, column)
+		}
+	}
+
+	return q, nil
+}
+
+func optionalInt(values url.Values, name string) (*int, error) {
+	raw := strings.TrimSpace(values.Get(name))
+	if raw == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+	return &n, nil
+}
+
+// parseStay validates check_in, check_out and guests
+func parseStay(values url.Values, q *PropertyListQuery) error {
+	var err error
+	if q.CheckIn, err = optionalDate(values, "check_in"); err != nil {
+		return err
+	}
+	if q.CheckOut, err = optionalDate(values, "check_out"); err != nil {
+		return err
+	}
+	if q.Guests, err = optionalInt(values, "guests"); err != nil {
+		return err
+	}
+	if q.Guests != nil && *q.Guests < 1 {
+		return fmt.Errorf("guests must be at least 1")
+	}
+	if (q.CheckIn == "") != (q.CheckOut == "") {
+		return fmt.Errorf("check_in and check_out must be given together")
+	}
+	if q.CheckIn == "" {
+		return nil
+	}
+	nights := len(StayNights(q.CheckIn, q.CheckOut))
+	if nights == 0 {
+		return fmt.Errorf("check_out must be after check_in")
+	}
+	if nights > MaxStayNights {
+		return fmt.Errorf("a stay can be at most %d nights", MaxStayNights)
+	}
+	return nil
+}
+
+type PropertyListService struct {
+	// Stays answers stay searches; NewStayService when nil
+	Stays *StayService
+}
+
+// querySeter applies the filters of q to the non-deleted rental properties
+func (s *PropertyListService) querySeter(q *PropertyListQuery) (orm.QuerySeter, error) {
+	qs := orm.NewOrm().QueryTable("rental_property").Filter("deleted_at__isnull", true)
+	if q.CityID != "" {
+		qs = qs.Filter("city_id", q.CityID)
+	}
+	if q.PropertyType != "" {
+		qs = qs.Filter("property_type__iexact", q.PropertyType)
+	}
+	if q.MinBedrooms != nil {
+		qs = qs.Filter("bedrooms__gte", *q.MinBedrooms)
+	}
+	if q.MaxBedrooms != nil {
+		qs = qs.Filter("bedrooms__lte", *q.MaxBedrooms)
+	}
+	if q.MinBathrooms != nil {
+		qs = qs.Filter("bathrooms__gte", *q.MinBathrooms)
+	}
+	if len(q.Amenities) > 0 {
+		filter, err := amenityFilterSQL(q.Amenities)
+		if err != nil {
+			return nil, err
+		}
+		qs = qs.FilterRaw("property_id", filter)
+	}
+	// A stay search matches guests night by night in property_calendar instead
+	if q.Guests != nil && q.CheckIn == "" {
+		qs = qs.Filter("max_occupancy__gte", *q.Guests)
+	}
+	return qs, nil
+}
+
+// List returns one page of rental properties; links are left for the caller to fill in.
+// A stay search leaves out before counting the listings reservations, the calendar and
+// earlier lookups rule out and quotes the rest on the page, dropping those a live lookup
+// finds unbookable from the page only; total and facets are then upper bounds.
+func (s *PropertyListService) List(ctx context.Context, q *PropertyListQuery) (*PropertyListPage, error) {
+	converter, err := NewCurrencyConverter(q.Currency)
+	if err != nil {
+		return nil, err
+	}
+	qs, err := s.querySeter(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var stays *StayService
+	if q.CheckIn != "" {
+		stays = s.Stays
+		if stays == nil {
+			stays = NewStayService()
+		}
+		excluded, err := stays.excludedProperties(q)
+		if err != nil {
+			return nil, err
+		}
+		if len(excluded) > 0 {
+			qs = qs.FilterRaw("property_id", stayFilterSQL(excluded))
+		}
+	}
+
+	total, err := qs.Count()
+	if err != nil {
+		return nil, fmt.Errorf("error counting properties: %v", err)
+	}
+
+	// Order by id last so pages are stable when sort keys tie
+	order := append(append([]string{}, q.Sort...), "id")
+	if len(q.Sort) == 0 {
+		order = []string{"property_id", "id"}
+	}
+
+	properties := []models.RentalProperty{}
+	offset := (q.Page - 1) * q.PageSize
+	if _, err := qs.OrderBy(order...).Limit(q.PageSize, offset).All(&properties); err != nil {
+		return nil, fmt.Errorf("error fetching properties: %v", err)
+	}
+
+	var search *StaySearch
+	if stays != nil {
+		ids := make([]int64, len(properties))
+		for i, property := range properties {
+			ids[i] = property.PropertyID
+		}
+		quotes, stay, err := stays.Quote(ctx, ids, q, converter)
+		if err != nil {
+			return nil, err
+		}
+		search = stay
+		search.TotalIsUpperBound = true
+		bookable := properties[:0]
+		for _, property := range properties {
+			quote, ok := quotes[property.PropertyID]
+			if !ok {
+				search.Dropped++
+				continue
+			}
+			property.Stay = &quote
+			bookable = append(bookable, property)
+		}
+		properties = bookable
+	}
+
+	if err := AttachAmenities(properties); err != nil {
+		return nil, err
+	}
+	if err := AttachPrices(properties, converter); err != nil {
+		return nil, err
+	}
+
+	// Facets count amenities across every matching listing, not just this page
+	var matching orm.ParamsList
+	if _, err := qs.Limit(-1).ValuesFlat(&matching, "property_id"); err != nil {
+		return nil, fmt.Errorf("error fetching matching properties: %v", err)
+	}
+	ids := make([]int64, 0, len(matching))
+	for _, id := range matching {
+		if n, err := strconv.ParseInt(fmt.Sprint(id), 10, 64); err == nil {
+			ids = append(ids, n)
+		}
+	}
+	facets, err := amenityFacets(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int((total + int64(q.PageSize) - 1) / int64(q.PageSize))
+	return &PropertyListPage{
+		Data:       properties,
+		Total:      total,
+		Page:       q.Page,
+		PageSize:   q.PageSize,
+		TotalPages: totalPages,
+		Facets:     facets,
+		Stay:       search,
+	}, nil
+}
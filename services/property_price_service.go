@@ -0,0 +1,184 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend_rental/models"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/lib/pq"
+)
+
+// PriceHistoryQuery narrows the price history of a property. Dates are YYYY-MM-DD.
+type PriceHistoryQuery struct {
+	// CheckIn keeps the quotes of one stay
+	CheckIn string
+	// From and To bound the day the quotes were fetched, both inclusive
+	From string
+	To   string
+	// Currency the amounts are converted to; currency::base when empty
+	Currency string
+}
+
+// PriceHistory is the response of /v1/properties/{id}/prices, ordered by check-in date
+// and then by fetch time
+type PriceHistory struct {
+	PropertyID int64                  `json:"propertyId"`
+	Data       []models.PropertyPrice `json:"data"`
+}
+
+// ParsePriceHistoryQuery validates the query string of /v1/properties/{id}/prices
+func ParsePriceHistoryQuery(values url.Values) (*PriceHistoryQuery, error) {
+	q := &PriceHistoryQuery{}
+	var err error
+	if q.CheckIn, err = optionalDate(values, "check_in"); err != nil {
+		return nil, err
+	}
+	if q.From, err = optionalDate(values, "from"); err != nil {
+		return nil, err
+	}
+	if q.To, err = optionalDate(values, "to"); err != nil {
+		return nil, err
+	}
+	if q.From != "" && q.To != "" && q.From > q.To {
+		return nil, fmt.Errorf("from must not be after to")
+	}
+	if q.Currency, err = ParseCurrency(values); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func optionalDate(values url.Values, name string) (string, error) {
+	raw := strings.TrimSpace(values.Get(name))
+	if raw == "" {
+		return "", nil
+	}
+	if _, err := time.Parse("2006-01-02", raw); err != nil {
+		return "", fmt.Errorf("%s must be a date like 2026-11-17", name)
+	}
+	return raw, nil
+}
+
+type PropertyPriceService struct{}
+
+// History returns the quotes stored for a property; like the calendar, a property that was
+// never listed or has been removed is ErrPropertyNotFound
+func (s *PropertyPriceService) History(propertyID int64, q *PriceHistoryQuery) (*PriceHistory, error) {
+	if !propertyListed(propertyID) {
+		return nil, ErrPropertyNotFound
+	}
+	converter, err := NewCurrencyConverter(q.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	where := []string{"property_id = $1"}
+	args := []interface{}{propertyID}
+	for _, filter := range []struct {
+		condition string
+		value     string
+	}{
+		{"check_in = $%d::date", q.CheckIn},
+		{"fetched_at >= $%d::date", q.From},
+		{"fetched_at < $%d::date + 1", q.To},
+	} {
+		if filter.value == "" {
+			continue
+		}
+		args = append(args, filter.value)
+		where = append(where, fmt.Sprintf(filter.condition, len(args)))
+	}
+
+	rows, err := db.Query(`
+		SELECT to_char(check_in, 'YYYY-MM-DD'), to_char(check_out, 'YYYY-MM-DD'), fetched_at,
+			currency, gross, strikethrough, taxes
+		FROM property_price
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY check_in, fetched_at`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve prices: %v", err)
+	}
+	defer rows.Close()
+
+	history := &PriceHistory{PropertyID: propertyID, Data: []models.PropertyPrice{}}
+	for rows.Next() {
+		price := models.PropertyPrice{PropertyID: propertyID}
+		err := rows.Scan(&price.CheckIn, &price.CheckOut, &price.FetchedAt,
+			&price.Currency, &price.Gross, &price.Strikethrough, &price.Taxes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read price of property %d: %v", propertyID, err)
+		}
+		converter.Convert(&price)
+		history.Data = append(history.Data, price)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prices: %v", err)
+	}
+	return history, nil
+}
+
+// latestPrices returns the most recent quote of each property; of quotes fetched together
+// the earliest stay wins
+func latestPrices(propertyIDs []int64) (map[int64]models.PropertyPrice, error) {
+	prices := make(map[int64]models.PropertyPrice, len(propertyIDs))
+	if len(propertyIDs) == 0 {
+		return prices, nil
+	}
+
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT DISTINCT ON (property_id) property_id, to_char(check_in, 'YYYY-MM-DD'),
+			to_char(check_out, 'YYYY-MM-DD'), fetched_at, currency, gross, strikethrough, taxes
+		FROM property_price
+		WHERE property_id = ANY($1)
+		ORDER BY property_id, fetched_at DESC, check_in`, pq.Array(propertyIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prices: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var price models.PropertyPrice
+		err := rows.Scan(&price.PropertyID, &price.CheckIn, &price.CheckOut, &price.FetchedAt,
+			&price.Currency, &price.Gross, &price.Strikethrough, &price.Taxes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read price: %v", err)
+		}
+		prices[price.PropertyID] = price
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prices: %v", err)
+	}
+	return prices, nil
+}
+
+// AttachPrices fills in the latest Price of each property, converted by converter
+func AttachPrices(properties []models.RentalProperty, converter *CurrencyConverter) error {
+	ids := make([]int64, len(properties))
+	for i, property := range properties {
+		ids[i] = property.PropertyID
+	}
+	prices, err := latestPrices(ids)
+	if err != nil {
+		return err
+	}
+	for i := range properties {
+		if price, ok := prices[properties[i].PropertyID]; ok {
+			converter.Convert(&price)
+			properties[i].Price = &price
+		}
+	}
+	return nil
+}
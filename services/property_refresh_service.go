@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// JobKindPropertyRefresh is the job kind of the scheduled listings refresh
+const JobKindPropertyRefresh = "property-refresh"
+
+const (
+	// DefaultRefreshDeleteAfterMisses is how many refreshes in a row must miss a listing
+	// before it is soft-deleted when scheduler::delete_after_misses is not set
+	DefaultRefreshDeleteAfterMisses = 3
+	// maxRefreshPages stops paging a search that never reports its last page
+	maxRefreshPages = 50
+)
+
+// PropertyRefreshService re-runs the property search per city and applies only the
+// differences to rental_property and data/properties.json
+type PropertyRefreshService struct {
+	Provider       utils.ListingsProvider
+	PropertiesPath string
+	Progress       ProgressReporter
+	// DeleteAfterMisses is how many refreshes in a row must miss a listing before it is
+	// soft-deleted; below 1 means 1
+	DeleteAfterMisses int
+}
+
+func NewPropertyRefreshService() *PropertyRefreshService {
+	return &PropertyRefreshService{
+		Provider:          utils.NewListingsProvider(),
+		PropertiesPath:    filepath.Join("data", "properties.json"),
+		DeleteAfterMisses: beego.AppConfig.DefaultInt("scheduler::delete_after_misses", DefaultRefreshDeleteAfterMisses),
+	}
+}
+
+// RefreshAllCities syncs every city in the location table and returns one history row per city
+func (s *PropertyRefreshService) RefreshAllCities(ctx context.Context) ([]models.PropertyRefresh, error) {
+	var cities []models.Location
+	if _, err := orm.NewOrm().QueryTable("location").Limit(-1).All(&cities); err != nil {
+		return nil, fmt.Errorf("error fetching cities from database: %v", err)
+	}
+
+	progress := progressOrNoop(s.Progress)
+	progress.SetTotal(len(cities))
+
+	checkIn := time.Now().AddDate(0, 0, 30).Format("2006-01-02")
+	checkOut := time.Now().AddDate(0, 0, 31).Format("2006-01-02")
+
+	var history []models.PropertyRefresh
+	totals := map[string]int{}
+	for _, city := range cities {
+		if err := ctx.Err(); err != nil {
+			return history, err
+		}
+
+		refresh := s.RefreshCity(ctx, city, checkIn, checkOut)
+		history = append(history, refresh)
+		progress.Advance(1)
+		if refresh.Status == models.RefreshStatusFailed {
+			progress.AddError(fmt.Errorf("city %s: %s", city.CityName, refresh.Error))
+		}
+
+		totals["inserted"] += refresh.Inserted
+		totals["updated"] += refresh.Updated
+		totals["deleted"] += refresh.Deleted
+		totals["missed"] += refresh.Missed
+		totals["unchanged"] += refresh.Unchanged
+		for key, n := range totals {
+			progress.SetCount(key, n)
+		}
+	}
+
+	fmt.Printf("Refresh finished for %d cities: %+v\n", len(cities), totals)
+	return history, nil
+}
+
+// RefreshCity fetches the current listings of one city, applies the diff and records the run
+func (s *PropertyRefreshService) RefreshCity(ctx context.Context, city models.Location, checkIn, checkOut string) models.PropertyRefresh {
+	refresh := models.PropertyRefresh{
+		CityID:    city.CityID,
+		CityName:  city.CityName,
+		StartedAt: time.Now(),
+	}
+
+	err := s.refreshCity(ctx, city, checkIn, checkOut, &refresh)
+	refresh.FinishedAt = time.Now()
+	if err != nil {
+		fmt.Printf("Refresh failed for %s: %v\n", city.CityName, err)
+		refresh.Status = models.RefreshStatusFailed
+		refresh.Error = err.Error()
+	} else {
+		refresh.Status = models.RefreshStatusSucceeded
+		fmt.Printf("Refreshed %s: %d fetched, %d inserted, %d updated, %d deleted, %d missed, %d unchanged\n",
+			city.CityName, refresh.Fetched, refresh.Inserted, refresh.Updated, refresh.Deleted, refresh.Missed, refresh.Unchanged)
+	}
+
+	if _, err := orm.NewOrm().Insert(&refresh); err != nil {
+		fmt.Printf("Warning: Failed to record refresh history for %s: %v\n", city.CityName, err)
+	}
+	return refresh
+}
+
+func (s *PropertyRefreshService) refreshCity(ctx context.Context, city models.Location, checkIn, checkOut string, refresh *models.PropertyRefresh) error {
+	fetched, err := s.searchCity(ctx, city.CityID, checkIn, checkOut)
+	if err != nil {
+		return err
+	}
+	// Stored listings missing from the search count as missed, so a search that returned
+	// nothing must not be taken for a city without listings
+	if len(fetched) == 0 {
+		return fmt.Errorf("search returned no properties; nothing was changed")
+	}
+	quotedAt := time.Now()
+
+	for i := range fetched {
+		fetched[i].CityID = city.CityID
+		quote(&fetched[i], checkIn, checkOut, quotedAt)
+	}
+	refresh.Fetched = len(fetched)
+
+	missed, err := s.applyDiff(city.CityID, fetched, refresh)
+	if err != nil {
+		return err
+	}
+	s.recordPrices(city, fetched)
+	return s.mergePropertiesFile(city.CityID, fetched, missed)
+}
+
+// searchCity pages through the search of one city, so the diff sees every listing. Paging
+// stops at the last page the provider reports or, when it reports none, at a page that adds
+// no listing. A failed page fails the whole search.
+func (s *PropertyRefreshService) searchCity(ctx context.Context, cityID, checkIn, checkOut string) ([]models.Property, error) {
+	var fetched []models.Property
+	seen := map[int]bool{}
+	for page := 1; page <= maxRefreshPages; page++ {
+		response, err := s.Provider.FetchPropertiesForCity(ctx, cityID, checkIn, checkOut, page)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching page %d of properties: %v", page, err)
+		}
+		if response.Status != nil && !*response.Status {
+			return nil, fmt.Errorf("provider reported a failed search on page %d (status false); nothing was changed", page)
+		}
+
+		added := 0
+		for _, property := range response.Data {
+			if !seen[property.HotelID] {
+				seen[property.HotelID] = true
+				fetched = append(fetched, property)
+				added++
+			}
+		}
+		if added == 0 || (response.Meta.TotalPages > 0 && page >= response.Meta.TotalPages) {
+			return fetched, nil
+		}
+	}
+	return nil, fmt.Errorf("search did not end within %d pages; nothing was changed", maxRefreshPages)
+}
+
+// recordPrices appends the quoted prices to the price history; a failure only loses
+// this run's data points, so it does not fail the refresh
+func (s *PropertyRefreshService) recordPrices(city models.Location, fetched []models.Property) {
+	db, err := orm.GetDB("default")
+	if err == nil {
+		_, err = utils.SavePropertyPrices(db, utils.QuotedPrices(fetched))
+	}
+	if err != nil {
+		fmt.Printf("Warning: Failed to record prices for %s: %v\n", city.CityName, err)
+	}
+}
+
+// applyDiff inserts new properties, updates renamed or reappearing ones and counts a miss
+// for the ones the search no longer returns, soft-deleting those missed DeleteAfterMisses
+// refreshes in a row. It returns the missed listings that are not deleted yet.
+func (s *PropertyRefreshService) applyDiff(cityID string, fetched []models.Property, refresh *models.PropertyRefresh) (map[int64]bool, error) {
+	o := orm.NewOrm()
+	txOrm, err := o.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	var stored []models.RentalProperty
+	if _, err := txOrm.QueryTable("rental_property").Filter("city_id", cityID).Limit(-1).All(&stored); err != nil {
+		txOrm.Rollback()
+		return nil, fmt.Errorf("failed to load stored properties: %v", err)
+	}
+
+	storedByID := make(map[int64]*models.RentalProperty, len(stored))
+	for i := range stored {
+		storedByID[stored[i].PropertyID] = &stored[i]
+	}
+
+	seen := make(map[int64]bool, len(fetched))
+	for _, property := range fetched {
+		id := int64(property.HotelID)
+		seen[id] = true
+
+		existing, ok := storedByID[id]
+		// Listings near a city border can show up in both cities' searches; the first
+		// city to store one keeps it
+		if !ok && txOrm.QueryTable("rental_property").Filter("property_id", id).Exist() {
+			refresh.Unchanged++
+			continue
+		}
+		switch {
+		case !ok:
+			if _, err := txOrm.Insert(&models.RentalProperty{
+				CityID:     cityID,
+				PropertyID: id,
+				Name:       property.PropertyName,
+			}); err != nil {
+				txOrm.Rollback()
+				return nil, fmt.Errorf("failed to insert property %d: %v", id, err)
+			}
+			refresh.Inserted++
+		case existing.DeletedAt != nil || existing.Name != property.PropertyName:
+			existing.Name = property.PropertyName
+			existing.DeletedAt = nil
+			existing.MissedRefreshes = 0
+			if _, err := txOrm.Update(existing, "Name", "DeletedAt", "MissedRefreshes"); err != nil {
+				txOrm.Rollback()
+				return nil, fmt.Errorf("failed to update property %d: %v", id, err)
+			}
+			refresh.Updated++
+		case existing.MissedRefreshes > 0:
+			existing.MissedRefreshes = 0
+			if _, err := txOrm.Update(existing, "MissedRefreshes"); err != nil {
+				txOrm.Rollback()
+				return nil, fmt.Errorf("failed to update property %d: %v", id, err)
+			}
+			refresh.Unchanged++
+		default:
+			refresh.Unchanged++
+		}
+	}
+
+	now := time.Now()
+	missed := map[int64]bool{}
+	for i := range stored {
+		if seen[stored[i].PropertyID] || stored[i].DeletedAt != nil {
+			continue
+		}
+		stored[i].MissedRefreshes++
+		columns := []string{"MissedRefreshes"}
+		if stored[i].MissedRefreshes >= max(s.DeleteAfterMisses, 1) {
+			stored[i].DeletedAt = &now
+			columns = append(columns, "DeletedAt")
+			refresh.Deleted++
+		} else {
+			missed[stored[i].PropertyID] = true
+			refresh.Missed++
+		}
+		if _, err := txOrm.Update(&stored[i], columns...); err != nil {
+			txOrm.Rollback()
+			return nil, fmt.Errorf("failed to record the miss of property %d: %v", stored[i].PropertyID, err)
+		}
+	}
+
+	if err := txOrm.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return missed, nil
+}
+
+// mergePropertiesFile replaces the city's entries in data/properties.json with the fetched
+// set, keeping the entries of missed listings that are not deleted yet
+func (s *PropertyRefreshService) mergePropertiesFile(cityID string, fetched []models.Property, missed map[int64]bool) error {
+	var properties []models.Property
+	data, err := os.ReadFile(s.PropertiesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading properties file: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &properties); err != nil {
+			return fmt.Errorf("error unmarshaling properties data: %v", err)
+		}
+	}
+
+	merged := make([]models.Property, 0, len(properties)+len(fetched))
+	for _, property := range properties {
+		if property.CityID != cityID || missed[int64(property.HotelID)] {
+			merged = append(merged, property)
+		}
+	}
+	merged = append(merged, fetched...)
+
+	data, err = json.MarshalIndent(merged, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error marshaling properties data: %v", err)
+	}
+	if err := os.WriteFile(s.PropertiesPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing properties to file: %v", err)
+	}
+	return nil
+}
+
+// RefreshHistory returns the most recent refresh runs, optionally for a single city
+func (s *PropertyRefreshService) RefreshHistory(cityID string, limit int) ([]models.PropertyRefresh, error) {
+	qs := orm.NewOrm().QueryTable("property_refresh").OrderBy("-started_at").Limit(limit)
+	if cityID != "" {
+		qs = qs.Filter("city_id", cityID)
+	}
+
+	var history []models.PropertyRefresh
+	if _, err := qs.All(&history); err != nil {
+		return nil, fmt.Errorf("error fetching refresh history: %v", err)
+	}
+	return history, nil
+}
+
+// LastSyncPerCity returns the latest refresh run of every city that has been refreshed
+func (s *PropertyRefreshService) LastSyncPerCity() ([]models.PropertyRefresh, error) {
+	var history []models.PropertyRefresh
+	_, err := orm.NewOrm().Raw(`
+		SELECT DISTINCT ON (city_id) *
+		FROM property_refresh
+		ORDER BY city_id, started_at DESC
+	`).QueryRows(&history)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching last sync per city: %v", err)
+	}
+	return history, nil
+}
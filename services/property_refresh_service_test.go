@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// searchOnlyProvider answers the property search with fixed pages and an empty page past them
+type searchOnlyProvider struct {
+	utils.ListingsProvider
+	pages []*models.PropertyResponse
+	calls int
+}
+
+func (p *searchOnlyProvider) FetchPropertiesForCity(ctx context.Context, locationId string, checkIn, checkOut string, page int) (*models.PropertyResponse, error) {
+	p.calls++
+	if page > len(p.pages) {
+		return &models.PropertyResponse{Data: []models.Property{}}, nil
+	}
+	return p.pages[page-1], nil
+}
+
+// TestRefreshEmptySearch checks that a search returning nothing fails the refresh before
+// any stored listing is touched
+func TestRefreshEmptySearch(t *testing.T) {
+	Convey("Subject: refreshing a city whose search returns nothing\n", t, func() {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "properties.json")
+		So(os.WriteFile(path, []byte(`[{"name":"Canal House","id":26263,"cityId":"ams"}]`), 0644), ShouldBeNil)
+		city := models.Location{CityID: "ams", CityName: "Amsterdam"}
+		failed := false
+
+		for name, response := range map[string]*models.PropertyResponse{
+			"an empty data list": {Data: []models.Property{}},
+			"status false":       {Status: &failed, Data: []models.Property{{PropertyName: "Canal House", HotelID: 26263}}},
+		} {
+			Convey("The refresh fails on "+name, func() {
+				service := &PropertyRefreshService{Provider: &searchOnlyProvider{pages: []*models.PropertyResponse{response}}, PropertiesPath: path}
+				var refresh models.PropertyRefresh
+				err := service.refreshCity(context.Background(), city, "2026-11-17", "2026-11-18", &refresh)
+				So(err, ShouldNotBeNil)
+				So(refresh.Deleted, ShouldEqual, 0)
+
+				data, err := os.ReadFile(path)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldContainSubstring, "Canal House")
+			})
+		}
+
+		Convey("The file provider finding no listings for the city fails the refresh too", func() {
+			fixtures := t.TempDir()
+			So(os.WriteFile(filepath.Join(fixtures, "properties.json"), []byte(`[{"name":"Elsewhere","id":1,"cityId":"other"}]`), 0644), ShouldBeNil)
+			service := &PropertyRefreshService{Provider: utils.NewFileListingsProvider(fixtures), PropertiesPath: path}
+			err := service.refreshCity(context.Background(), city, "2026-11-17", "2026-11-18", &models.PropertyRefresh{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "no properties")
+		})
+	})
+}
+
+// TestRefreshSearchPages checks that a refresh diffs against every page of the search
+func TestRefreshSearchPages(t *testing.T) {
+	Convey("Subject: paging through the search of a city\n", t, func() {
+		page := func(totalPages int, ids ...int) *models.PropertyResponse {
+			response := &models.PropertyResponse{Data: []models.Property{}}
+			response.Meta.TotalPages = totalPages
+			for _, id := range ids {
+				response.Data = append(response.Data, models.Property{HotelID: id})
+			}
+			return response
+		}
+
+		Convey("Pages are read up to the last one the provider reports", func() {
+			provider := &searchOnlyProvider{pages: []*models.PropertyResponse{page(3, 1, 2), page(3, 3, 4), page(3, 5)}}
+			service := &PropertyRefreshService{Provider: provider}
+			fetched, err := service.searchCity(context.Background(), "ams", "2026-11-17", "2026-11-18")
+			So(err, ShouldBeNil)
+			So(len(fetched), ShouldEqual, 5)
+			So(provider.calls, ShouldEqual, 3)
+		})
+
+		Convey("Without a page count, a page adding no listing ends the search", func() {
+			provider := &searchOnlyProvider{pages: []*models.PropertyResponse{page(0, 1, 2), page(0, 2, 3), page(0, 2, 3)}}
+			service := &PropertyRefreshService{Provider: provider}
+			fetched, err := service.searchCity(context.Background(), "ams", "2026-11-17", "2026-11-18")
+			So(err, ShouldBeNil)
+			So(len(fetched), ShouldEqual, 3)
+			So(provider.calls, ShouldEqual, 3)
+		})
+
+		Convey("A failed page fails the whole search", func() {
+			failed := page(3)
+			failed.Status = new(bool)
+			provider := &searchOnlyProvider{pages: []*models.PropertyResponse{page(3, 1, 2), failed}}
+			service := &PropertyRefreshService{Provider: provider}
+			_, err := service.searchCity(context.Background(), "ams", "2026-11-17", "2026-11-18")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "page 2")
+		})
+	})
+}
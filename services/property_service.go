@@ -3,6 +3,7 @@ package services
 import (
     "context"
     "encoding/json"
+    "errors"
     "fmt"
     "os"
     "path/filepath"
@@ -12,16 +13,16 @@ import (
 )
 
 type PropertyService struct {
-    RateLimiter *utils.RateLimiterConfig
-    ApiClient   *utils.ApiClient
+    Provider    utils.ListingsProvider
     StoragePath string
     CitiesPath  string
+    Progress    ProgressReporter
+    Selection   Selection
 }
 
 func NewPropertyService() *PropertyService {
     return &PropertyService{
-        RateLimiter: &utils.LenientRateLimiter,
-        ApiClient:   utils.NewApiClient(),
+        Provider:    utils.NewListingsProvider(),
         StoragePath: filepath.Join("data", "properties.json"),
         CitiesPath:  filepath.Join("data", "cities.json"),
     }
@@ -88,51 +89,107 @@ func (s *PropertyService) LoadCities() ([]models.Location, error) {
 
 //     return allProperties, nil
 // }
-func (s *PropertyService) FetchPropertiesForCities() ([]models.Property, error) {
+// SelectCities returns the cities the next FetchPropertiesForCities run covers
+func (s *PropertyService) SelectCities() ([]models.Location, error) {
     cities, err := s.LoadCities()
     if err != nil {
         return nil, err
     }
 
+    var done map[string]bool
+    if s.Selection.Missing {
+        done, err = storedIDs(s.StoragePath, func(p models.Property) string { return p.CityID })
+        if err != nil {
+            return nil, err
+        }
+    }
+    return s.Selection.SelectCities(cities, done), nil
+}
+
+func (s *PropertyService) FetchPropertiesForCities(ctx context.Context) ([]models.Property, error) {
+    utils.SetCacheMode(s.Provider, s.Selection.Cache)
+    cities, err := s.SelectCities()
+    if err != nil {
+        return nil, err
+    }
+
+    progress := progressOrNoop(s.Progress)
+    progress.SetTotal(len(cities))
+
     var allProperties []models.Property
-    ctx := context.Background()
+    fetched := map[string]bool{}
     checkIn := time.Now().AddDate(0, 0, 30).Format("2006-01-02")
     checkOut := time.Now().AddDate(0, 0, 31).Format("2006-01-02")
 
-    // Create a rate limiter from the configuration
-    limiter := utils.NewRateLimiter(s.RateLimiter.Limit, s.RateLimiter.BurstSize)
+    for i, city := range cities {
+        if !s.Selection.Budget.Take() {
+            fmt.Printf("Call budget exhausted after %d calls; %d cities left for the next run\n",
+                s.Selection.Budget.Used(), len(cities)-i)
+            break
+        }
 
-    for _, city := range cities {
-        // Use the centralized rate limiter wait method
-        err := utils.WaitForRateLimit(ctx, limiter)
-        if err != nil {
-            return nil, fmt.Errorf("rate limiter error: %v", err)
+        if err := ctx.Err(); err != nil {
+            return nil, err
         }
 
-        response, err := s.ApiClient.FetchPropertiesForCity(city.CityID, checkIn, checkOut)
+        // A search the response cache answered costs no provider call, so its unit is refunded
+        searchCtx, count := utils.WithCallCount(ctx)
+        response, err := s.Provider.FetchPropertiesForCity(searchCtx, city.CityID, checkIn, checkOut, 1)
+        if count.CachedOnly() {
+            s.Selection.Budget.Refund()
+        }
+        quotedAt := time.Now()
+        progress.Advance(1)
         if err != nil {
             fmt.Printf("Error fetching properties for %s: %v\n", city.CityName, err)
+            progress.AddError(fmt.Errorf("city %s: %v", city.CityName, err))
+            if errors.Is(err, utils.ErrQuotaExhausted) {
+                fmt.Printf("Provider quota exhausted; %d cities left for the next run\n", len(cities)-i)
+                break
+            }
             continue
         }
+        // Only a fetched city replaces its stored properties
+        fetched[city.CityID] = true
 
         fmt.Printf("Fetched %d properties for %s\n", len(response.Data), city.CityName)
 
         for _, property := range response.Data {
             property.CityID = city.CityID
+            quote(&property, checkIn, checkOut, quotedAt)
             allProperties = append(allProperties, property)
         }
+        progress.SetCount("properties", len(allProperties))
     }
 
     fmt.Printf("Total properties fetched: %d\n", len(allProperties))
 
     // Save to file
-    err = s.SavePropertiesToFile(allProperties)
+    saved := allProperties
+    if s.Selection.Partial() {
+        saved, err = mergeRecords(s.StoragePath, allProperties, func(p models.Property) bool {
+            return fetched[p.CityID]
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    err = s.SavePropertiesToFile(saved)
     if err != nil {
         fmt.Printf("Warning: Failed to save properties: %v\n", err)
     }
 
     return allProperties, nil
 }
+
+// quote records the stay and time of the search that returned property
+func quote(property *models.Property, checkIn, checkOut string, at time.Time) {
+    property.QuotedCheckIn = checkIn
+    property.QuotedCheckOut = checkOut
+    property.QuotedAt = &at
+}
+
 func (s *PropertyService) SavePropertiesToFile(properties []models.Property) error {
     data, err := json.MarshalIndent(properties, "", "    ")
     if err != nil {
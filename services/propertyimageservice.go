@@ -2,165 +2,260 @@ package services
 
 import (
     "context"
-    "encoding/json"
     "fmt"
-    "io"
     "log"
-    "net/http"
     "time"
     "strconv"
     "backend_rental/models"
     "backend_rental/utils"
-    "golang.org/x/time/rate"
+)
+
+// Default locations of the image stage input and output
+const (
+    PropertiesFilePath      = "data/properties.json"
+    PropertyDetailsFilePath = "data/property_details.json"
+    PropertyImagesFilePath  = "data/property_images.json"
 )
 
 type PropertyImageService struct {
-    apiBaseURL   string
-    apiKey       string
-    rateLimiter  *rate.Limiter
+    provider     utils.ListingsProvider
+    Progress     ProgressReporter
+    Selection    Selection
+    // Workers is how many photo requests run at once
+    Workers      int
 }
 
-func NewPropertyImageService(apiBaseURL, apiKey string) (*PropertyImageService, error) {
+func NewPropertyImageService(provider utils.ListingsProvider) (*PropertyImageService, error) {
+    if provider == nil {
+        return nil, fmt.Errorf("listings provider is required")
+    }
     return &PropertyImageService{
-        apiBaseURL:  apiBaseURL,
-        apiKey:      apiKey,
-        rateLimiter: utils.NewRateLimiter(
-            utils.LenientRateLimiter.Limit, 
-            utils.LenientRateLimiter.BurstSize,
-        ),
+        provider:    provider,
+        Workers:     IngestWorkers(),
     }, nil
 }
 
-func (s *PropertyImageService) FetchPropertyImages(ctx context.Context, properties []models.Property) ([]models.PropertyImage, error) {
-    var allPropertyImages []models.PropertyImage
-    
-    // Limit to first 10 properties
-    if len(properties) > 10 {
-        properties = properties[:10]
+// SelectProperties returns the properties the next FetchAndSavePropertyImages run covers
+func (s *PropertyImageService) SelectProperties() ([]models.Property, error) {
+    properties, err := utils.LoadPropertiesFromJSON(PropertiesFilePath)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load properties: %v", err)
     }
 
-    log.Printf("Fetching images for %d properties", len(properties))
+    var done map[int]bool
+    if s.Selection.Missing {
+        done, err = storedIDs(PropertyImagesFilePath, func(i models.PropertyImage) int { return i.PropertyID })
+        if err != nil {
+            return nil, err
+        }
+    }
+    return s.Selection.SelectProperties(properties, done), nil
+}
 
-    for i, property := range properties {
-        log.Printf("Fetching images for property %d (ID: %d)", i+1, property.HotelID)
+// FetchAndSavePropertyImages loads data/properties.json, fetches the photos of the selected
+// properties and writes data/property_images.json
+func (s *PropertyImageService) FetchAndSavePropertyImages(ctx context.Context) ([]models.PropertyImage, error) {
+    properties, err := s.SelectProperties()
+    if err != nil {
+        return nil, err
+    }
 
-        if err := s.rateLimiter.Wait(ctx); err != nil {
-            log.Printf("Rate limiter error: %v", err)
+    // The photo endpoint is keyed on the page slug; reuse the page URLs the details stage recorded
+    if setter, ok := s.provider.(utils.StayPageURLSetter); ok {
+        details, err := readRecords[models.PropertyDetail](PropertyDetailsFilePath)
+        if err != nil {
             return nil, err
         }
+        urls := make(map[int]string, len(details))
+        for _, detail := range details {
+            urls[detail.HotelID] = detail.URL
+        }
+        setter.SetStayPageURLs(urls)
+    }
 
-        // Modify URL to use string ID format from curl example
-        url := fmt.Sprintf("https://%s/web/stays/details?id=us/mayfair-new-york&checkIn=%s&checkOut=%s", 
-            s.apiBaseURL, 
-            time.Now().AddDate(0, 1, 0).Format("2006-01-02"),
-            time.Now().AddDate(0, 1, 7).Format("2006-01-02"),
-        )
+    images, fetched, err := s.fetchPropertyImages(ctx, properties)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch property images: %v", err)
+    }
 
-        req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+    saved := images
+    if s.Selection.Partial() {
+        saved, err = mergeRecords(PropertyImagesFilePath, images, func(i models.PropertyImage) bool {
+            return fetched[i.PropertyID]
+        })
         if err != nil {
-            log.Printf("Error creating request for property %d: %v", property.HotelID, err)
-            continue
+            return nil, err
         }
-        req.Header.Add("x-rapidapi-host", "booking-com18.p.rapidapi.com")
-        req.Header.Add("x-rapidapi-key", s.apiKey)
+    }
 
-        resp, err := http.DefaultClient.Do(req)
-        if err != nil {
-            log.Printf("HTTP request error for property %d: %v", property.HotelID, err)
-            continue
+    if err := utils.SavePropertyImagesToJSON(saved, PropertyImagesFilePath); err != nil {
+        return nil, fmt.Errorf("failed to save property images: %v", err)
+    }
+    return images, nil
+}
+
+// FetchPropertyImages fetches the photos of properties, stopping early when the call budget runs out
+func (s *PropertyImageService) FetchPropertyImages(ctx context.Context, properties []models.Property) ([]models.PropertyImage, error) {
+    images, _, err := s.fetchPropertyImages(ctx, properties)
+    return images, err
+}
+
+// fetchPropertyImages also returns the hotel IDs it called the provider for
+func (s *PropertyImageService) fetchPropertyImages(ctx context.Context, properties []models.Property) ([]models.PropertyImage, map[int]bool, error) {
+    utils.SetCacheMode(s.provider, s.Selection.Cache)
+    var allPropertyImages []models.PropertyImage
+    fetched := map[int]bool{}
+    progress := progressOrNoop(s.Progress)
+    progress.SetTotal(len(properties))
+
+    log.Printf("Fetching images for %d properties", len(properties))
+
+    checkIn := time.Now().AddDate(0, 1, 0).Format("2006-01-02")
+    checkOut := time.Now().AddDate(0, 1, 7).Format("2006-01-02")
+
+    pool := workerPool{Workers: s.Workers, Budget: s.Selection.Budget, Progress: progress}
+    results, err := runPool(ctx, pool, properties, func(ctx context.Context, property models.Property) (*models.PropertyImageResponse, error) {
+        log.Printf("Fetching images for property %d", property.HotelID)
+        // A page URL the details stage did not record costs a stays/detail call first
+        if setter, ok := s.provider.(utils.StayPageURLSetter); ok && !setter.HasStayPageURL(property.HotelID) && !takeExtraCall(ctx, s.Selection.Budget) {
+            return nil, ErrBudgetExhausted
         }
-        defer resp.Body.Close()
+        return s.provider.FetchPropertyPhotos(ctx, property.HotelID, checkIn, checkOut)
+    })
+    if err != nil {
+        log.Printf("Image fetch aborted: %v", err)
+        return nil, nil, err
+    }
 
-        // Check response status code
-        if resp.StatusCode != http.StatusOK {
-            log.Printf("Unexpected status code for property %d: %d", property.HotelID, resp.StatusCode)
-            body, _ := io.ReadAll(resp.Body)
-            log.Printf("Response body: %s", string(body))
-            continue
+    for i, property := range properties {
+        if !results[i].Attempted {
+            log.Printf("Stopped early: call budget or provider quota exhausted; %d properties left for the next run", len(properties)-i)
+            break
         }
 
-        body, err := io.ReadAll(resp.Body)
+        apiResponse, err := results[i].Value, results[i].Err
         if err != nil {
-            log.Printf("Error reading response body for property %d: %v", property.HotelID, err)
+            log.Printf("Error fetching photos for property %d: %v", property.HotelID, err)
+            progress.AddError(fmt.Errorf("property %d: %v", property.HotelID, err))
             continue
         }
 
-        var apiResponse map[string]interface{}
-        if err := json.Unmarshal(body, &apiResponse); err != nil {
-            log.Printf("JSON unmarshaling error for property %d: %v", property.HotelID, err)
-            log.Printf("Response body: %s", string(body))
+        data := apiResponse.Data
+        if data == nil {
+            log.Printf("No 'data' key found for property %d", property.HotelID)
             continue
         }
+        // Only a fetched property replaces its stored images
+        fetched[property.HotelID] = true
 
-        // More detailed logging for debugging
-        var keys []string
-        for k := range apiResponse {
-            keys = append(keys, k)
+        // Hotel Photos
+        hotelPhotos := parsePhotos(data["hotelPhotos"], property.HotelID, models.PhotoKindHotel)
+        if len(hotelPhotos) > 0 {
+            allPropertyImages = append(allPropertyImages, newPropertyImage(property.HotelID, "hotel_photos", hotelPhotos))
         }
-        log.Printf("API Response keys for property %d: %v", property.HotelID, keys)
 
-        data, ok := apiResponse["data"].(map[string]interface{})
-        if !ok {
-            log.Printf("No 'data' key found for property %d", property.HotelID)
-            log.Printf("Full API response: %+v", apiResponse)
-            continue
+        // Room Photos
+        roomPhotos := parsePhotos(data["allRoomPhotos"], property.HotelID, models.PhotoKindRoom)
+        if len(roomPhotos) > 0 {
+            allPropertyImages = append(allPropertyImages, newPropertyImage(property.HotelID, "room_photos", roomPhotos))
         }
+    }
 
-        // Hotel Photos
-        hotelPhotos, ok := data["hotelPhotos"].([]interface{})
-        if ok {
-            var hotelImageURLs []string
-            for _, photo := range hotelPhotos {
-                photoMap, ok := photo.(map[string]interface{})
-                if !ok {
-                    continue
-                }
-                thumbURL, ok := photoMap["thumb_url"].(string)
-                if !ok {
-                    continue
-                }
-                hotelImageURLs = append(hotelImageURLs, thumbURL)
-            }
+    progress.SetCount("imageSets", len(allPropertyImages))
+    log.Printf("Finished fetching images. Total images collected: %d", len(allPropertyImages))
+    return allPropertyImages, fetched, nil
+}
+// newPropertyImage groups photos into the image set of a property; ImageURLs keeps the
+// thumbnails for the files that predate photo metadata
+func newPropertyImage(hotelID int, imageType string, photos []models.PropertyPhoto) models.PropertyImage {
+    urls := make([]string, len(photos))
+    for i, photo := range photos {
+        urls[i] = photo.ThumbURL
+        if urls[i] == "" {
+            urls[i] = photo.LargeURL
+        }
+    }
+    return models.PropertyImage{
+        PropertyID:   hotelID,
+        PropertyName: strconv.Itoa(hotelID),
+        ImageType:    imageType,
+        ImageURLs:    urls,
+        Photos:       photos,
+    }
+}
 
-            if len(hotelImageURLs) > 0 {
-                hotelPropertyImage := models.PropertyImage{
-                    PropertyID:   property.HotelID,
-                    PropertyName: strconv.Itoa(property.HotelID),
-                    ImageType:    "hotel_photos",
-                    ImageURLs:    hotelImageURLs,
-                }
-                allPropertyImages = append(allPropertyImages, hotelPropertyImage)
-            }
+// parsePhotos reads a hotelPhotos or allRoomPhotos list. Room photos come either flat with
+// a room_id or grouped as {room_id, room_name, photos: [...]}.
+func parsePhotos(raw interface{}, hotelID int, kind string) []models.PropertyPhoto {
+    entries, _ := raw.([]interface{})
+
+    var photos []models.PropertyPhoto
+    add := func(photoMap map[string]interface{}, roomID int64, roomName string) {
+        photo := models.PropertyPhoto{
+            PropertyID: int64(hotelID),
+            PhotoID:    photoInt(photoMap, "id", "photo_id"),
+            Kind:       kind,
+            RoomID:     roomID,
+            RoomName:   roomName,
+            Caption:    photoString(photoMap, "caption", "photo_caption"),
+            LargeURL:   photoString(photoMap, "large_url", "highres_url", "url_max", "url_1440", "url_original"),
+            ThumbURL:   photoString(photoMap, "thumb_url", "url_square60", "url_max300"),
+            Position:   len(photos),
+        }
+        if photo.RoomID == 0 {
+            photo.RoomID = photoInt(photoMap, "room_id", "roomId")
+        }
+        if photo.RoomName == "" {
+            photo.RoomName = photoString(photoMap, "room_name", "roomName")
+        }
+        if photo.LargeURL == "" && photo.ThumbURL == "" {
+            return
         }
+        photos = append(photos, photo)
+    }
 
-        // Room Photos
-        roomPhotos, ok := data["allRoomPhotos"].([]interface{})
-        if ok {
-            var roomImageURLs []string
-            for _, photo := range roomPhotos {
-                photoMap, ok := photo.(map[string]interface{})
-                if !ok {
-                    continue
-                }
-                thumbURL, ok := photoMap["thumb_url"].(string)
-                if !ok {
-                    continue
-                }
-                roomImageURLs = append(roomImageURLs, thumbURL)
+    for _, entry := range entries {
+        entryMap, ok := entry.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        grouped, ok := entryMap["photos"].([]interface{})
+        if !ok {
+            add(entryMap, 0, "")
+            continue
+        }
+        roomID := photoInt(entryMap, "room_id", "roomId", "id")
+        roomName := photoString(entryMap, "room_name", "roomName", "name")
+        for _, photo := range grouped {
+            if photoMap, ok := photo.(map[string]interface{}); ok {
+                add(photoMap, roomID, roomName)
             }
+        }
+    }
+    return photos
+}
 
-            if len(roomImageURLs) > 0 {
-                roomPropertyImage := models.PropertyImage{
-                    PropertyID:   property.HotelID,
-                    PropertyName: strconv.Itoa(property.HotelID),
-                    ImageType:    "room_photos",
-                    ImageURLs:    roomImageURLs,
-                }
-                allPropertyImages = append(allPropertyImages, roomPropertyImage)
-            }
+// photoString returns the first non-empty string among keys
+func photoString(photo map[string]interface{}, keys ...string) string {
+    for _, key := range keys {
+        if value, ok := photo[key].(string); ok && value != "" {
+            return value
         }
     }
+    return ""
+}
 
-    log.Printf("Finished fetching images. Total images collected: %d", len(allPropertyImages))
-    return allPropertyImages, nil
-}
\ No newline at end of file
+// photoInt returns the first numeric value among keys; IDs arrive as numbers or strings
+func photoInt(photo map[string]interface{}, keys ...string) int64 {
+    for _, key := range keys {
+        switch value := photo[key].(type) {
+        case float64:
+            return int64(value)
+        case string:
+            if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+                return n
+            }
+        }
+    }
+    return 0
+}
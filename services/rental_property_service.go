@@ -15,18 +15,19 @@ func (s *RentalPropertyService) GenerateRentalPropertyJSON(properties []map[stri
 	for _, prop := range properties {
 		for _, detail := range propertyDetails {
 			if prop["id"] == detail["hotel_id"] {
-				// Convert amenities safely
-				amenitiesJSON, _ := json.Marshal(convertToStringSlice(detail["amenities"]))
-
 				rentalProp := models.RentalProperty{
 					PropertyID:   int64(prop["id"].(float64)),
 					Name:         prop["name"].(string),
 					CityID:       prop["cityId"].(string),
 					Bedrooms:     int(detail["bedrooms"].(float64)),
 					Bathrooms:    int(detail["bathrooms"].(float64)),
-					Amenities:    string(amenitiesJSON),
+					Amenities:    models.NormalizeAmenities(convertToStringSlice(detail["amenities"])),
 					PropertyType: detail["property_type"].(string),
 				}
+				// Details fetched before units were parsed have no occupancy
+				if occupancy, ok := detail["max_occupancy"].(float64); ok {
+					rentalProp.MaxOccupancy = int(occupancy)
+				}
 				rentalProperties = append(rentalProperties, rentalProp)
 				break
 			}
@@ -36,6 +37,21 @@ func (s *RentalPropertyService) GenerateRentalPropertyJSON(properties []map[stri
 	return s.writeJSONFile(rentalProperties)
 }
 
+// GenerateFromFiles joins data/properties.json with data/property_details.json into data/RentalProperty.json
+func (s *RentalPropertyService) GenerateFromFiles() error {
+	var properties []map[string]interface{}
+	if err := readJSONFile("data/properties.json", &properties); err != nil {
+		return err
+	}
+
+	var propertyDetails []map[string]interface{}
+	if err := readJSONFile("data/property_details.json", &propertyDetails); err != nil {
+		return err
+	}
+
+	return s.GenerateRentalPropertyJSON(properties, propertyDetails)
+}
+
 func (s *RentalPropertyService) writeJSONFile(data []models.RentalProperty) error {
 	file, err := json.MarshalIndent(data, "", " ")
 	if err != nil {
@@ -87,7 +103,7 @@ func convertToStringSlice(data interface{}) []string {
 // 					CityID:       prop["cityId"].(string),
 // 					Bedrooms:     int(detail["bedrooms"].(float64)),
 // 					Bathrooms:    int(detail["bathrooms"].(float64)),
-// 					Amenities:    string(amenitiesJSON),
+// 					Amenities:    models.NormalizeAmenities(convertToStringSlice(detail["amenities"])),
 // 					PropertyType: detail["property_type"].(string),
 // 				}
 // 				rentalProperties = append(rentalProperties, rentalProp)
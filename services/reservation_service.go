@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/lib/pq"
+)
+
+// Errors of the reservation endpoints
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	// ErrReservationOverlap is returned when a confirmed reservation of the property already
+	// holds one of the nights
+	ErrReservationOverlap = errors.New("the property is already booked for some of these nights")
+	// ErrReservationStatus is returned for a change the current status does not allow
+	ErrReservationStatus = errors.New("reservation status does not allow this change")
+	// ErrPropertyUnavailable is returned when the availability calendar or the provider
+	// reports the stay cannot be booked
+	ErrPropertyUnavailable = errors.New("the property is not available for this stay")
+	// ErrStayUnverified is returned when neither the calendar nor the provider could check
+	// the stay
+	ErrStayUnverified = errors.New("the stay could not be checked right now; try again later")
+	// ErrReservationUnpriced is returned for confirming a reservation without a total price
+	ErrReservationUnpriced = errors.New("a reservation without a total price cannot be confirmed")
+)
+
+// exclusionViolation is the SQLSTATE Postgres returns when reservation_no_overlap rejects a row
+const exclusionViolation = "23P01"
+
+// ReservationRequest is the body of POST /v1/reservations
+type ReservationRequest struct {
+	PropertyID int64  `json:"propertyId"`
+	GuestName  string `json:"guestName"`
+	GuestEmail string `json:"guestEmail"`
+	Guests     int    `json:"guests"`
+	CheckIn    string `json:"checkIn"`
+	CheckOut   string `json:"checkOut"`
+	// Status is pending unless the reservation is confirmed right away
+	Status string `json:"status"`
+	// Currency of the total price; currency::base when empty
+	Currency string `json:"currency"`
+}
+
+// ParseReservationRequest decodes and validates a reservation body; the stay must not
+// start before today
+func ParseReservationRequest(body []byte, today time.Time) (*ReservationRequest, error) {
+	var r ReservationRequest
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("invalid reservation body: %v", err)
+	}
+
+	r.GuestName = strings.TrimSpace(r.GuestName)
+	r.GuestEmail = strings.TrimSpace(r.GuestEmail)
+	if r.PropertyID <= 0 {
+		return nil, fmt.Errorf("propertyId is required")
+	}
+	if r.GuestName == "" {
+		return nil, fmt.Errorf("guestName is required")
+	}
+	if address, err := mail.ParseAddress(r.GuestEmail); err != nil || address.Address != r.GuestEmail {
+		return nil, fmt.Errorf("guestEmail must be an email address")
+	}
+	if r.Guests == 0 {
+		r.Guests = 1
+	}
+	if r.Guests < 0 {
+		return nil, fmt.Errorf("guests must be at least 1")
+	}
+
+	if _, err := time.Parse("2006-01-02", r.CheckIn); err != nil {
+		return nil, fmt.Errorf("checkIn must be a date such as 2026-11-03")
+	}
+	if _, err := time.Parse("2006-01-02", r.CheckOut); err != nil {
+		return nil, fmt.Errorf("checkOut must be a date such as 2026-11-07")
+	}
+	nights := len(StayNights(r.CheckIn, r.CheckOut))
+	switch {
+	case nights == 0:
+		return nil, fmt.Errorf("checkOut must be after checkIn")
+	case nights > MaxStayNights:
+		return nil, fmt.Errorf("a stay can be at most %d nights", MaxStayNights)
+	case r.CheckIn < today.Format("2006-01-02"):
+		return nil, fmt.Errorf("checkIn must not be in the past")
+	}
+
+	switch r.Status {
+	case "":
+		r.Status = models.ReservationPending
+	case models.ReservationPending, models.ReservationConfirmed:
+	default:
+		return nil, fmt.Errorf("status must be %s or %s", models.ReservationPending, models.ReservationConfirmed)
+	}
+
+	if r.Currency != "" {
+		currency, err := utils.NormalizeCurrency(r.Currency)
+		if err != nil {
+			return nil, err
+		}
+		r.Currency = currency
+	}
+	return &r, nil
+}
+
+// reservationColumns are the columns scanned by scanReservation, in order
+const reservationColumns = `id, property_id, guest_name, guest_email, guests,
+	to_char(check_in, 'YYYY-MM-DD'), to_char(check_out, 'YYYY-MM-DD'), status,
+	total_price, currency, created_at, updated_at, cancelled_at`
+
+// ReservationService creates and changes reservations. Overlaps are left to the
+// reservation_no_overlap constraint, so concurrent confirmations cannot both succeed.
+type ReservationService struct {
+	// Stays prices the stay and checks it against the availability calendar; NewStayService
+	// when nil
+	Stays *StayService
+}
+
+// Create stores a reservation priced from the availability calendar, or a live lookup when
+// it is stale. A stay that could not be checked is ErrStayUnverified; one checked but not
+// priced can only be reserved pending.
+func (s *ReservationService) Create(ctx context.Context, r *ReservationRequest) (*models.Reservation, error) {
+	if !propertyListed(r.PropertyID) {
+		return nil, ErrPropertyNotFound
+	}
+	converter, err := NewCurrencyConverter(r.Currency)
+	if err != nil {
+		return nil, err
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	// A pending reservation would only fail once confirmed, so it is turned away early too;
+	// the constraint still decides between reservations made at the same time
+	var booked bool
+	err = db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM reservation
+			WHERE property_id = $1 AND status = 'confirmed'
+			AND daterange(check_in, check_out, '[)') && daterange($2::date, $3::date, '[)'))`,
+		r.PropertyID, r.CheckIn, r.CheckOut).Scan(&booked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check reservations of property %d: %v", r.PropertyID, err)
+	}
+	if booked {
+		return nil, ErrReservationOverlap
+	}
+
+	stays := s.Stays
+	if stays == nil {
+		stays = NewStayService()
+	}
+	guests := r.Guests
+	quotes, _, err := stays.Quote(ctx, []int64{r.PropertyID}, &PropertyListQuery{CheckIn: r.CheckIn, CheckOut: r.CheckOut, Guests: &guests}, converter)
+	if err != nil {
+		return nil, err
+	}
+	quote, ok := quotes[r.PropertyID]
+	switch {
+	case !ok:
+		return nil, ErrPropertyUnavailable
+	case quote.Source == StaySourceUnverified:
+		return nil, ErrStayUnverified
+	case quote.Total == nil && r.Status == models.ReservationConfirmed:
+		return nil, ErrReservationUnpriced
+	}
+
+	row := db.QueryRowContext(ctx, `
+		INSERT INTO reservation
+			(property_id, guest_name, guest_email, guests, check_in, check_out, status, total_price, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+reservationColumns,
+		r.PropertyID, r.GuestName, r.GuestEmail, r.Guests, r.CheckIn, r.CheckOut, r.Status, quote.Total, quote.Currency)
+	reservation, err := scanReservation(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reservation: %w", err)
+	}
+	return reservation, nil
+}
+
+// Get returns one reservation
+func (s *ReservationService) Get(id int64) (*models.Reservation, error) {
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+	reservation, err := scanReservation(db.QueryRow(`SELECT `+reservationColumns+` FROM reservation WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve reservation %d: %v", id, err)
+	}
+	return reservation, nil
+}
+
+// Confirm confirms a pending reservation; confirming it again changes nothing. One without
+// a total price is ErrReservationUnpriced.
+func (s *ReservationService) Confirm(id int64) (*models.Reservation, error) {
+	current, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == models.ReservationPending && current.TotalPrice == nil {
+		return nil, fmt.Errorf("%w: reservation %d", ErrReservationUnpriced, id)
+	}
+	return s.transition(id, models.ReservationConfirmed, `
+		UPDATE reservation SET status = 'confirmed', updated_at = now()
+		WHERE id = $1 AND status = 'pending' AND total_price IS NOT NULL
+		RETURNING `+reservationColumns)
+}
+
+// Cancel cancels a pending or confirmed reservation, freeing its nights; cancelling it
+// again changes nothing
+func (s *ReservationService) Cancel(id int64) (*models.Reservation, error) {
+	return s.transition(id, models.ReservationCancelled, `
+		UPDATE reservation SET status = 'cancelled', updated_at = now(), cancelled_at = now()
+		WHERE id = $1 AND status <> 'cancelled'
+		RETURNING `+reservationColumns)
+}
+
+// transition runs update, which returns no row when the reservation is missing or not in a
+// status it applies to; a reservation already in status is returned unchanged
+func (s *ReservationService) transition(id int64, status, update string) (*models.Reservation, error) {
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+	reservation, err := scanReservation(db.QueryRow(update, id))
+	if err == nil {
+		return reservation, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to update reservation %d: %w", id, err)
+	}
+
+	current, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != status {
+		return nil, fmt.Errorf("%w: reservation %d is %s", ErrReservationStatus, id, current.Status)
+	}
+	return current, nil
+}
+
+// scanReservation reads a row of reservationColumns, turning an overlap rejected by the
+// database into ErrReservationOverlap
+func scanReservation(row *sql.Row) (*models.Reservation, error) {
+	var r models.Reservation
+	err := row.Scan(&r.ID, &r.PropertyID, &r.GuestName, &r.GuestEmail, &r.Guests, &r.CheckIn, &r.CheckOut,
+		&r.Status, &r.TotalPrice, &r.Currency, &r.CreatedAt, &r.UpdatedAt, &r.CancelledAt)
+	if isExclusionViolation(err) {
+		return nil, ErrReservationOverlap
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func isExclusionViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == exclusionViolation
+}
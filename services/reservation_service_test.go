@@ -0,0 +1,52 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"backend_rental/models"
+	"github.com/lib/pq"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestReservationRequest checks the validation of a reservation body and that an overlap
+// rejected by the database is recognized
+func TestReservationRequest(t *testing.T) {
+	Convey("Subject: reservation requests\n", t, func() {
+		today := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+		parse := func(body string) (*ReservationRequest, error) {
+			return ParseReservationRequest([]byte(body), today)
+		}
+
+		Convey("A valid body defaults to one guest and pending", func() {
+			r, err := parse(`{"propertyId":3226748,"guestName":" Sam Lee ","guestEmail":"sam@example.com","checkIn":"2026-11-03","checkOut":"2026-11-07","currency":"usd"}`)
+			So(err, ShouldBeNil)
+			So(r.GuestName, ShouldEqual, "Sam Lee")
+			So(r.Guests, ShouldEqual, 1)
+			So(r.Status, ShouldEqual, models.ReservationPending)
+			So(r.Currency, ShouldEqual, "USD")
+		})
+
+		Convey("Invalid bodies are rejected", func() {
+			valid := `"propertyId":3226748,"guestName":"Sam","guestEmail":"sam@example.com"`
+			for _, body := range []string{
+				`{"guestName":"Sam","guestEmail":"sam@example.com","checkIn":"2026-11-03","checkOut":"2026-11-07"}`,
+				`{"propertyId":3226748,"guestName":"Sam","guestEmail":"Sam <sam>","checkIn":"2026-11-03","checkOut":"2026-11-07"}`,
+				`{` + valid + `,"checkIn":"2026-11-07","checkOut":"2026-11-03"}`,
+				`{` + valid + `,"checkIn":"2026-10-17","checkOut":"2026-10-19"}`,
+				`{` + valid + `,"checkIn":"2026-11-03","checkOut":"2026-11-07","status":"cancelled"}`,
+				`{` + valid + `,"checkIn":"2026-11-03","checkOut":"2026-11-07","guests":-1}`,
+			} {
+				_, err := parse(body)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Only an exclusion violation is an overlap", func() {
+			So(isExclusionViolation(fmt.Errorf("insert: %w", &pq.Error{Code: "23P01"})), ShouldBeTrue)
+			So(isExclusionViolation(&pq.Error{Code: "23505"}), ShouldBeFalse)
+			So(isExclusionViolation(nil), ShouldBeFalse)
+		})
+	})
+}
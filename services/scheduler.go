@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	beego "github.com/beego/beego/v2/server/web"
+	"github.com/beego/beego/v2/task"
+)
+
+// DefaultPropertyRefreshSpec runs the listings refresh daily at 03:00 (sec min hour day month weekday)
+const DefaultPropertyRefreshSpec = "0 0 3 * * *"
+
+// StartScheduler registers the periodic jobs configured in the [scheduler] section of app.conf
+func StartScheduler() error {
+	if !beego.AppConfig.DefaultBool("scheduler::enabled", false) {
+		fmt.Println("Scheduler disabled")
+		return nil
+	}
+
+	spec := beego.AppConfig.DefaultString("scheduler::property_refresh", DefaultPropertyRefreshSpec)
+	refresh, err := newTask(JobKindPropertyRefresh, spec, func(ctx context.Context) error {
+		_, err := StartPropertyRefreshJob()
+		if err == ErrJobAlreadyRunning {
+			fmt.Println("Skipping scheduled property refresh: previous run still in progress")
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	task.AddTask(JobKindPropertyRefresh, refresh)
+	fmt.Printf("Scheduled property refresh with spec %q\n", spec)
+
+	// The crawl costs a provider call per property and night, so it only runs when scheduled
+	if spec := beego.AppConfig.DefaultString("scheduler::availability", ""); spec != "" {
+		crawl, err := newTask(JobKindAvailabilityCrawl, spec, func(ctx context.Context) error {
+			_, err := StartAvailabilityJob()
+			if err == ErrJobAlreadyRunning {
+				fmt.Println("Skipping scheduled availability crawl: previous run still in progress")
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		task.AddTask(JobKindAvailabilityCrawl, crawl)
+		fmt.Printf("Scheduled availability crawl with spec %q\n", spec)
+	}
+
+	task.StartTask()
+	return nil
+}
+
+// newTask wraps task.NewTask, which panics on a malformed cron spec
+func newTask(name, spec string, f task.TaskFunc) (t *task.Task, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid schedule %q for %s: %v", spec, name, r)
+		}
+	}()
+	return task.NewTask(name, spec, f), nil
+}
+
+// StartPropertyRefreshJob runs RefreshAllCities as a background job
+func StartPropertyRefreshJob() (*Job, error) {
+	return Jobs.Start(JobKindPropertyRefresh, func(ctx context.Context, job *Job) error {
+		service := NewPropertyRefreshService()
+		service.Progress = job
+		_, err := service.RefreshAllCities(ctx)
+		return err
+	})
+}
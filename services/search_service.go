@@ -0,0 +1,520 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// Search backends selectable with search_backend in app.conf
+const (
+	SearchBackendPostgres = "postgres"
+	SearchBackendMemory   = "memory"
+)
+
+// Highlight markers wrapped around matched terms in snippets
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// Field weights of the in-memory ranking, mirroring the A/B/C tsvector weights
+var memoryFieldWeights = map[string]float64{
+	"name":        1.0,
+	"description": 0.4,
+	"amenities":   0.2,
+}
+
+// SearchQuery is a full-text query with its page
+type SearchQuery struct {
+	Text     string
+	Page     int
+	PageSize int
+}
+
+// SearchHighlights holds the snippets of a hit with matches wrapped in <mark>
+type SearchHighlights struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Amenities   []string `json:"amenities"`
+}
+
+// SearchHit is one ranked listing
+type SearchHit struct {
+	PropertyID   int64            `json:"propertyId"`
+	Name         string           `json:"name"`
+	CityID       string           `json:"cityId"`
+	PropertyType string           `json:"propertyType"`
+	Score        float64          `json:"score"`
+	Highlights   SearchHighlights `json:"highlights"`
+}
+
+// SearchResult is the response envelope of /v1/search
+type SearchResult struct {
+	Query    string      `json:"query"`
+	Backend  string      `json:"backend"`
+	Total    int         `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"pageSize"`
+	Data     []SearchHit `json:"data"`
+}
+
+// ParseSearchQuery validates the query string of /v1/search
+func ParseSearchQuery(values url.Values) (*SearchQuery, error) {
+	q := &SearchQuery{
+		Text:     strings.TrimSpace(values.Get("q")),
+		Page:     1,
+		PageSize: DefaultPropertyPageSize,
+	}
+	if q.Text == "" {
+		return nil, fmt.Errorf("q is required")
+	}
+
+	if page, err := optionalInt(values, "page"); err != nil {
+		return nil, err
+	} else if page != nil {
+		if *page < 1 {
+			return nil, fmt.Errorf("page must be at least 1")
+		}
+		q.Page = *page
+	}
+	if pageSize, err := optionalInt(values, "page_size"); err != nil {
+		return nil, err
+	} else if pageSize != nil {
+		if *pageSize < 1 || *pageSize > MaxPropertyPageSize {
+			return nil, fmt.Errorf("page_size must be between 1 and %d", MaxPropertyPageSize)
+		}
+		q.PageSize = *pageSize
+	}
+	return q, nil
+}
+
+type SearchService struct {
+	Backend string
+}
+
+// NewSearchService selects the backend from search_backend, defaulting to the in-memory
+// index when listings come from the file-backed provider
+func NewSearchService() *SearchService {
+	defaultBackend := SearchBackendPostgres
+	if beego.AppConfig.DefaultString("listings_provider", utils.ProviderRapidAPI) == utils.ProviderFile {
+		defaultBackend = SearchBackendMemory
+	}
+	return &SearchService{
+		Backend: beego.AppConfig.DefaultString("search_backend", defaultBackend),
+	}
+}
+
+// Search ranks listings by relevance across name, description and amenities
+func (s *SearchService) Search(q *SearchQuery) (*SearchResult, error) {
+	if s.Backend == SearchBackendMemory {
+		index, err := loadMemorySearchIndex()
+		if err != nil {
+			return nil, err
+		}
+		return index.search(q), nil
+	}
+	return s.searchPostgres(q)
+}
+
+const postgresSearchSQL = `
+	SELECT rp.property_id, rp.name, rp.city_id, rp.property_type,
+		coalesce((SELECT json_agg(a.name ORDER BY a.name) FROM property_amenity pa
+			JOIN amenity a ON a.id = pa.amenity_id
+			WHERE pa.property_id = rp.property_id), '[]') AS amenities,
+		ts_rank(rp.search_document, query) AS score,
+		ts_headline('english', rp.name, query, $2) AS name_snippet,
+		ts_headline('english', coalesce(pd.description, ''), query, $2) AS description_snippet
+	FROM rental_property rp
+	LEFT JOIN property_details pd ON pd.property_id = rp.property_id,
+		websearch_to_tsquery('english', $1) query
+	WHERE rp.deleted_at IS NULL AND rp.search_document @@ query
+	ORDER BY score DESC, rp.property_id
+	LIMIT $3 OFFSET $4`
+
+const postgresSearchCountSQL = `
+	SELECT count(*)
+	FROM rental_property rp, websearch_to_tsquery('english', $1) query
+	WHERE rp.deleted_at IS NULL AND rp.search_document @@ query`
+
+// headlineOptions configures ts_headline to produce short fragments with <mark> markers
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=25, MinWords=8", highlightStart, highlightStop)
+
+type postgresSearchRow struct {
+	PropertyID         int64
+	Name               string
+	CityID             string
+	PropertyType       string
+	Amenities          string
+	Score              float64
+	NameSnippet        string
+	DescriptionSnippet string
+}
+
+func (s *SearchService) searchPostgres(q *SearchQuery) (*SearchResult, error) {
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	var total int
+	if err := db.QueryRow(postgresSearchCountSQL, q.Text).Scan(&total); err != nil {
+		return nil, fmt.Errorf("error counting search results: %v", err)
+	}
+
+	rows, err := db.Query(postgresSearchSQL, q.Text, headlineOptions, q.PageSize, (q.Page-1)*q.PageSize)
+	if err != nil {
+		return nil, fmt.Errorf("error searching properties: %v", err)
+	}
+	defer rows.Close()
+
+	terms := tokenize(q.Text)
+	hits := []SearchHit{}
+	for rows.Next() {
+		var row postgresSearchRow
+		if err := rows.Scan(&row.PropertyID, &row.Name, &row.CityID, &row.PropertyType, &row.Amenities,
+			&row.Score, &row.NameSnippet, &row.DescriptionSnippet); err != nil {
+			return nil, fmt.Errorf("error reading search result: %v", err)
+		}
+		hits = append(hits, SearchHit{
+			PropertyID:   row.PropertyID,
+			Name:         row.Name,
+			CityID:       row.CityID,
+			PropertyType: row.PropertyType,
+			Score:        row.Score,
+			Highlights: SearchHighlights{
+				Name:        row.NameSnippet,
+				Description: row.DescriptionSnippet,
+				Amenities:   matchingAmenities(decodeAmenities(row.Amenities), terms),
+			},
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading search results: %v", err)
+	}
+
+	return &SearchResult{
+		Query:    q.Text,
+		Backend:  SearchBackendPostgres,
+		Total:    total,
+		Page:     q.Page,
+		PageSize: q.PageSize,
+		Data:     hits,
+	}, nil
+}
+
+// memoryDocument is a listing as seen by the in-memory index
+type memoryDocument struct {
+	property    models.RentalProperty
+	description string
+	amenities   []string
+	fieldTerms  map[string]map[string]int
+}
+
+// memorySearchIndex is the fallback used in file-backed mode; it ranks with a weighted
+// TF-IDF over data/RentalProperty.json and data/PropertyDetails.json
+type memorySearchIndex struct {
+	documents []memoryDocument
+	docFreq   map[string]int
+}
+
+var (
+	memoryIndexMu     sync.Mutex
+	memoryIndex       *memorySearchIndex
+	memoryIndexSource = map[string]int64{}
+)
+
+// loadMemorySearchIndex builds the index, rebuilding it when a source file has changed
+func loadMemorySearchIndex() (*memorySearchIndex, error) {
+	memoryIndexMu.Lock()
+	defer memoryIndexMu.Unlock()
+
+	sources := []string{"data/RentalProperty.json", "data/PropertyDetails.json"}
+	stale := memoryIndex == nil
+	// Modification times are only recorded once the rebuild succeeds, so a failed rebuild is
+	// retried on the next search
+	modTimes := make(map[string]int64, len(sources))
+	for _, path := range sources {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		modTimes[path] = info.ModTime().UnixNano()
+		if memoryIndexSource[path] != modTimes[path] {
+			stale = true
+		}
+	}
+	if !stale {
+		return memoryIndex, nil
+	}
+
+	var properties []models.RentalProperty
+	if err := readJSONFile(sources[0], &properties); err != nil {
+		return nil, err
+	}
+	var details []models.PropertyDetails
+	if err := readJSONFile(sources[1], &details); err != nil {
+		return nil, err
+	}
+
+	descriptions := make(map[int64]string, len(details))
+	for _, detail := range details {
+		if _, ok := descriptions[detail.PropertyID]; !ok {
+			descriptions[detail.PropertyID] = detail.Description
+		}
+	}
+
+	index := &memorySearchIndex{docFreq: map[string]int{}}
+	for _, property := range properties {
+		amenities := property.Amenities.Names()
+		doc := memoryDocument{
+			property:    property,
+			description: descriptions[property.PropertyID],
+			amenities:   amenities,
+			fieldTerms: map[string]map[string]int{
+				"name":        termCounts(property.Name),
+				"description": termCounts(descriptions[property.PropertyID]),
+				"amenities":   termCounts(strings.Join(amenities, " ")),
+			},
+		}
+
+		seen := map[string]bool{}
+		for _, counts := range doc.fieldTerms {
+			for term := range counts {
+				if !seen[term] {
+					seen[term] = true
+					index.docFreq[term]++
+				}
+			}
+		}
+		index.documents = append(index.documents, doc)
+	}
+
+	memoryIndex = index
+	for path, modTime := range modTimes {
+		memoryIndexSource[path] = modTime
+	}
+	fmt.Printf("Built in-memory search index over %d properties\n", len(index.documents))
+	return index, nil
+}
+
+func (idx *memorySearchIndex) search(q *SearchQuery) *SearchResult {
+	terms := tokenize(q.Text)
+	total := float64(len(idx.documents))
+
+	var hits []SearchHit
+	for _, doc := range idx.documents {
+		score := 0.0
+		for _, term := range terms {
+			df := idx.docFreq[term]
+			if df == 0 {
+				continue
+			}
+			idf := math.Log(1 + total/float64(df))
+			for field, weight := range memoryFieldWeights {
+				if tf := doc.fieldTerms[field][term]; tf > 0 {
+					score += weight * (1 + math.Log(float64(tf))) * idf
+				}
+			}
+		}
+		if score == 0 {
+			continue
+		}
+
+		hits = append(hits, SearchHit{
+			PropertyID:   doc.property.PropertyID,
+			Name:         doc.property.Name,
+			CityID:       doc.property.CityID,
+			PropertyType: doc.property.PropertyType,
+			Score:        math.Round(score*1e4) / 1e4,
+			Highlights: SearchHighlights{
+				Name:        highlight(doc.property.Name, terms),
+				Description: snippet(doc.description, terms, 160),
+				Amenities:   matchingAmenities(doc.amenities, terms),
+			},
+		})
+	}
+
+	sort.SliceStable(hits, func(i, k int) bool {
+		if hits[i].Score != hits[k].Score {
+			return hits[i].Score > hits[k].Score
+		}
+		return hits[i].PropertyID < hits[k].PropertyID
+	})
+
+	result := &SearchResult{
+		Query:    q.Text,
+		Backend:  SearchBackendMemory,
+		Total:    len(hits),
+		Page:     q.Page,
+		PageSize: q.PageSize,
+		Data:     []SearchHit{},
+	}
+	start := (q.Page - 1) * q.PageSize
+	if start < len(hits) {
+		result.Data = hits[start:min(start+q.PageSize, len(hits))]
+	}
+	return result
+}
+
+// searchStopWords are dropped from queries and documents, like the english text search config
+var searchStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "the": true, "to": true, "with": true,
+}
+
+// tokenize lowercases text and splits it into words, dropping stop words
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, word := range words {
+		if !searchStopWords[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+func termCounts(text string) map[string]int {
+	counts := map[string]int{}
+	for _, term := range tokenize(text) {
+		counts[term]++
+	}
+	return counts
+}
+
+// highlight escapes text and wraps every word matching one of terms in <mark>
+func highlight(text string, terms []string) string {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	var b strings.Builder
+	word := []rune{}
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		w := html.EscapeString(string(word))
+		if wanted[strings.ToLower(string(word))] {
+			w = highlightStart + w + highlightStop
+		}
+		b.WriteString(w)
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	flush()
+	return b.String()
+}
+
+// snippet returns a window of about width characters around the first match, highlighted
+func snippet(text string, terms []string, width int) string {
+	if text == "" {
+		return ""
+	}
+	runes := []rune(text)
+	// Lowercase rune by rune so offsets in lower are offsets in runes
+	lower := lowerRunes(text)
+
+	first := -1
+	for _, term := range terms {
+		if i := indexRunes(lower, lowerRunes(term)); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	start := max(0, first-width/2)
+	end := min(len(runes), start+width)
+	fragment := highlight(string(runes[start:end]), terms)
+	if start > 0 {
+		fragment = "…" + fragment
+	}
+	if end < len(runes) {
+		fragment += "…"
+	}
+	return fragment
+}
+
+// lowerRunes lowercases s one rune at a time, keeping its rune count
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// indexRunes returns the rune offset of the first sub in s, or -1
+func indexRunes(s, sub []rune) int {
+	if len(sub) == 0 {
+		return -1
+	}
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if slices.Equal(s[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchingAmenities returns the highlighted amenities that contain one of terms
+func matchingAmenities(amenities []string, terms []string) []string {
+	matched := []string{}
+	for _, amenity := range amenities {
+		counts := termCounts(amenity)
+		for _, term := range terms {
+			if counts[term] > 0 {
+				matched = append(matched, highlight(amenity, terms))
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// decodeAmenities parses the JSON-encoded amenities column
+func decodeAmenities(raw string) []string {
+	var amenities []string
+	if raw == "" || json.Unmarshal([]byte(raw), &amenities) != nil {
+		return []string{}
+	}
+	return amenities
+}
+
+func readJSONFile(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("error unmarshaling %s: %v", path, err)
+	}
+	return nil
+}
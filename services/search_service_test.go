@@ -0,0 +1,29 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestSnippet checks that the snippet window is cut around the match in text whose lowercase
+// takes a different number of bytes than the original
+func TestSnippet(t *testing.T) {
+	Convey("Subject: cutting a highlighted snippet out of a description\n", t, func() {
+		Convey("A match after non-ASCII text is found without slicing inside a rune", func() {
+			text := strings.Repeat("İ", 40) + " quiet garden view " + strings.Repeat("İ", 40)
+
+			So(func() { snippet(text, []string{"garden"}, 20) }, ShouldNotPanic)
+			fragment := snippet(text, []string{"garden"}, 20)
+			So(fragment, ShouldContainSubstring, highlightStart+"garden"+highlightStop)
+			So(fragment, ShouldStartWith, "…")
+			So(fragment, ShouldEndWith, "…")
+		})
+
+		Convey("A term is matched against uppercase non-ASCII letters", func() {
+			fragment := snippet("Près de ÉTÉ plage", []string{"été"}, 40)
+			So(fragment, ShouldEqual, "Près de "+highlightStart+"ÉTÉ"+highlightStop+" plage")
+		})
+	})
+}
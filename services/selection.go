@@ -0,0 +1,350 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// Selection narrows which cities and properties an ingest stage works on. The zero value
+// selects everything.
+type Selection struct {
+	// CityID restricts a stage to one city
+	CityID string
+	// PropertyIDs restricts the per-property stages to these hotel IDs
+	PropertyIDs []int
+	// TopReviewed orders properties by review count, most reviewed first, before Limit applies
+	TopReviewed bool
+	// Missing skips cities and properties the stage already has data for
+	Missing bool
+	// Limit caps how many cities or properties a stage selects; 0 means no cap
+	Limit int
+	// Budget caps the provider calls of a run; nil means unlimited
+	Budget *CallBudget
+	// Cache is how the run uses the provider response cache
+	Cache utils.CacheMode
+}
+
+// Partial reports whether the stage covers only part of the data, in which case its output
+// is merged into the existing file instead of replacing it
+func (s Selection) Partial() bool {
+	return s.CityID != "" || len(s.PropertyIDs) > 0 || s.Missing || s.Limit > 0 || s.Budget.Limited()
+}
+
+// SelectCities filters cities by CityID, drops those in done when Missing is set and
+// applies Limit
+func (s Selection) SelectCities(cities []models.Location, done map[string]bool) []models.Location {
+	var selected []models.Location
+	for _, city := range cities {
+		if s.CityID != "" && city.CityID != s.CityID {
+			continue
+		}
+		if s.Missing && done[city.CityID] {
+			continue
+		}
+		selected = append(selected, city)
+	}
+	if s.Limit > 0 && len(selected) > s.Limit {
+		selected = selected[:s.Limit]
+	}
+	return selected
+}
+
+// SelectProperties filters properties by CityID and PropertyIDs, drops those in done when
+// Missing is set, orders them by review count when TopReviewed is set and applies Limit
+func (s Selection) SelectProperties(properties []models.Property, done map[int]bool) []models.Property {
+	ids := make(map[int]bool, len(s.PropertyIDs))
+	for _, id := range s.PropertyIDs {
+		ids[id] = true
+	}
+
+	var selected []models.Property
+	for _, property := range properties {
+		if s.CityID != "" && property.CityID != s.CityID {
+			continue
+		}
+		if len(ids) > 0 && !ids[property.HotelID] {
+			continue
+		}
+		if s.Missing && done[property.HotelID] {
+			continue
+		}
+		selected = append(selected, property)
+	}
+
+	if s.TopReviewed {
+		sort.SliceStable(selected, func(i, k int) bool {
+			return selected[i].ReviewCount > selected[k].ReviewCount
+		})
+	}
+	if s.Limit > 0 && len(selected) > s.Limit {
+		selected = selected[:s.Limit]
+	}
+	return selected
+}
+
+// String describes the selection for logs and dry runs
+func (s Selection) String() string {
+	var parts []string
+	if s.CityID != "" {
+		parts = append(parts, "city "+s.CityID)
+	}
+	if len(s.PropertyIDs) > 0 {
+		parts = append(parts, fmt.Sprintf("%d property IDs", len(s.PropertyIDs)))
+	}
+	if s.Missing {
+		parts = append(parts, "missing data only")
+	}
+	if s.TopReviewed {
+		parts = append(parts, "most reviewed first")
+	}
+	if s.Limit > 0 {
+		parts = append(parts, fmt.Sprintf("limit %d", s.Limit))
+	}
+	if s.Budget.Limited() {
+		parts = append(parts, fmt.Sprintf("%d calls left in budget", s.Budget.Remaining()))
+	}
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseSelection reads a selection from request parameters: city (ID or name), ids
+// (comma-separated hotel IDs), order=reviews, missing=true, limit, budget, and refresh=true
+// or no_cache=true to bypass the response cache. Without a budget parameter the
+// ingest::call_budget setting applies.
+func ParseSelection(values url.Values) (Selection, error) {
+	var selection Selection
+
+	if city := strings.TrimSpace(values.Get("city")); city != "" {
+		cityID, err := resolveCityID(city)
+		if err != nil {
+			return selection, err
+		}
+		selection.CityID = cityID
+	}
+
+	ids, err := ParsePropertyIDs(values.Get("ids"))
+	if err != nil {
+		return selection, err
+	}
+	selection.PropertyIDs = ids
+
+	switch order := values.Get("order"); order {
+	case "":
+	case "reviews":
+		selection.TopReviewed = true
+	default:
+		return selection, fmt.Errorf("unknown order %q; use reviews", order)
+	}
+
+	if missing := values.Get("missing"); missing != "" {
+		if selection.Missing, err = strconv.ParseBool(missing); err != nil {
+			return selection, fmt.Errorf("invalid missing %q", missing)
+		}
+	}
+	if selection.Limit, err = nonNegativeParam(values, "limit"); err != nil {
+		return selection, err
+	}
+
+	budget := ConfiguredCallBudget()
+	if values.Get("budget") != "" {
+		if budget, err = nonNegativeParam(values, "budget"); err != nil {
+			return selection, err
+		}
+	}
+	selection.Budget = NewCallBudget(budget)
+
+	for param, mode := range map[string]utils.CacheMode{"refresh": utils.CacheRefresh, "no_cache": utils.CacheOff} {
+		if raw := values.Get(param); raw != "" {
+			set, err := strconv.ParseBool(raw)
+			if err != nil {
+				return selection, fmt.Errorf("invalid %s %q", param, raw)
+			}
+			if set && mode > selection.Cache {
+				selection.Cache = mode
+			}
+		}
+	}
+	return selection, nil
+}
+
+// ParsePropertyIDs parses a comma-separated list of hotel IDs
+func ParsePropertyIDs(raw string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid property ID %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func nonNegativeParam(values url.Values, name string) (int, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, raw)
+	}
+	return n, nil
+}
+
+// ConfiguredCallBudget returns ingest::call_budget, the default provider call budget of a
+// run; 0 means unlimited
+func ConfiguredCallBudget() int {
+	return beego.AppConfig.DefaultInt("ingest::call_budget", 0)
+}
+
+// CallBudget counts provider calls against a limit shared by every stage of a run. A nil
+// budget is unlimited.
+type CallBudget struct {
+	mu    sync.Mutex
+	limit int
+	used  int
+}
+
+// NewCallBudget returns a budget of limit calls, or nil (unlimited) when limit is 0
+func NewCallBudget(limit int) *CallBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &CallBudget{limit: limit}
+}
+
+// Take spends one call and reports whether the budget allowed it
+func (b *CallBudget) Take() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used >= b.limit {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// Refund gives back a call taken for a request the response cache answered
+func (b *CallBudget) Refund() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used > 0 {
+		b.used--
+	}
+}
+
+// Limited reports whether the budget caps calls at all
+func (b *CallBudget) Limited() bool {
+	return b != nil
+}
+
+// Used returns the calls spent so far
+func (b *CallBudget) Used() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Remaining returns the calls left, or -1 when unlimited
+func (b *CallBudget) Remaining() int {
+	if b == nil {
+		return -1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit - b.used
+}
+
+// ResolveCity finds a city by ID or, case-insensitively, by name
+func ResolveCity(cities []models.Location, city string) (*models.Location, error) {
+	for i := range cities {
+		if cities[i].CityID == city || strings.EqualFold(cities[i].CityName, city) {
+			return &cities[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown city %q", city)
+}
+
+// resolveCityID turns a city ID or name into a city ID using data/cities.json
+func resolveCityID(city string) (string, error) {
+	cities, err := NewPropertyService().LoadCities()
+	if err != nil {
+		return "", err
+	}
+	location, err := ResolveCity(cities, city)
+	if err != nil {
+		return "", err
+	}
+	return location.CityID, nil
+}
+
+// readRecords reads the JSON array stored at path; a missing file has no records
+func readRecords[T any](path string) ([]T, error) {
+	var stored []T
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s: %v", path, err)
+	}
+	return stored, nil
+}
+
+// storedIDs returns the IDs of the records stored at path
+func storedIDs[T any, K comparable](path string, id func(T) K) (map[K]bool, error) {
+	stored, err := readRecords[T](path)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[K]bool, len(stored))
+	for _, record := range stored {
+		ids[id(record)] = true
+	}
+	return ids, nil
+}
+
+// mergeRecords reads the records stored at path, drops those fresh replaces and appends fresh
+func mergeRecords[T any](path string, fresh []T, replaced func(T) bool) ([]T, error) {
+	stored, err := readRecords[T](path)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make([]T, 0, len(stored)+len(fresh))
+	for _, record := range stored {
+		if !replaced(record) {
+			merged = append(merged, record)
+		}
+	}
+	return append(merged, fresh...), nil
+}
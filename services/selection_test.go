@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// namedDetailsProvider answers stays/detail for the hotels in names and fails the others
+type namedDetailsProvider struct {
+	utils.ListingsProvider
+	names map[int]string
+}
+
+func (p *namedDetailsProvider) FetchPropertyDetails(ctx context.Context, hotelID int, checkIn, checkOut string) (*models.StayDetailResponse, error) {
+	name, ok := p.names[hotelID]
+	if !ok {
+		return nil, errors.New("provider unavailable")
+	}
+	return &models.StayDetailResponse{Status: true, Data: models.StayDetail{HotelID: hotelID, HotelName: name}}, nil
+}
+
+// TestMergeRecords checks that a partial run replaces the stored records of the items it
+// fetched and keeps those of the items it failed to fetch
+func TestMergeRecords(t *testing.T) {
+	Convey("Subject: merging a partial run into the stored records\n", t, func() {
+		dir := t.TempDir()
+		storage := filepath.Join(dir, "property_details.json")
+		stored, err := json.Marshal([]models.PropertyDetail{
+			{HotelID: 1, PropertyType: "Hotels"},
+			{HotelID: 2, PropertyType: "Hotels"},
+			{HotelID: 3, PropertyType: "Hotels"},
+		})
+		So(err, ShouldBeNil)
+		So(os.WriteFile(storage, stored, 0644), ShouldBeNil)
+
+		Convey("Stored records the run replaced give way to the fresh ones", func() {
+			merged, err := mergeRecords(storage, []models.PropertyDetail{{HotelID: 1, PropertyType: "Apartments"}},
+				func(d models.PropertyDetail) bool { return d.HotelID == 1 })
+			So(err, ShouldBeNil)
+			So(len(merged), ShouldEqual, 3)
+			So(merged[2].PropertyType, ShouldEqual, "Apartments")
+		})
+
+		Convey("A failed fetch keeps the stored record of its property", func() {
+			properties := filepath.Join(dir, "properties.json")
+			So(os.WriteFile(properties, []byte(`[{"name":"Canal House","id":1},{"name":"Dam Suites","id":2}]`), 0644), ShouldBeNil)
+
+			service := &PropertyDetailsService{
+				Provider:       &namedDetailsProvider{names: map[int]string{1: "Canal House"}},
+				StoragePath:    storage,
+				PropertiesPath: properties,
+				Workers:        1,
+				Selection:      Selection{PropertyIDs: []int{1, 2}},
+			}
+			_, err := service.FetchPropertyDetails(context.Background())
+			So(err, ShouldBeNil)
+
+			var saved []models.PropertyDetail
+			data, err := os.ReadFile(storage)
+			So(err, ShouldBeNil)
+			So(json.Unmarshal(data, &saved), ShouldBeNil)
+
+			ids := map[int]int{}
+			for _, detail := range saved {
+				ids[detail.HotelID]++
+			}
+			So(ids, ShouldResemble, map[int]int{1: 1, 2: 1, 3: 1})
+		})
+	})
+}
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	"github.com/beego/beego/v2/client/orm"
+	beego "github.com/beego/beego/v2/server/web"
+	"github.com/lib/pq"
+)
+
+// Stay search settings used when [availability] does not set them
+const (
+	DefaultAvailabilityMaxAge      = 24 * time.Hour
+	DefaultAvailabilityLiveLookups = 10
+	// MaxStayNights caps the stay of a list request
+	MaxStayNights = 30
+)
+
+// Sources of a StayQuote
+const (
+	StaySourceCalendar = "calendar"
+	StaySourceLive     = "live"
+	// StaySourceUnverified marks a listing whose stay could not be checked; it has no total
+	StaySourceUnverified = "unverified"
+)
+
+// StaySearch reports how the stay of a list request was checked
+type StaySearch struct {
+	CheckIn  string `json:"checkIn"`
+	CheckOut string `json:"checkOut"`
+	Nights   int    `json:"nights"`
+	Guests   int    `json:"guests"`
+	// Live counts the listings of the page looked up at the provider because stored nights
+	// were missing or older than availability::max_age
+	Live int `json:"live"`
+	// Unverified counts the listings of the page returned unverified because they needed a
+	// lookup beyond availability::live_lookups or the lookup failed
+	Unverified int `json:"unverified"`
+	// Dropped counts the listings of the page a live lookup found unbookable. They were
+	// counted before the lookup, as were the unchecked listings of other pages, so total,
+	// totalPages and the facets are upper bounds, which TotalIsUpperBound states.
+	Dropped           int  `json:"dropped"`
+	TotalIsUpperBound bool `json:"totalIsUpperBound"`
+}
+
+// StayService answers whether properties can be booked for a stay, from property_calendar
+// where it is fresh and from the provider where it is not
+type StayService struct {
+	// Provider answers live lookups; nil shares one provider that bypasses the response cache
+	Provider utils.ListingsProvider
+	// MaxAge is how old a stored night may be before the provider is asked again
+	MaxAge time.Duration
+	// LiveLookups caps the provider calls of one request
+	LiveLookups int
+	Workers     int
+
+	now func() time.Time
+}
+
+// NewStayService reads availability::max_age and live_lookups
+func NewStayService() *StayService {
+	maxAge := DefaultAvailabilityMaxAge
+	if raw := beego.AppConfig.DefaultString("availability::max_age", ""); raw != "" {
+		if parsed, err := time.ParseDuration(raw); err == nil {
+			maxAge = parsed
+		} else {
+			fmt.Printf("Warning: invalid availability::max_age %q, keeping %v\n", raw, maxAge)
+		}
+	}
+	return &StayService{
+		MaxAge:      maxAge,
+		LiveLookups: beego.AppConfig.DefaultInt("availability::live_lookups", DefaultAvailabilityLiveLookups),
+		Workers:     IngestWorkers(),
+		now:         time.Now,
+	}
+}
+
+// StayNights returns the nights of a stay, from checkIn up to the night before checkOut
+func StayNights(checkIn, checkOut string) []string {
+	first, err := time.Parse("2006-01-02", checkIn)
+	if err != nil {
+		return nil
+	}
+	last, err := time.Parse("2006-01-02", checkOut)
+	if err != nil {
+		return nil
+	}
+	var nights []string
+	for day := first; day.Before(last); day = day.AddDate(0, 0, 1) {
+		nights = append(nights, day.Format("2006-01-02"))
+	}
+	return nights
+}
+
+// staleBefore is the fetch time before which stored nights and lookups are asked again
+func (s *StayService) staleBefore() time.Time {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	return now().Add(-s.MaxAge)
+}
+
+// Quote returns the quotes of the properties among ids that can be booked for the stay of
+// q, keyed by property id; properties that cannot be booked are absent. Properties the
+// calendar cannot answer are answered by a lookup of the last max_age, or looked up live in
+// the order of ids up to LiveLookups; the rest get a StaySourceUnverified quote.
+func (s *StayService) Quote(ctx context.Context, ids []int64, q *PropertyListQuery, converter *CurrencyConverter) (map[int64]models.StayQuote, *StaySearch, error) {
+	search := &StaySearch{CheckIn: q.CheckIn, CheckOut: q.CheckOut, Guests: stayGuests(q)}
+	nights := StayNights(q.CheckIn, q.CheckOut)
+	search.Nights = len(nights)
+
+	stored, err := s.storedNights(ids, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	staleBefore := s.staleBefore()
+
+	quotes := map[int64]models.StayQuote{}
+	var stale []int64
+	for _, id := range ids {
+		quote, available, answered := stayFromCalendar(q, nights, stored[id], staleBefore, converter)
+		if !answered {
+			if stay, ok := stayLookups.get(id, q, staleBefore); ok {
+				quote, available, answered = stayFromLookup(q, len(nights), stay, converter)
+			}
+		}
+		switch {
+		case !answered:
+			stale = append(stale, id)
+		case available:
+			quotes[id] = quote
+		}
+	}
+
+	lookups := stale
+	if len(lookups) > s.LiveLookups {
+		lookups = stale[:max(s.LiveLookups, 0)]
+	}
+	unverified := stale[len(lookups):]
+	if len(lookups) > 0 {
+		live, err := s.lookup(ctx, lookups, q)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, result := range live {
+			if !result.Attempted || result.Err != nil {
+				if result.Err != nil {
+					fmt.Printf("Error looking up stay of property %d: %v\n", lookups[i], result.Err)
+				}
+				unverified = append(unverified, lookups[i])
+				continue
+			}
+			search.Live++
+			if quote, available, _ := stayFromLookup(q, len(nights), result.Value, converter); available {
+				quotes[lookups[i]] = quote
+			}
+		}
+	}
+
+	search.Unverified = len(unverified)
+	for _, id := range unverified {
+		quotes[id] = models.StayQuote{CheckIn: q.CheckIn, CheckOut: q.CheckOut, Nights: len(nights), Source: StaySourceUnverified}
+	}
+	return quotes, search, nil
+}
+
+// storedNights reads the sampled nights of the stay for ids
+func (s *StayService) storedNights(ids []int64, q *PropertyListQuery) (map[int64][]models.CalendarNight, error) {
+	stored := map[int64][]models.CalendarNight{}
+	if len(ids) == 0 {
+		return stored, nil
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+	rows, err := db.Query(`
+		SELECT property_id, to_char(night, 'YYYY-MM-DD'), available, price, currency, max_occupancy, fetched_at
+		FROM property_calendar
+		WHERE property_id = ANY($1) AND night >= $2::date AND night < $3::date`,
+		pq.Array(ids), q.CheckIn, q.CheckOut)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve availability: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var night models.CalendarNight
+		err := rows.Scan(&night.PropertyID, &night.Night, &night.Available, &night.Price, &night.Currency, &night.MaxOccupancy, &night.FetchedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read availability: %v", err)
+		}
+		stored[night.PropertyID] = append(stored[night.PropertyID], night)
+	}
+	return stored, rows.Err()
+}
+
+// stayFromCalendar answers a stay from the stored nights of one property. A fresh night that
+// is unavailable or too small answers no on its own; otherwise every night must be stored
+// and fresh, and answered is false when one is not. The total is the sum of the one-night
+// prices, nil when a night has none or the nights do not convert to one currency.
+func stayFromCalendar(q *PropertyListQuery, nights []string, stored []models.CalendarNight, staleBefore time.Time, converter *CurrencyConverter) (quote models.StayQuote, available, answered bool) {
+	byNight := make(map[string]models.CalendarNight, len(stored))
+	for _, night := range stored {
+		byNight[night.Night] = night
+	}
+
+	guests := stayGuests(q)
+	complete := true
+	for _, date := range nights {
+		night, ok := byNight[date]
+		if !ok || night.FetchedAt.Before(staleBefore) {
+			complete = false
+			continue
+		}
+		if !night.Available || night.MaxOccupancy < guests {
+			return models.StayQuote{}, false, true
+		}
+	}
+	if !complete {
+		return models.StayQuote{}, false, false
+	}
+
+	quote = models.StayQuote{CheckIn: q.CheckIn, CheckOut: q.CheckOut, Nights: len(nights), Source: StaySourceCalendar}
+	total := 0.0
+	priced := true
+	for i, date := range nights {
+		night := byNight[date]
+		if i == 0 || night.FetchedAt.Before(quote.FetchedAt) {
+			quote.FetchedAt = night.FetchedAt
+		}
+		if night.Price == nil {
+			priced = false
+			continue
+		}
+		price, currency, converted := converter.Amount(*night.Price, night.Currency)
+		if quote.Currency != "" && currency != quote.Currency {
+			priced = false
+		}
+		quote.Unconverted = quote.Unconverted || !converted
+		quote.Currency = currency
+		total += price
+	}
+	if priced {
+		total = math.Round(total*100) / 100
+		quote.Total = &total
+	} else {
+		quote.Currency, quote.Unconverted = "", false
+	}
+	return quote, true, true
+}
+
+// stayFromLookup answers a stay from a provider lookup of the whole stay, read like a
+// calendar night
+func stayFromLookup(q *PropertyListQuery, nights int, stay models.CalendarNight, converter *CurrencyConverter) (quote models.StayQuote, available, answered bool) {
+	if !stay.Available || stay.MaxOccupancy < stayGuests(q) {
+		return models.StayQuote{}, false, true
+	}
+	quote = models.StayQuote{CheckIn: q.CheckIn, CheckOut: q.CheckOut, Nights: nights, Source: StaySourceLive, FetchedAt: stay.FetchedAt}
+	if stay.Price != nil {
+		total, currency, converted := converter.Amount(*stay.Price, stay.Currency)
+		quote.Total, quote.Currency, quote.Unconverted = &total, currency, !converted
+	}
+	return quote, true, true
+}
+
+var (
+	stayProviderOnce sync.Once
+	stayProvider     utils.ListingsProvider
+)
+
+// sharedStayProvider returns the provider of live lookups. It never uses the response
+// cache, whose details are not keyed on their dates and can be a week old.
+func sharedStayProvider() utils.ListingsProvider {
+	stayProviderOnce.Do(func() {
+		stayProvider = utils.NewListingsProvider()
+		utils.SetCacheMode(stayProvider, utils.CacheOff)
+	})
+	return stayProvider
+}
+
+// lookup asks the provider for the whole stay at each of ids. Every answer is kept in
+// stayLookups for max_age, and one-night stays are stored in property_calendar as well.
+func (s *StayService) lookup(ctx context.Context, ids []int64, q *PropertyListQuery) ([]fetchResult[models.CalendarNight], error) {
+	provider := s.Provider
+	if provider == nil {
+		provider = sharedStayProvider()
+	}
+
+	nights := StayNights(q.CheckIn, q.CheckOut)
+	var sampled []models.CalendarNight
+	var mu sync.Mutex
+
+	pool := workerPool{Workers: s.Workers}
+	results, err := runPool(ctx, pool, ids, func(ctx context.Context, id int64) (models.CalendarNight, error) {
+		response, err := provider.FetchPropertyDetails(ctx, int(id), q.CheckIn, q.CheckOut)
+		if err != nil {
+			return models.CalendarNight{}, err
+		}
+		stay := calendarNight(id, q.CheckIn, &response.Data, time.Now())
+		mu.Lock()
+		sampled = append(sampled, stay)
+		mu.Unlock()
+		return stay, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stay lookup aborted: %v", err)
+	}
+
+	staleBefore := s.staleBefore()
+	for _, stay := range sampled {
+		stayLookups.put(stay.PropertyID, q, stay, staleBefore)
+	}
+	if len(nights) == 1 && len(sampled) > 0 {
+		db, err := orm.GetDB("default")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get database connection: %v", err)
+		}
+		if err := utils.SaveCalendarNights(db, sampled); err != nil {
+			fmt.Printf("Warning: failed to store looked up nights: %v\n", err)
+		}
+	}
+	return results, nil
+}
+
+// stayGuests is the guests of q, one when not given
+func stayGuests(q *PropertyListQuery) int {
+	if q.Guests == nil {
+		return 1
+	}
+	return *q.Guests
+}
+
+// excludedProperties returns the properties a stay search leaves out before counting: those
+// with a confirmed reservation overlapping the stay of q, checked as Create checks it, those
+// with a night fetched within max_age that is unavailable or too small, and those a lookup
+// of the last max_age found unbookable. The rest are quoted page by page.
+func (s *StayService) excludedProperties(q *PropertyListQuery) ([]int64, error) {
+	staleBefore := s.staleBefore()
+	excluded := stayLookups.unbookable(q, staleBefore)
+
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+	rows, err := db.Query(`
+		SELECT property_id FROM reservation
+		WHERE status = 'confirmed'
+		AND daterange(check_in, check_out, '[)') && daterange($1::date, $2::date, '[)')
+		UNION
+		SELECT property_id FROM property_calendar
+		WHERE night >= $1::date AND night < $2::date AND fetched_at >= $3
+		AND (NOT available OR max_occupancy < $4)`,
+		q.CheckIn, q.CheckOut, staleBefore, stayGuests(q))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up unbookable properties: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read unbookable property: %v", err)
+		}
+		excluded = append(excluded, id)
+	}
+	return excluded, rows.Err()
+}
+
+// stayFilterSQL is a property_id condition leaving out excluded. FilterRaw takes no bind
+// parameters, so excludedProperties resolves the ids first and only integers reach the SQL.
+func stayFilterSQL(excluded []int64) string {
+	ids := make([]string, len(excluded))
+	for i, id := range excluded {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return "NOT IN (" + strings.Join(ids, ", ") + ")"
+}
+
+// stayLookupKey is a property and a stay
+type stayLookupKey struct {
+	PropertyID int64
+	CheckIn    string
+	CheckOut   string
+}
+
+// stayLookupCache keeps the live lookups of every stay, whatever the guests, so a list page
+// or reservation asking again within max_age does not call the provider
+type stayLookupCache struct {
+	mu    sync.Mutex
+	stays map[stayLookupKey]models.CalendarNight
+}
+
+var stayLookups = &stayLookupCache{stays: map[stayLookupKey]models.CalendarNight{}}
+
+// get returns the lookup of the stay of q at id when it was fetched after staleBefore
+func (c *stayLookupCache) get(id int64, q *PropertyListQuery, staleBefore time.Time) (models.CalendarNight, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stay, ok := c.stays[stayLookupKey{id, q.CheckIn, q.CheckOut}]
+	if !ok || stay.FetchedAt.Before(staleBefore) {
+		return models.CalendarNight{}, false
+	}
+	return stay, true
+}
+
+// unbookable returns the properties whose lookup of the stay of q, fetched after staleBefore,
+// found them unavailable or too small for its guests
+func (c *stayLookupCache) unbookable(q *PropertyListQuery, staleBefore time.Time) []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []int64
+	for key, stay := range c.stays {
+		if key.CheckIn != q.CheckIn || key.CheckOut != q.CheckOut || stay.FetchedAt.Before(staleBefore) {
+			continue
+		}
+		if !stay.Available || stay.MaxOccupancy < stayGuests(q) {
+			ids = append(ids, key.PropertyID)
+		}
+	}
+	return ids
+}
+
+// put keeps stay and drops the lookups fetched before staleBefore
+func (c *stayLookupCache) put(id int64, q *PropertyListQuery, stay models.CalendarNight, staleBefore time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, cached := range c.stays {
+		if cached.FetchedAt.Before(staleBefore) {
+			delete(c.stays, key)
+		}
+	}
+	c.stays[stayLookupKey{id, q.CheckIn, q.CheckOut}] = stay
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"backend_rental/models"
+	"backend_rental/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestStaySearch checks the stay parameters of the list endpoint and how stored nights
+// answer a stay
+func TestStaySearch(t *testing.T) {
+	Convey("Subject: stay search on the property list\n", t, func() {
+		Convey("check_in and check_out give the nights of the stay", func() {
+			q, err := ParsePropertyListQuery(url.Values{"check_in": {"2026-11-03"}, "check_out": {"2026-11-07"}, "guests": {"2"}})
+			So(err, ShouldBeNil)
+			So(*q.Guests, ShouldEqual, 2)
+			So(StayNights(q.CheckIn, q.CheckOut), ShouldResemble, []string{"2026-11-03", "2026-11-04", "2026-11-05", "2026-11-06"})
+
+			_, err = ParsePropertyListQuery(url.Values{"check_in": {"2026-11-03"}})
+			So(err, ShouldNotBeNil)
+			_, err = ParsePropertyListQuery(url.Values{"check_in": {"2026-11-07"}, "check_out": {"2026-11-07"}})
+			So(err, ShouldNotBeNil)
+			_, err = ParsePropertyListQuery(url.Values{"check_in": {"2026-11-01"}, "check_out": {"2026-12-15"}})
+			So(err, ShouldNotBeNil)
+			_, err = ParsePropertyListQuery(url.Values{"guests": {"0"}})
+			So(err, ShouldNotBeNil)
+		})
+
+		fetched := time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC)
+		staleBefore := fetched.Add(-time.Hour)
+		converter := &CurrencyConverter{Rates: utils.CurrencyRates{"EUR": 1, "USD": 1.1}, Currency: "EUR"}
+		guests := 2
+		q := &PropertyListQuery{CheckIn: "2026-11-03", CheckOut: "2026-11-05", Guests: &guests}
+		nights := StayNights(q.CheckIn, q.CheckOut)
+		night := func(date string, available bool, price float64, currency string) models.CalendarNight {
+			return models.CalendarNight{PropertyID: 1, Night: date, Available: available, Price: &price, Currency: currency, MaxOccupancy: 4, FetchedAt: fetched}
+		}
+
+		Convey("Fresh available nights sum to the stay total", func() {
+			quote, available, answered := stayFromCalendar(q, nights, []models.CalendarNight{
+				night("2026-11-03", true, 120, "EUR"), night("2026-11-04", true, 110, "USD"),
+			}, staleBefore, converter)
+			So(answered, ShouldBeTrue)
+			So(available, ShouldBeTrue)
+			So(*quote.Total, ShouldEqual, 220)
+			So(quote.Currency, ShouldEqual, "EUR")
+			So(quote.Nights, ShouldEqual, 2)
+			So(quote.Source, ShouldEqual, StaySourceCalendar)
+		})
+
+		Convey("A total in a currency without a rate is flagged unconverted", func() {
+			quote, available, _ := stayFromCalendar(q, nights, []models.CalendarNight{
+				night("2026-11-03", true, 120, "CHF"), night("2026-11-04", true, 110, "CHF"),
+			}, staleBefore, converter)
+			So(available, ShouldBeTrue)
+			So(*quote.Total, ShouldEqual, 230)
+			So(quote.Currency, ShouldEqual, "CHF")
+			So(quote.Unconverted, ShouldBeTrue)
+
+			price := models.PropertyPrice{Currency: "CHF", Gross: 230}
+			converter.Convert(&price)
+			So(price.Currency, ShouldEqual, "CHF")
+			So(price.Unconverted, ShouldBeTrue)
+		})
+
+		Convey("One fresh unavailable night rules the stay out without a lookup", func() {
+			old := night("2026-11-04", true, 110, "EUR")
+			old.FetchedAt = staleBefore.Add(-time.Hour)
+			_, available, answered := stayFromCalendar(q, nights, []models.CalendarNight{
+				night("2026-11-03", false, 0, ""), old,
+			}, staleBefore, converter)
+			So(answered, ShouldBeTrue)
+			So(available, ShouldBeFalse)
+		})
+
+		Convey("Too many guests rule the stay out", func() {
+			small := night("2026-11-04", true, 110, "EUR")
+			small.MaxOccupancy = 1
+			_, available, answered := stayFromCalendar(q, nights, []models.CalendarNight{night("2026-11-03", true, 120, "EUR"), small}, staleBefore, converter)
+			So(answered, ShouldBeTrue)
+			So(available, ShouldBeFalse)
+		})
+
+		Convey("A live lookup answers the stay again until it is older than max_age", func() {
+			price := 480.0
+			stay := models.CalendarNight{PropertyID: 7, Night: q.CheckIn, Available: true, Price: &price, Currency: "USD", MaxOccupancy: 2, FetchedAt: fetched}
+			cache := &stayLookupCache{stays: map[stayLookupKey]models.CalendarNight{}}
+			cache.put(7, q, stay, staleBefore)
+
+			cached, ok := cache.get(7, q, staleBefore)
+			So(ok, ShouldBeTrue)
+			quote, available, answered := stayFromLookup(q, len(nights), cached, converter)
+			So(answered, ShouldBeTrue)
+			So(available, ShouldBeTrue)
+			So(quote.Source, ShouldEqual, StaySourceLive)
+			So(*quote.Total, ShouldEqual, 436.36)
+			So(quote.Currency, ShouldEqual, "EUR")
+
+			_, ok = cache.get(7, &PropertyListQuery{CheckIn: q.CheckIn, CheckOut: "2026-11-06"}, staleBefore)
+			So(ok, ShouldBeFalse)
+			_, ok = cache.get(7, q, fetched.Add(time.Minute))
+			So(ok, ShouldBeFalse)
+
+			crowd := 3
+			_, available, _ = stayFromLookup(&PropertyListQuery{CheckIn: q.CheckIn, CheckOut: q.CheckOut, Guests: &crowd}, len(nights), cached, converter)
+			So(available, ShouldBeFalse)
+		})
+
+		Convey("Lookups that found the stay unbookable are left out before counting", func() {
+			cache := &stayLookupCache{stays: map[stayLookupKey]models.CalendarNight{}}
+			cache.put(7, q, models.CalendarNight{PropertyID: 7, Available: true, MaxOccupancy: 2, FetchedAt: fetched}, staleBefore)
+			cache.put(8, q, models.CalendarNight{PropertyID: 8, Available: false, FetchedAt: fetched}, staleBefore)
+			cache.put(9, &PropertyListQuery{CheckIn: q.CheckIn, CheckOut: "2026-11-06"}, models.CalendarNight{PropertyID: 9, FetchedAt: fetched}, staleBefore)
+
+			So(cache.unbookable(q, staleBefore), ShouldResemble, []int64{8})
+			crowd := 3
+			So(len(cache.unbookable(&PropertyListQuery{CheckIn: q.CheckIn, CheckOut: q.CheckOut, Guests: &crowd}, staleBefore)), ShouldEqual, 2)
+			So(cache.unbookable(q, fetched.Add(time.Minute)), ShouldBeEmpty)
+			So(stayFilterSQL([]int64{8, 12}), ShouldEqual, "NOT IN (8, 12)")
+		})
+
+		Convey("A missing or stale night leaves the stay to a live lookup", func() {
+			_, _, answered := stayFromCalendar(q, nights, []models.CalendarNight{night("2026-11-03", true, 120, "EUR")}, staleBefore, converter)
+			So(answered, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"backend_rental/utils"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// ErrBudgetExhausted is returned by a fetch that needs another provider call than the one
+// the pool charged for it when the call budget has none left
+var ErrBudgetExhausted = errors.New("call budget exhausted")
+
+// extraCallsKey is the context key of the budget units an item took with takeExtraCall
+type extraCallsKey struct{}
+
+// takeExtraCall charges the budget for a provider call an item needs beyond the one the pool
+// charged for it. The pool refunds it like the item's own unit when no call spends it.
+func takeExtraCall(ctx context.Context, budget *CallBudget) bool {
+	if !budget.Take() {
+		return false
+	}
+	if extra, ok := ctx.Value(extraCallsKey{}).(*atomic.Int32); ok {
+		extra.Add(1)
+	}
+	return true
+}
+
+// DefaultIngestWorkers is how many provider calls an enrichment stage runs at once when
+// ingest::workers is not set
+const DefaultIngestWorkers = 4
+
+// IngestWorkers returns ingest::workers, the size of the enrichment worker pools
+func IngestWorkers() int {
+	workers := beego.AppConfig.DefaultInt("ingest::workers", DefaultIngestWorkers)
+	if workers < 1 {
+		return 1
+	}
+	return workers
+}
+
+// workerPool fans provider calls out over a bounded number of goroutines. Every call that
+// reaches the provider waits on its shared limiter, so more workers only help while the
+// limiter has tokens or the responses come from the cache.
+type workerPool struct {
+	Workers  int
+	Budget   *CallBudget
+	Progress ProgressReporter
+}
+
+// fetchResult is the outcome of one item; Attempted is false for items the pool never
+// called the provider for because the budget ran out
+type fetchResult[R any] struct {
+	Value     R
+	Err       error
+	Attempted bool
+}
+
+// runPool calls fetch for each item and returns the results in the order of items,
+// whatever order the workers finish in. Items are handed out in order and the budget is
+// spent as they are, so an exhausted budget always leaves the tail of items unattempted.
+// Units an item was charged for, its own and those of takeExtraCall, go back to the budget
+// when the response cache answered the requests they paid for or the item gave up with
+// ErrBudgetExhausted, and once the budget runs out the pool waits for the items in flight
+// before giving up.
+// A utils.ErrQuotaExhausted or ErrBudgetExhausted result stops handing out items the same way
+// and leaves its item unattempted, since the provider returned nothing for it. Cancelling ctx
+// stops handing out items, aborts waiting workers and returns ctx.Err().
+func runPool[T, R any](ctx context.Context, pool workerPool, items []T, fetch func(ctx context.Context, item T) (R, error)) ([]fetchResult[R], error) {
+	results := make([]fetchResult[R], len(items))
+	progress := progressOrNoop(pool.Progress)
+
+	workers := pool.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	// quotaHit is closed by the first worker whose call finds the provider quota used up
+	quotaHit := make(chan struct{})
+	var quotaOnce sync.Once
+
+	// finished receives one value per item a worker is done with
+	finished := make(chan struct{}, len(items))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				extra := &atomic.Int32{}
+				itemCtx, count := utils.WithCallCount(context.WithValue(ctx, extraCallsKey{}, extra))
+				results[i].Value, results[i].Err = fetch(itemCtx, items[i])
+				if count.Hits() > 0 || errors.Is(results[i].Err, ErrBudgetExhausted) {
+					for unused := 1 + int(extra.Load()) - count.Calls(); unused > 0; unused-- {
+						pool.Budget.Refund()
+					}
+				}
+				finished <- struct{}{}
+				if errors.Is(results[i].Err, utils.ErrQuotaExhausted) || errors.Is(results[i].Err, ErrBudgetExhausted) {
+					results[i].Attempted = false
+					quotaOnce.Do(func() { close(quotaHit) })
+					continue
+				}
+				progress.Advance(1)
+			}
+		}()
+	}
+
+	inFlight := 0
+
+dispatch:
+	for i := range items {
+		select {
+		case <-quotaHit:
+			break dispatch
+		default:
+		}
+		// Calls of the items in flight may still come back from the cache
+		for !pool.Budget.Take() {
+			if inFlight == 0 {
+				break dispatch
+			}
+			select {
+			case <-finished:
+				inFlight--
+			case <-quotaHit:
+				break dispatch
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		results[i].Attempted = true
+		select {
+		case indexes <- i:
+			inFlight++
+		case <-quotaHit:
+			results[i].Attempted = false
+			break dispatch
+		case <-ctx.Done():
+			results[i].Attempted = false
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend_rental/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestRunPool checks that results keep the order of items and that the budget, an exhausted
+// provider quota and cancellation stop handing out items
+func TestRunPool(t *testing.T) {
+	Convey("Subject: fanning fetches out over a worker pool\n", t, func() {
+		items := make([]int, 10)
+		for i := range items {
+			items[i] = i
+		}
+
+		Convey("Results come back in the order of items, whatever order workers finish in", func() {
+			results, err := runPool(context.Background(), workerPool{Workers: 4}, items, func(ctx context.Context, item int) (int, error) {
+				// Later items finish first
+				time.Sleep(time.Duration(len(items)-item) * time.Millisecond)
+				return item * 10, nil
+			})
+			So(err, ShouldBeNil)
+			So(len(results), ShouldEqual, len(items))
+			for i, result := range results {
+				So(result.Attempted, ShouldBeTrue)
+				So(result.Err, ShouldBeNil)
+				So(result.Value, ShouldEqual, i*10)
+			}
+		})
+
+		Convey("An exhausted budget leaves the tail of items unattempted", func() {
+			var calls int32
+			budget := NewCallBudget(3)
+			results, err := runPool(context.Background(), workerPool{Workers: 2, Budget: budget}, items, func(ctx context.Context, item int) (int, error) {
+				atomic.AddInt32(&calls, 1)
+				return item, nil
+			})
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&calls), ShouldEqual, 3)
+			So(budget.Used(), ShouldEqual, 3)
+			for i, result := range results {
+				So(result.Attempted, ShouldEqual, i < 3)
+			}
+		})
+
+		Convey("Items answered from the response cache give their call back", func() {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.Write([]byte(`{"status":true,"data":[]}`))
+			}))
+			defer srv.Close()
+			client := &utils.ApiClient{BaseURL: srv.URL, HTTPClient: srv.Client(), Cache: utils.NewResponseCache(t.TempDir(), 0)}
+			describe := func(ctx context.Context, item int) (int, error) {
+				_, err := client.FetchPropertyDescription(ctx, fmt.Sprint(item))
+				return item, err
+			}
+			_, err := runPool(context.Background(), workerPool{Workers: 2}, items[:5], describe)
+			So(err, ShouldBeNil)
+
+			budget := NewCallBudget(7)
+			results, err := runPool(context.Background(), workerPool{Workers: 2, Budget: budget}, items, describe)
+			So(err, ShouldBeNil)
+			for _, result := range results {
+				So(result.Attempted, ShouldBeTrue)
+			}
+			So(atomic.LoadInt32(&calls), ShouldEqual, 10)
+			So(budget.Used(), ShouldEqual, 5)
+		})
+
+		Convey("Extra calls an item took are given back with its own", func() {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"status":true,"data":[]}`))
+			}))
+			defer srv.Close()
+			client := &utils.ApiClient{BaseURL: srv.URL, HTTPClient: srv.Client(), Cache: utils.NewResponseCache(t.TempDir(), 0)}
+			var budget *CallBudget
+			// Every item looks up one description with the extra call and then its own
+			describeTwice := func(ctx context.Context, item int) (int, error) {
+				if !takeExtraCall(ctx, budget) {
+					return 0, ErrBudgetExhausted
+				}
+				if _, err := client.FetchPropertyDescription(ctx, fmt.Sprint(item+100)); err != nil {
+					return 0, err
+				}
+				_, err := client.FetchPropertyDescription(ctx, fmt.Sprint(item))
+				return item, err
+			}
+			_, err := runPool(context.Background(), workerPool{Workers: 2}, items[:5], describeTwice)
+			So(err, ShouldBeNil)
+
+			Convey("Both units of an item the cache answered go back", func() {
+				budget = NewCallBudget(20)
+				results, err := runPool(context.Background(), workerPool{Workers: 2, Budget: budget}, items, describeTwice)
+				So(err, ShouldBeNil)
+				for _, result := range results {
+					So(result.Attempted, ShouldBeTrue)
+				}
+				So(budget.Used(), ShouldEqual, 10)
+			})
+
+			Convey("An item without budget for its extra call gives its own back", func() {
+				budget = NewCallBudget(1)
+				results, err := runPool(context.Background(), workerPool{Workers: 1, Budget: budget}, items[5:6], describeTwice)
+				So(err, ShouldBeNil)
+				So(results[0].Attempted, ShouldBeFalse)
+				So(budget.Used(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("A quota error stops handing out items and leaves its item unattempted", func() {
+			results, err := runPool(context.Background(), workerPool{Workers: 1}, items, func(ctx context.Context, item int) (int, error) {
+				if item == 2 {
+					return 0, fmt.Errorf("%w: daily quota", utils.ErrQuotaExhausted)
+				}
+				return item, nil
+			})
+			So(err, ShouldBeNil)
+			So(results[0].Attempted, ShouldBeTrue)
+			So(results[1].Attempted, ShouldBeTrue)
+			So(results[2].Attempted, ShouldBeFalse)
+			for _, result := range results[4:] {
+				So(result.Attempted, ShouldBeFalse)
+			}
+		})
+
+		Convey("Other errors are kept per item and do not stop the pool", func() {
+			results, err := runPool(context.Background(), workerPool{Workers: 3}, items, func(ctx context.Context, item int) (int, error) {
+				if item%2 == 1 {
+					return 0, fmt.Errorf("item %d failed", item)
+				}
+				return item, nil
+			})
+			So(err, ShouldBeNil)
+			for i, result := range results {
+				So(result.Attempted, ShouldBeTrue)
+				So(result.Err != nil, ShouldEqual, i%2 == 1)
+			}
+		})
+
+		Convey("Cancelling ctx stops the pool and returns the context error", func() {
+			many := make([]int, 200)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var calls int32
+			results, err := runPool(ctx, workerPool{Workers: 1}, many, func(ctx context.Context, item int) (int, error) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					cancel()
+				}
+				return item, nil
+			})
+			So(err, ShouldEqual, context.Canceled)
+			So(results, ShouldBeNil)
+			So(atomic.LoadInt32(&calls), ShouldBeLessThan, len(many))
+		})
+	})
+}
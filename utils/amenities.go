@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+
+	"backend_rental/models"
+)
+
+// SavePropertyAmenities replaces the amenity links of a property, creating missing amenities
+func SavePropertyAmenities(tx *sql.Tx, propertyID int64, amenities []models.Amenity) error {
+	if _, err := tx.Exec("DELETE FROM property_amenity WHERE property_id = $1", propertyID); err != nil {
+		return fmt.Errorf("failed to clear amenities of property %d: %v", propertyID, err)
+	}
+
+	for _, amenity := range amenities {
+		var amenityID int64
+		err := tx.QueryRow(`
+			INSERT INTO amenity (slug, name, category) VALUES ($1, $2, $3)
+			ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name, category = EXCLUDED.category
+			RETURNING id`,
+			amenity.Slug, amenity.Name, amenity.Category,
+		).Scan(&amenityID)
+		if err != nil {
+			return fmt.Errorf("failed to save amenity %s: %v", amenity.Slug, err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO property_amenity (property_id, amenity_id) VALUES ($1, $2)
+			ON CONFLICT (property_id, amenity_id) DO NOTHING`,
+			propertyID, amenityID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to link amenity %s to property %d: %v", amenity.Slug, propertyID, err)
+		}
+	}
+	return nil
+}
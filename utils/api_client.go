@@ -5,15 +5,40 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/beego/beego/v2/core/config"
 	"backend_rental/models"
 )
 
+// DefaultRapidAPIBaseURL is the Booking API host on RapidAPI
+const DefaultRapidAPIBaseURL = "https://booking-com18.p.rapidapi.com"
+
+// Paths of the Booking endpoints on the RapidAPI host
+const (
+	autoCompletePath   = "/stays/auto-complete"
+	staysSearchPath    = "/stays/search"
+	staysDetailPath    = "/stays/detail"
+	descriptionPath    = "/stays/get-description"
+	webStayDetailsPath = "/web/stays/details"
+)
+
+// ApiClient is the RapidAPI Booking adapter of ListingsProvider
 type ApiClient struct {
 	BaseURL string
 	Headers map[string]string
+	// HTTPClient sends the requests; nil uses a client retrying with DefaultRetryPolicy
+	HTTPClient *http.Client
+	// Limiter paces the requests that reach the provider; cache hits skip it. Nil means no pacing.
+	Limiter *AdaptiveLimiter
+	// Cache answers repeated requests from disk; nil disables caching
+	Cache     *ResponseCache
+	CacheMode CacheMode
+
+	// stayURLs caches Booking page URLs by hotel ID for FetchPropertyPhotos
+	stayURLs sync.Map
 }
 
 func NewApiClient() *ApiClient {
@@ -31,36 +56,142 @@ func NewApiClient() *ApiClient {
 		apiKey = "default_key_if_needed"
 	}
 
+	// The base URL can point at a local stand-in such as cmd/fakebooking
+	baseURL := conf.DefaultString("rapidapi_base_url", DefaultRapidAPIBaseURL)
+
 	return &ApiClient{
-		BaseURL: "https://booking-com18.p.rapidapi.com/stays/auto-complete",
+		BaseURL: strings.TrimRight(baseURL, "/"),
 		Headers: map[string]string{
 			"x-rapidapi-host": "booking-com18.p.rapidapi.com",
 			"x-rapidapi-key":  strings.TrimSpace(apiKey),
 		},
+		Limiter: ProviderLimiter(ProviderRapidAPI),
+		Cache:   SharedResponseCache(),
 	}
 }
 
-func (c *ApiClient) FetchCityData(query string) (*models.ApiResponse, error) {
-	url := fmt.Sprintf("%s?query=%s", c.BaseURL, query)
-	req, _ := http.NewRequest("GET", url, nil)
+// SetCacheMode sets how the following requests use the response cache
+func (c *ApiClient) SetCacheMode(mode CacheMode) {
+	c.CacheMode = mode
+}
+
+// newRequest builds a GET request for path on the API host with the auth headers set
+func (c *ApiClient) newRequest(path string, params url.Values) (*http.Request, error) {
+	endpoint := strings.TrimRight(c.BaseURL, "/") + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	// Set headers
 	for key, value := range c.Headers {
 		req.Header.Set(key, value)
 	}
+	return req, nil
+}
+
+// defaultHTTPClient is shared by every ApiClient without its own HTTPClient. Every response
+// feeds the RapidAPI limiter, which adapts to the quota headers. It has no overall timeout:
+// DefaultRetryPolicy bounds each attempt, and a timeout over all of them would cut off an
+// honored Retry-After before the final response is classified.
+var defaultHTTPClient = &http.Client{
+	Transport: &RetryTransport{
+		Base:   http.DefaultTransport,
+		Policy: DefaultRetryPolicy,
+		Observe: func(resp *http.Response) {
+			ProviderLimiter(ProviderRapidAPI).Observe(resp)
+		},
+	},
+}
+
+// fetch decodes the response body to req with decode, reading it from the response cache
+// when that holds a fresh copy. A body from the provider is cached only once it decodes and
+// does not report status false, which the API sends with a 200 for some failures.
+func (c *ApiClient) fetch(req *http.Request, decode func(body []byte) error) error {
+	cache := c.Cache
+	if c.CacheMode == CacheOff {
+		cache = nil
+	}
+	if cache != nil && c.CacheMode == CacheUse {
+		if body, ok := cache.Get(req); ok {
+			countRequest(req.Context(), true)
+			return decode(body)
+		}
+	}
+
+	body, err := c.get(req)
+	if err != nil {
+		return err
+	}
+	if err := decode(body); err != nil {
+		return err
+	}
+	if cache != nil && !failedPayload(body) {
+		if err := cache.Put(req, body); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// failedPayload reports whether body carries status false
+func failedPayload(body []byte) bool {
+	var envelope struct {
+		Status interface{} `json:"status"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return true
+	}
+	return envelope.Status == false
+}
+
+// get returns the raw response body to req from the provider. Failed responses become an
+// *APIError once the retries are used up.
+func (c *ApiClient) get(req *http.Request) ([]byte, error) {
+	countRequest(req.Context(), false)
+	if c.Limiter != nil {
+		if err := c.Limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
 
 	// Send request
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (c *ApiClient) FetchCityData(query string) (*models.ApiResponse, error) {
+	req, err := c.newRequest(autoCompletePath, url.Values{"query": {query}})
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse response
-	body, _ := ioutil.ReadAll(resp.Body)
 	var apiResponse models.ApiResponse
-	err = json.Unmarshal(body, &apiResponse)
+	err = c.fetch(req, func(body []byte) error {
+		return json.Unmarshal(body, &apiResponse)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+
+	"backend_rental/models"
+)
+
+// SaveCalendarNights stores sampled nights in property_calendar, replacing an earlier sample
+// of the same property and night
+func SaveCalendarNights(db sqlExecer, nights []models.CalendarNight) error {
+	for _, night := range nights {
+		_, err := db.Exec(`
+			INSERT INTO property_calendar
+				(property_id, night, available, price, currency, max_occupancy, fetched_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (property_id, night) DO UPDATE SET
+				available = EXCLUDED.available,
+				price = EXCLUDED.price,
+				currency = EXCLUDED.currency,
+				max_occupancy = EXCLUDED.max_occupancy,
+				fetched_at = EXCLUDED.fetched_at`,
+			night.PropertyID, night.Night, night.Available, night.Price, night.Currency,
+			night.MaxOccupancy, night.FetchedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save night %s of property %d: %v", night.Night, night.PropertyID, err)
+		}
+	}
+	return nil
+}
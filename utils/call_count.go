@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// CallCount records how the provider requests made under one context were answered, so a
+// caller charging a call budget can hand back what the response cache answered
+type CallCount struct {
+	calls atomic.Int32
+	hits  atomic.Int32
+}
+
+type callCountKey struct{}
+
+// WithCallCount returns a context whose provider requests are counted in the returned
+// CallCount
+func WithCallCount(ctx context.Context) (context.Context, *CallCount) {
+	count := &CallCount{}
+	return context.WithValue(ctx, callCountKey{}, count), count
+}
+
+// CachedOnly reports whether requests were made and the response cache answered all of them
+func (c *CallCount) CachedOnly() bool {
+	return c.hits.Load() > 0 && c.calls.Load() == 0
+}
+
+// Calls returns how many requests reached the provider
+func (c *CallCount) Calls() int {
+	return int(c.calls.Load())
+}
+
+// Hits returns how many requests the response cache answered
+func (c *CallCount) Hits() int {
+	return int(c.hits.Load())
+}
+
+// countRequest adds a request made under ctx, answered by the cache or by the provider
+func countRequest(ctx context.Context, cached bool) {
+	count, ok := ctx.Value(callCountKey{}).(*CallCount)
+	if !ok {
+		return
+	}
+	if cached {
+		count.hits.Add(1)
+	} else {
+		count.calls.Add(1)
+	}
+}
@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/config"
+	"github.com/lib/pq"
+)
+
+// Currency settings used when [currency] is not configured
+const (
+	DefaultBaseCurrency      = "EUR"
+	DefaultCurrencyRatesPath = "data/currency_rates.json"
+)
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// NormalizeCurrency upper-cases an ISO 4217 code such as "eur" and rejects anything else
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !currencyCodePattern.MatchString(code) {
+		return "", fmt.Errorf("invalid currency %q; use a three-letter code such as EUR", code)
+	}
+	return code, nil
+}
+
+// BaseCurrency returns currency::base, the currency prices are shown in by default
+func BaseCurrency() string {
+	code, err := NormalizeCurrency(config.DefaultString("currency::base", DefaultBaseCurrency))
+	if err != nil {
+		fmt.Printf("Warning: %v in currency::base, using %s\n", err, DefaultBaseCurrency)
+		return DefaultBaseCurrency
+	}
+	return code
+}
+
+// CurrencyRatesPath returns currency::rates_file
+func CurrencyRatesPath() string {
+	return config.DefaultString("currency::rates_file", DefaultCurrencyRatesPath)
+}
+
+// CurrencyRates maps currency codes to what one unit of a common anchor currency buys in
+// them, so any amount converts through the ratio of two rates
+type CurrencyRates map[string]float64
+
+// Convert converts amount between currencies, rounded to cents; ok is false when either
+// currency has no rate
+func (r CurrencyRates) Convert(amount float64, from, to string) (converted float64, ok bool) {
+	if from == to {
+		return amount, true
+	}
+	fromRate, fromOK := r[from]
+	toRate, toOK := r[to]
+	if !fromOK || !toOK {
+		return amount, false
+	}
+	return math.Round(amount*toRate/fromRate*100) / 100, true
+}
+
+// currencyRatesFile is the JSON rates format, as published by most rate APIs:
+//
+//	{"base": "EUR", "rates": {"USD": 1.0842, "GBP": 0.8571}}
+type currencyRatesFile struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// ReadCurrencyRatesFile reads rates from a .json file in the format of currencyRatesFile or
+// from a .csv file of currency,rate rows, all relative to the same anchor currency
+func ReadCurrencyRatesFile(path string) (CurrencyRates, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	raw := map[string]float64{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var data currencyRatesFile
+		if err := json.NewDecoder(file).Decode(&data); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		for code, rate := range data.Rates {
+			raw[code] = rate
+		}
+		if data.Base != "" {
+			raw[data.Base] = 1
+		}
+	case ".csv":
+		if raw, err = readCurrencyRatesCSV(file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported rates file %s; use .json or .csv", path)
+	}
+
+	rates := make(CurrencyRates, len(raw))
+	for code, rate := range raw {
+		normalized, err := NormalizeCurrency(code)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return nil, fmt.Errorf("%s: rate of %s must be positive", path, normalized)
+		}
+		rates[normalized] = rate
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%s has no rates", path)
+	}
+	return rates, nil
+}
+
+// readCurrencyRatesCSV reads currency,rate rows; a header row is skipped
+func readCurrencyRatesCSV(r io.Reader) (map[string]float64, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	rates := map[string]float64{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, record[1])
+		}
+		rates[record[0]] = rate
+	}
+}
+
+// LoadCurrencyRates reads the currency_rate table
+func LoadCurrencyRates() (CurrencyRates, error) {
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+	rows, err := db.Query(`SELECT currency, rate FROM currency_rate`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load currency rates: %v", err)
+	}
+	defer rows.Close()
+
+	rates := CurrencyRates{}
+	for rows.Next() {
+		var code string
+		var rate float64
+		if err := rows.Scan(&code, &rate); err != nil {
+			return nil, fmt.Errorf("failed to read currency rate: %v", err)
+		}
+		rates[code] = rate
+	}
+	return rates, rows.Err()
+}
+
+// currencyRatesRecheck is how often cached rates are compared with currency_rate, so rates
+// loaded by `rentalctl rates` reach a running server
+const currencyRatesRecheck = time.Minute
+
+var currencyRatesCache struct {
+	sync.Mutex
+	rates     CurrencyRates
+	version   string
+	checkedAt time.Time
+}
+
+// CachedCurrencyRates returns the currency_rate table, reading it again only once it has
+// changed. The cached rates are shared and must not be modified.
+func CachedCurrencyRates() (CurrencyRates, error) {
+	cache := &currencyRatesCache
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.rates != nil && time.Since(cache.checkedAt) < currencyRatesRecheck {
+		return cache.rates, nil
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+	// Every save that changes the table changes its row count or latest updated_at
+	var version string
+	err = db.QueryRow(`SELECT count(*) || '/' || coalesce(max(updated_at)::text, '') FROM currency_rate`).Scan(&version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check currency rates: %v", err)
+	}
+	if cache.rates == nil || version != cache.version {
+		rates, err := LoadCurrencyRates()
+		if err != nil {
+			return nil, err
+		}
+		cache.rates, cache.version = rates, version
+	}
+	cache.checkedAt = time.Now()
+	return cache.rates, nil
+}
+
+// InvalidateCurrencyRates makes the next CachedCurrencyRates read the table again
+func InvalidateCurrencyRates() {
+	currencyRatesCache.Lock()
+	defer currencyRatesCache.Unlock()
+	currencyRatesCache.rates = nil
+}
+
+// SaveCurrencyRates makes rates the content of currency_rate. Rates of one file share an
+// anchor currency, so currencies missing from rates are deleted rather than kept with a rate
+// relative to another anchor. Unchanged rates keep their updated_at.
+func SaveCurrencyRates(tx *sql.Tx, rates CurrencyRates, stats *SeedStats) error {
+	now := time.Now()
+	codes := make([]string, 0, len(rates))
+	for code, rate := range rates {
+		codes = append(codes, code)
+		var inserted bool
+		err := tx.QueryRow(`
+			INSERT INTO currency_rate (currency, rate, updated_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (currency) DO UPDATE SET
+				rate = EXCLUDED.rate,
+				updated_at = EXCLUDED.updated_at
+			WHERE currency_rate.rate IS DISTINCT FROM EXCLUDED.rate
+			RETURNING (xmax = 0)`, code, rate, now).Scan(&inserted)
+		switch {
+		case err == sql.ErrNoRows:
+			stats.Unchanged++
+		case err != nil:
+			return fmt.Errorf("failed to save rate of %s: %v", code, err)
+		case inserted:
+			stats.Inserted++
+		default:
+			stats.Updated++
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM currency_rate WHERE NOT (currency = ANY($1))`, pq.Array(codes))
+	if err != nil {
+		return fmt.Errorf("failed to delete stale currency rates: %v", err)
+	}
+	deleted, _ := result.RowsAffected()
+	stats.Deleted += int(deleted)
+	return nil
+}
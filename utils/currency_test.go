@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestCurrencyRates checks both rates file formats and conversion through the anchor currency
+func TestCurrencyRates(t *testing.T) {
+	Convey("Subject: exchange rates\n", t, func() {
+		dir := t.TempDir()
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			So(os.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+			return path
+		}
+
+		Convey("A JSON file adds its base at rate 1", func() {
+			rates, err := ReadCurrencyRatesFile(write("rates.json", `{"base":"EUR","rates":{"usd":1.25,"GBP":0.8}}`))
+			So(err, ShouldBeNil)
+			So(rates, ShouldResemble, CurrencyRates{"EUR": 1, "USD": 1.25, "GBP": 0.8})
+		})
+
+		Convey("A CSV file may start with a header", func() {
+			rates, err := ReadCurrencyRatesFile(write("rates.csv", "currency,rate\nEUR,1\nUSD, 1.25\n"))
+			So(err, ShouldBeNil)
+			So(rates, ShouldResemble, CurrencyRates{"EUR": 1, "USD": 1.25})
+		})
+
+		Convey("Invalid codes and rates are rejected", func() {
+			_, err := ReadCurrencyRatesFile(write("bad.csv", "EUR,1\nUSD,0\n"))
+			So(err, ShouldNotBeNil)
+			_, err = ReadCurrencyRatesFile(write("bad.json", `{"rates":{"EURO":1}}`))
+			So(err, ShouldNotBeNil)
+			_, err = ReadCurrencyRatesFile(write("rates.txt", "EUR,1"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Amounts convert through the ratio of two rates, rounded to cents", func() {
+			rates := CurrencyRates{"EUR": 1, "USD": 1.25, "GBP": 0.8}
+			amount, ok := rates.Convert(100, "USD", "GBP")
+			So(ok, ShouldBeTrue)
+			So(amount, ShouldEqual, 64)
+			amount, ok = rates.Convert(10, "EUR", "USD")
+			So(amount, ShouldEqual, 12.5)
+			_, ok = rates.Convert(10, "EUR", "JPY")
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
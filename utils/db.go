@@ -4,9 +4,8 @@ import (
     "fmt"
     "strconv"
     "database/sql"
-    "io/ioutil"  
-    "encoding/json"  
     // "backend_rental/services"
+    "backend_rental/migrations"
     "backend_rental/models"
     "github.com/beego/beego/v2/client/orm"
     "github.com/beego/beego/v2/core/config"
@@ -107,7 +106,8 @@ func createDatabaseIfNotExists(config *DBConfig) error {
     return nil
 }
 
-func InitDB() error {
+// ConnectDB creates the database if needed and registers it with the ORM as "default"
+func ConnectDB() error {
     // Get database configuration
     dbConfig, err := getDBConfig()
     if err != nil {
@@ -148,164 +148,49 @@ func InitDB() error {
     orm.RegisterModel(new(models.Location))
     // orm.RegisterModel(new(models.RentalProperty))
     // orm.RegisterModel(new(models.PropertyDetails))
-
-    // Create tables
-    err = orm.RunSyncdb("default", false, true)
-    if err != nil {
-        return fmt.Errorf("failed to sync database: %v", err)
-    }
-    err = loadRentalPropertyData()
-    if err != nil {
-        return fmt.Errorf("failed to load rental property data: %v", err)
-    }
-    // service := &services.PropertyDetailsServiceDB{}
-    err = LoadPropertyDetailsFromJSON()
-    if err != nil {
-        return fmt.Errorf("failed to load property details: %v", err)
-    }
-    fmt.Println("Database initialized successfully")
     return nil
 }
 
-
-func loadRentalPropertyData() error {
-    // Read the RentalProperty.json file
-    data, err := ioutil.ReadFile("data/RentalProperty.json")
-    if err != nil {
-        // If file doesn't exist, it's not an error - just skip loading
-        fmt.Println("RentalProperty.json not found. Skipping data loading.")
-        return nil
-    }
-
-    var properties []models.RentalProperty
-    err = json.Unmarshal(data, &properties)
+func InitDB() error {
+    err := ConnectDB()
     if err != nil {
-        return fmt.Errorf("failed to parse RentalProperty.json: %v", err)
+        return err
     }
 
-    fmt.Printf("Loaded %d properties from JSON\n", len(properties))
-
-    // Get database connection
     db, err := orm.GetDB("default")
     if err != nil {
         return fmt.Errorf("failed to get database connection: %v", err)
     }
 
-    // Start a transaction
-    tx, err := db.Begin()
-    if err != nil {
-        return fmt.Errorf("failed to start transaction: %v", err)
-    }
-
-    // Prepare to defer transaction rollback or commit
-    defer func() {
+    // Schema changes live in the migrations package. With db::auto_migrate = false they
+    // are applied deliberately with `go run ./cmd/migrate up` and boot refuses to run
+    // against an outdated schema.
+    if config.DefaultBool("db::auto_migrate", true) {
+        applied, err := migrations.Up(db)
         if err != nil {
-            tx.Rollback()
-            return
+            return fmt.Errorf("failed to migrate database: %v", err)
         }
-        tx.Commit()
-    }()
-
-    // Clear existing data
-    _, err = tx.Exec("DELETE FROM rental_property")
-    if err != nil {
-        return fmt.Errorf("failed to clear existing data: %v", err)
-    }
-
-    // Prepare insert statement
-    stmt, err := tx.Prepare("INSERT INTO rental_property (city_id, property_id, name, property_type, bedrooms, bathrooms, amenities) VALUES ($1, $2, $3, $4, $5, $6, $7)")
-    if err != nil {
-        return fmt.Errorf("failed to prepare insert statement: %v", err)
-    }
-    defer stmt.Close()
-
-    // Insert new data
-    for _, prop := range properties {
-        _, err = stmt.Exec(prop.CityID, prop.PropertyID, prop.Name, prop.PropertyType, prop.Bedrooms, prop.Bathrooms, prop.Amenities)
+        fmt.Printf("Applied %d migrations\n", applied)
+    } else {
+        pending, err := migrations.Pending(db)
         if err != nil {
-            return fmt.Errorf("failed to insert property %v: %v", prop.PropertyID, err)
+            return fmt.Errorf("failed to check migrations: %v", err)
+        }
+        if len(pending) > 0 {
+            return fmt.Errorf("%d pending migrations, starting with %04d_%s; run `go run ./cmd/migrate up`",
+                len(pending), pending[0].Version, pending[0].Name)
         }
     }
 
-    fmt.Printf("Successfully inserted %d properties\n", len(properties))
-    return nil
-}
-
-func LoadPropertyDetailsFromJSON() error {
-    // Read the PropertyDetails.json file
-    data, err := ioutil.ReadFile("data/PropertyDetails.json")
-    if err != nil {
-        return fmt.Errorf("failed to read PropertyDetails.json: %v", err)
-    }
- 
-    var propertyDetails []models.PropertyDetails
-    err = json.Unmarshal(data, &propertyDetails)
-    if err != nil {
-        return fmt.Errorf("failed to parse PropertyDetails.json: %v", err)
-    }
- 
-    fmt.Printf("Loaded %d property details from JSON\n", len(propertyDetails))
- 
-    // Get database connection
-    db, err := orm.GetDB("default")
-    if err != nil {
-        return fmt.Errorf("failed to get database connection: %v", err)
-    }
- 
-    // Start a transaction
-    tx, err := db.Begin()
-    if err != nil {
-        return fmt.Errorf("failed to start transaction: %v", err)
-    }
- 
-    // Prepare to defer transaction rollback or commit
-    defer func() {
-        if err != nil {
-            tx.Rollback()
-            return
-        }
-        tx.Commit()
-    }()
- 
-    // Clear existing data
-    _, err = tx.Exec("DELETE FROM property_details")
+    // db::seed_mode decides how data/*.json is loaded; the default only fills empty tables
+    seedMode, err := SeedModeFromConfig()
     if err != nil {
-        return fmt.Errorf("failed to clear existing data: %v", err)
+        return err
     }
- 
-    // Prepare insert statement
-    stmt, err := tx.Prepare(`
-        INSERT INTO property_details 
-        (property_id, description, review_score, review_count, review_score_word, image_type, image_urls) 
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
-    `)
+    _, err = SeedDatabase(seedMode)
     if err != nil {
-        return fmt.Errorf("failed to prepare insert statement: %v", err)
-    }
-    defer stmt.Close()
- 
-    // Insert new data
-    for _, detail := range propertyDetails {
-        // Convert ImageUrls to JSON string
-        imageUrlsJSON, err := json.Marshal(detail.ImageUrls)
-        if err != nil {
-            return fmt.Errorf("failed to marshal image URLs: %v", err)
-        }
- 
-        _, err = stmt.Exec(
-            detail.PropertyID, 
-            detail.Description, 
-            detail.ReviewScore, 
-            detail.ReviewCount, 
-            detail.ReviewScoreWord, 
-            detail.ImageType, 
-            string(imageUrlsJSON),
-        )
-        if err != nil {
-            return fmt.Errorf("failed to insert property detail %v: %v", detail.PropertyID, err)
-        }
+        return err
     }
- 
-    fmt.Printf("Successfully inserted %d property details\n", len(propertyDetails))
+    fmt.Println("Database initialized successfully")
     return nil
- }
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"backend_rental/models"
+)
+
+// FileListingsProvider replays previously fetched JSON files (fetched/*.json)
+// so the ingest pipeline can run without network access
+type FileListingsProvider struct {
+	Dir string
+}
+
+func NewFileListingsProvider(dir string) *FileListingsProvider {
+	return &FileListingsProvider{Dir: dir}
+}
+
+func (p *FileListingsProvider) readFixture(name string, out interface{}) error {
+	data, err := os.ReadFile(filepath.Join(p.Dir, name))
+	if err != nil {
+		return fmt.Errorf("error reading fixture %s: %v", name, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("error unmarshaling fixture %s: %v", name, err)
+	}
+	return nil
+}
+
+// FetchCityData returns the recorded cities whose name starts with query
+func (p *FileListingsProvider) FetchCityData(query string) (*models.ApiResponse, error) {
+	var cities []models.Location
+	if err := p.readFixture("cities.json", &cities); err != nil {
+		return nil, err
+	}
+
+	prefix := strings.ToLower(query)
+	response := &models.ApiResponse{Data: []models.CityData{}}
+	for _, city := range cities {
+		if strings.HasPrefix(strings.ToLower(city.CityName), prefix) {
+			response.Data = append(response.Data, models.CityData{
+				CityName: city.CityName,
+				CityID:   city.CityID,
+				Country:  city.Country,
+			})
+		}
+	}
+	return response, nil
+}
+
+// FetchPropertiesForCity returns the recorded properties of the given city on a single page;
+// dates are ignored
+func (p *FileListingsProvider) FetchPropertiesForCity(ctx context.Context, locationId string, checkIn, checkOut string, page int) (*models.PropertyResponse, error) {
+	var properties []models.Property
+	if err := p.readFixture("properties.json", &properties); err != nil {
+		return nil, err
+	}
+
+	response := &models.PropertyResponse{Data: []models.Property{}}
+	response.Meta.TotalPages = 1
+	for _, property := range properties {
+		if page <= 1 && property.CityID == locationId {
+			response.Data = append(response.Data, property)
+		}
+	}
+	return response, nil
+}
+
+// FetchPropertyDetails rebuilds the subset of the stays/detail payload the services read
+func (p *FileListingsProvider) FetchPropertyDetails(ctx context.Context, hotelID int, checkIn, checkOut string) (*models.StayDetailResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var details []models.PropertyDetail
+	if err := p.readFixture("property_details.json", &details); err != nil {
+		return nil, err
+	}
+
+	for _, detail := range details {
+		if detail.HotelID != hotelID {
+			continue
+		}
+
+		facilities := make([]models.StayFacility, 0, len(detail.Amenities))
+		for _, amenity := range detail.Amenities {
+			facilities = append(facilities, models.StayFacility{Name: amenity})
+		}
+		policies := make([]models.StayPolicy, 0, len(detail.Policies))
+		for _, policy := range detail.Policies {
+			policies = append(policies, models.StayPolicy{Phrase: policy})
+		}
+		rooms, blocks := stayRoomsOf(detail.Units)
+
+		// Recorded files predate the page URL; the fake API resolves this slug back to the hotel
+		pageURL := detail.URL
+		if pageURL == "" {
+			pageURL = fmt.Sprintf("https://www.booking.com/hotel/xx/%d.html", detail.HotelID)
+		}
+
+		return &models.StayDetailResponse{
+			Status: true,
+			Data: models.StayDetail{
+				HotelID:               detail.HotelID,
+				HotelName:             detail.HotelName,
+				URL:                   pageURL,
+				AccommodationTypeName: detail.PropertyType,
+				Address:               detail.Address,
+				City:                  detail.City,
+				Zip:                   detail.Zip,
+				CountryCode:           detail.CountryCode,
+				Latitude:              detail.Latitude,
+				Longitude:             detail.Longitude,
+				Class:                 detail.StarRating,
+				BlockCount:            len(blocks),
+				Checkin:               models.StayTimeWindow{From: detail.CheckInFrom, Until: detail.CheckInUntil},
+				Checkout:              models.StayTimeWindow{From: detail.CheckOutFrom, Until: detail.CheckOutUntil},
+				Facilities:            facilities,
+				ImportantInformation:  policies,
+				Rooms:                 rooms,
+				Block:                 blocks,
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded details for hotel %d", hotelID)
+}
+
+// stayRoomsOf turns recorded units back into the rooms and block lists of stays/detail.
+// Files recorded before units were parsed have none, so their properties get no units.
+func stayRoomsOf(units []models.PropertyUnit) (map[string]models.StayRoom, []models.StayBlock) {
+	rooms := map[string]models.StayRoom{}
+	blocks := make([]models.StayBlock, 0, len(units))
+	for _, unit := range units {
+		room := models.StayRoom{BedroomCount: unit.Bedrooms, BathroomCount: unit.Bathrooms}
+		if unit.BedConfiguration != "" {
+			for _, configuration := range strings.Split(unit.BedConfiguration, " or ") {
+				room.BedConfigurations = append(room.BedConfigurations, models.StayBedConfiguration{
+					BedTypes: []models.StayBedType{{NameWithCount: configuration}},
+				})
+			}
+		}
+		switch unit.BathroomType {
+		case models.BathroomPrivate:
+			room.PrivateBathroomHighlight = models.StayHighlight{HasHighlight: 1, Text: "Private bathroom"}
+		case models.BathroomShared:
+			room.Facilities = []models.StayRoomFacility{{Name: "Shared bathroom"}}
+		}
+		rooms[strconv.FormatInt(unit.RoomID, 10)] = room
+		blocks = append(blocks, models.StayBlock{
+			RoomID:          unit.RoomID,
+			RoomName:        unit.Name,
+			MaxOccupancy:    unit.MaxOccupancy,
+			RoomSurfaceInM2: unit.SizeM2,
+		})
+	}
+	return rooms, blocks
+}
+
+// FetchPropertyDescription wraps the recorded description as the main (type 6) description
+func (p *FileListingsProvider) FetchPropertyDescription(ctx context.Context, hotelID string) (*PropertyDescriptionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var descriptions []models.PropertyDescription
+	if err := p.readFixture("property_desc_image.json", &descriptions); err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(hotelID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hotel id %q: %v", hotelID, err)
+	}
+
+	response := &PropertyDescriptionResponse{Data: []PropertyDescription{}}
+	for _, desc := range descriptions {
+		if desc.PropertyID == id {
+			response.Data = append(response.Data, PropertyDescription{
+				Description:       desc.Description,
+				DescriptionTypeID: 6,
+				LanguageCode:      "en-gb",
+			})
+		}
+	}
+	return response, nil
+}
+
+// FetchPropertyPhotos rebuilds the hotelPhotos/allRoomPhotos lists from the recorded photos,
+// or from the image URLs of files recorded before photo metadata was kept
+func (p *FileListingsProvider) FetchPropertyPhotos(ctx context.Context, hotelID int, checkIn, checkOut string) (*models.PropertyImageResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var images []models.PropertyImage
+	if err := p.readFixture("property_images.json", &images); err != nil {
+		return nil, err
+	}
+
+	photoKeys := map[string]string{
+		"hotel_photos": "hotelPhotos",
+		"room_photos":  "allRoomPhotos",
+	}
+
+	data := map[string]interface{}{}
+	for _, image := range images {
+		key, ok := photoKeys[image.ImageType]
+		if image.PropertyID != hotelID || !ok {
+			continue
+		}
+		photos, _ := data[key].([]interface{})
+		for _, photo := range image.Photos {
+			photos = append(photos, map[string]interface{}{
+				"id":        float64(photo.PhotoID),
+				"caption":   photo.Caption,
+				"large_url": photo.LargeURL,
+				"thumb_url": photo.ThumbURL,
+				"room_id":   float64(photo.RoomID),
+				"room_name": photo.RoomName,
+			})
+		}
+		if len(image.Photos) == 0 {
+			for _, imageURL := range image.ImageURLs {
+				photos = append(photos, map[string]interface{}{"thumb_url": imageURL})
+			}
+		}
+		data[key] = photos
+	}
+	return &models.PropertyImageResponse{Data: data}, nil
+}
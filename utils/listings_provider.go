@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"backend_rental/models"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// ListingsProvider is the source of city, property and enrichment data used by the ingest services
+type ListingsProvider interface {
+	FetchCityData(query string) (*models.ApiResponse, error)
+	// FetchPropertiesForCity returns one page of the search, counted from 1
+	FetchPropertiesForCity(ctx context.Context, locationId string, checkIn, checkOut string, page int) (*models.PropertyResponse, error)
+	FetchPropertyDetails(ctx context.Context, hotelID int, checkIn, checkOut string) (*models.StayDetailResponse, error)
+	FetchPropertyDescription(ctx context.Context, hotelID string) (*PropertyDescriptionResponse, error)
+	FetchPropertyPhotos(ctx context.Context, hotelID int, checkIn, checkOut string) (*models.PropertyImageResponse, error)
+}
+
+// Provider names accepted by the listings_provider setting
+const (
+	ProviderRapidAPI = "rapidapi"
+	ProviderFile     = "file"
+)
+
+// ListingsProviderName returns the provider selected by listings_provider in app.conf,
+// defaulting to the RapidAPI Booking adapter
+func ListingsProviderName() string {
+	name := strings.ToLower(strings.TrimSpace(beego.AppConfig.DefaultString("listings_provider", ProviderRapidAPI)))
+
+	switch name {
+	case ProviderFile, ProviderRapidAPI:
+		return name
+	default:
+		fmt.Printf("Warning: unknown listings_provider %q, falling back to %s\n", name, ProviderRapidAPI)
+		return ProviderRapidAPI
+	}
+}
+
+// NewListingsProvider returns the provider named by ListingsProviderName
+func NewListingsProvider() ListingsProvider {
+	if ListingsProviderName() == ProviderFile {
+		dir := beego.AppConfig.DefaultString("listings_fixture_dir", "fetched")
+		fmt.Printf("Using file-backed listings provider (%s)\n", dir)
+		return NewFileListingsProvider(dir)
+	}
+	return NewApiClient()
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+
+	"backend_rental/models"
+)
+
+// sqlExecer is a *sql.DB or *sql.Tx
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// SavePropertyPrices appends prices to property_price and returns how many were new; a
+// price already stored for the same property, check-in date and fetch time is kept as is
+func SavePropertyPrices(db sqlExecer, prices []models.PropertyPrice) (int, error) {
+	inserted := 0
+	for _, price := range prices {
+		result, err := db.Exec(`
+			INSERT INTO property_price
+				(property_id, check_in, check_out, fetched_at, currency, gross, strikethrough, taxes)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (property_id, check_in, fetched_at) DO NOTHING`,
+			price.PropertyID, price.CheckIn, price.CheckOut, price.FetchedAt,
+			price.Currency, price.Gross, price.Strikethrough, price.Taxes)
+		if err != nil {
+			return inserted, fmt.Errorf("failed to insert price of property %d: %v", price.PropertyID, err)
+		}
+		n, _ := result.RowsAffected()
+		inserted += int(n)
+	}
+	return inserted, nil
+}
+
+// QuotedPrices returns the prices carried by search results
+func QuotedPrices(properties []models.Property) []models.PropertyPrice {
+	var prices []models.PropertyPrice
+	for _, property := range properties {
+		if price := property.Quote(); price != nil {
+			prices = append(prices, *price)
+		}
+	}
+	return prices
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"backend_rental/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestQuotedPrices checks that the nested priceBreakdown of stays/search becomes price rows
+func TestQuotedPrices(t *testing.T) {
+	Convey("Subject: prices quoted by stays/search\n", t, func() {
+		var response models.PropertyResponse
+		err := json.Unmarshal([]byte(`{"data":[
+			{"id":26263,"name":"Canal House","checkin":{"fromTime":"15:00"},"priceBreakdown":{
+				"grossPrice":{"currency":"EUR","value":212.5,"amountRounded":"€213"},
+				"strikethroughPrice":{"currency":"EUR","value":250},
+				"excludedPrice":{"currency":"EUR","value":26.35}}},
+			{"id":3226748,"name":"Bunk Hotel","priceBreakdown":{"grossPrice":{"currency":"EUR","value":89}}},
+			{"id":9367966,"name":"Sold out"}]}`), &response)
+		So(err, ShouldBeNil)
+
+		quotedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		for i := range response.Data {
+			response.Data[i].QuotedCheckIn = "2026-11-17"
+			response.Data[i].QuotedCheckOut = "2026-11-18"
+			response.Data[i].QuotedAt = &quotedAt
+		}
+		prices := QuotedPrices(response.Data)
+
+		Convey("Gross, strikethrough, currency and taxes are read from the nested objects", func() {
+			So(len(prices), ShouldEqual, 2)
+			So(prices[0].PropertyID, ShouldEqual, 26263)
+			So(prices[0].Currency, ShouldEqual, "EUR")
+			So(prices[0].Gross, ShouldEqual, 212.5)
+			So(*prices[0].Strikethrough, ShouldEqual, 250)
+			So(prices[0].Taxes, ShouldEqual, 26.35)
+			So(prices[0].CheckIn, ShouldEqual, "2026-11-17")
+			So(prices[0].FetchedAt, ShouldEqual, quotedAt)
+		})
+
+		Convey("A stay without a discount has no strikethrough price", func() {
+			So(prices[1].Strikethrough, ShouldBeNil)
+			So(prices[1].Taxes, ShouldEqual, 0)
+		})
+
+		Convey("Results without a quote date carry no price", func() {
+			response.Data[0].QuotedAt = nil
+			So(len(QuotedPrices(response.Data)), ShouldEqual, 1)
+		})
+	})
+}
@@ -1,46 +1,37 @@
 package utils
 
 import (
-    "encoding/json"
+    "context"
     "fmt"
-    "io/ioutil"
-    "net/http"
-    "time"
-    // "backend_rental/models"
+    "net/url"
+    "strconv"
+    "backend_rental/models"
 )
 
-func (c *ApiClient) FetchPropertyDetails(hotelID int, checkIn, checkOut string) (*map[string]interface{}, error) {
-    url := fmt.Sprintf(
-        "https://booking-com18.p.rapidapi.com/stays/detail?hotelId=%d&checkinDate=%s&checkoutDate=%s&units=metric", 
-        hotelID, 
-        checkIn, 
-        checkOut,
-    )
-    
-    req, _ := http.NewRequest("GET", url, nil)
-
-    // Set headers
-    for key, value := range c.Headers {
-        req.Header.Set(key, value)
-    }
-
-    // Send request
-    client := &http.Client{
-        Timeout: 30 * time.Second,
-    }
-    resp, err := client.Do(req)
+// FetchPropertyDetails requests stays/detail for hotelID. The response records how the
+// payload drifted from models.StayDetailResponse.
+func (c *ApiClient) FetchPropertyDetails(ctx context.Context, hotelID int, checkIn, checkOut string) (*models.StayDetailResponse, error) {
+    req, err := c.newRequest(staysDetailPath, url.Values{
+        "hotelId":      {strconv.Itoa(hotelID)},
+        "checkinDate":  {checkIn},
+        "checkoutDate": {checkOut},
+        "units":        {"metric"},
+    })
     if err != nil {
         return nil, err
     }
-    defer resp.Body.Close()
 
-    // Parse response
-    body, _ := ioutil.ReadAll(resp.Body)
-    var propertyDetails map[string]interface{}
-    err = json.Unmarshal(body, &propertyDetails)
+    var response models.StayDetailResponse
+    err = c.fetch(req.WithContext(ctx), func(body []byte) error {
+        var err error
+        if response.Drift, err = DecodeChecked(body, &response); err != nil {
+            return fmt.Errorf("error parsing details of property %d: %v", hotelID, err)
+        }
+        return nil
+    })
     if err != nil {
         return nil, err
     }
 
-    return &propertyDetails, nil
+    return &response, nil
 }
@@ -1,46 +1,37 @@
 package utils
 
 import (
+    "context"
     "encoding/json"
-    "fmt"
-    "io/ioutil"
-    "net/http"
-    "time"
+    "net/url"
+    "strconv"
     "backend_rental/models"
 )
 
-func (c *ApiClient) FetchPropertiesForCity(locationId string, checkIn, checkOut string) (*models.PropertyResponse, error) {
-    url := fmt.Sprintf(
-        "https://booking-com18.p.rapidapi.com/stays/search?locationId=%s&checkinDate=%s&checkoutDate=%s&units=metric&temperature=c", 
-        locationId, 
-        checkIn, 
-        checkOut,
-    )
-    
-    req, _ := http.NewRequest("GET", url, nil)
-
-    // Set headers
-    for key, value := range c.Headers {
-        req.Header.Set(key, value)
+func (c *ApiClient) FetchPropertiesForCity(ctx context.Context, locationId string, checkIn, checkOut string, page int) (*models.PropertyResponse, error) {
+    params := url.Values{
+        "locationId":   {locationId},
+        "checkinDate":  {checkIn},
+        "checkoutDate": {checkOut},
+        "units":        {"metric"},
+        "temperature":  {"c"},
     }
-
-    // Send request
-    client := &http.Client{
-        Timeout: 30 * time.Second,
+    if page > 1 {
+        params.Set("page", strconv.Itoa(page))
     }
-    resp, err := client.Do(req)
+    req, err := c.newRequest(staysSearchPath, params)
     if err != nil {
         return nil, err
     }
-    defer resp.Body.Close()
 
     // Parse response
-    body, _ := ioutil.ReadAll(resp.Body)
     var propertyResponse models.PropertyResponse
-    err = json.Unmarshal(body, &propertyResponse)
+    err = c.fetch(req.WithContext(ctx), func(body []byte) error {
+        return json.Unmarshal(body, &propertyResponse)
+    })
     if err != nil {
         return nil, err
     }
 
     return &propertyResponse, nil
-}
\ No newline at end of file
+}
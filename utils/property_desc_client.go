@@ -1,11 +1,10 @@
 package utils
 
 import (
+    "context"
     "encoding/json"
     "fmt"
-    "io/ioutil"
-    "net/http"
-    "time"
+    "net/url"
 )
 
 type PropertyDescriptionResponse struct {
@@ -20,37 +19,24 @@ type PropertyDescription struct {
     LanguageCode    string `json:"languagecode"`
 }
 
-func (c *ApiClient) FetchPropertyDescription(hotelID string) (*PropertyDescriptionResponse, error) {
-    url := fmt.Sprintf(
-        "https://booking-com18.p.rapidapi.com/stays/get-description?hotelId=%s", 
-        hotelID,
-    )
-    
-    req, _ := http.NewRequest("GET", url, nil)
-
-    // Set headers
-    for key, value := range c.Headers {
-        req.Header.Set(key, value)
-    }
-
-    // Send request
-    client := &http.Client{
-        Timeout: 30 * time.Second,
-    }
-    resp, err := client.Do(req)
+func (c *ApiClient) FetchPropertyDescription(ctx context.Context, hotelID string) (*PropertyDescriptionResponse, error) {
+    req, err := c.newRequest(descriptionPath, url.Values{"hotelId": {hotelID}})
     if err != nil {
         return nil, err
     }
-    defer resp.Body.Close()
 
     // Parse response
-    body, _ := ioutil.ReadAll(resp.Body)
     var descResponse PropertyDescriptionResponse
-    err = json.Unmarshal(body, &descResponse)
+    err = c.fetch(req.WithContext(ctx), func(body []byte) error {
+        if err := json.Unmarshal(body, &descResponse); err != nil {
+            // Print raw response for debugging
+            fmt.Printf("Raw response: %s\n", string(body))
+            return fmt.Errorf("error unmarshaling response: %v", err)
+        }
+        return nil
+    })
     if err != nil {
-        // Print raw response for debugging
-        fmt.Printf("Raw response: %s\n", string(body))
-        return nil, fmt.Errorf("error unmarshaling response: %v", err)
+        return nil, err
     }
 
     return &descResponse, nil
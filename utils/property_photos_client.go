@@ -0,0 +1,111 @@
+package utils
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strings"
+    "backend_rental/models"
+)
+
+// StayPageURLSetter is implemented by providers that can reuse Booking page URLs recorded
+// by the details stage instead of looking each one up before fetching photos
+type StayPageURLSetter interface {
+    SetStayPageURLs(urls map[int]string)
+    // HasStayPageURL reports whether photos of hotelID can be fetched without looking the
+    // page up in stays/detail first
+    HasStayPageURL(hotelID int) bool
+}
+
+// StaySlug extracts the slug the web stay endpoint takes, e.g. "us/mayfair-new-york",
+// from a Booking page URL such as https://www.booking.com/hotel/us/mayfair-new-york.en-gb.html
+func StaySlug(pageURL string) (string, error) {
+    u, err := url.Parse(pageURL)
+    if err != nil {
+        return "", fmt.Errorf("invalid stay URL %q: %v", pageURL, err)
+    }
+
+    path := strings.Trim(u.Path, "/")
+    path = strings.TrimPrefix(path, "hotel/")
+    path = strings.TrimSuffix(path, ".html")
+    parts := strings.Split(path, "/")
+    if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+        return "", fmt.Errorf("no stay slug in URL %q", pageURL)
+    }
+    // Drop a language suffix such as ".en-gb"
+    name := parts[1]
+    if i := strings.Index(name, "."); i > 0 {
+        name = name[:i]
+    }
+    return parts[0] + "/" + name, nil
+}
+
+// SetStayPageURLs records Booking page URLs by hotel ID
+func (c *ApiClient) SetStayPageURLs(urls map[int]string) {
+    for hotelID, pageURL := range urls {
+        if pageURL != "" {
+            c.stayURLs.Store(hotelID, pageURL)
+        }
+    }
+}
+
+// HasStayPageURL reports whether the page URL of hotelID is recorded
+func (c *ApiClient) HasStayPageURL(hotelID int) bool {
+    _, ok := c.stayURLs.Load(hotelID)
+    return ok
+}
+
+// stayPageURL returns the Booking page URL of hotelID, reading it from stays/detail when it
+// was not recorded
+func (c *ApiClient) stayPageURL(ctx context.Context, hotelID int, checkIn, checkOut string) (string, error) {
+    if pageURL, ok := c.stayURLs.Load(hotelID); ok {
+        return pageURL.(string), nil
+    }
+
+    details, err := c.FetchPropertyDetails(ctx, hotelID, checkIn, checkOut)
+    if err != nil {
+        return "", fmt.Errorf("error looking up the page of property %d: %v", hotelID, err)
+    }
+    pageURL := details.Data.URL
+    if pageURL == "" {
+        return "", fmt.Errorf("details of property %d carry no page URL", hotelID)
+    }
+    c.stayURLs.Store(hotelID, pageURL)
+    return pageURL, nil
+}
+
+// FetchPropertyPhotos requests the web stay details of hotelID, which carry the hotel and
+// room photo lists. The endpoint is keyed on the page slug, so the slug is resolved first.
+func (c *ApiClient) FetchPropertyPhotos(ctx context.Context, hotelID int, checkIn, checkOut string) (*models.PropertyImageResponse, error) {
+    pageURL, err := c.stayPageURL(ctx, hotelID, checkIn, checkOut)
+    if err != nil {
+        return nil, err
+    }
+    slug, err := StaySlug(pageURL)
+    if err != nil {
+        return nil, fmt.Errorf("error resolving slug of property %d: %v", hotelID, err)
+    }
+
+    req, err := c.newRequest(webStayDetailsPath, url.Values{
+        "id":       {slug},
+        "checkIn":  {checkIn},
+        "checkOut": {checkOut},
+    })
+    if err != nil {
+        return nil, fmt.Errorf("error creating request for property %d: %v", hotelID, err)
+    }
+
+    var imageResponse models.PropertyImageResponse
+    err = c.fetch(req.WithContext(ctx), func(body []byte) error {
+        if err := json.Unmarshal(body, &imageResponse); err != nil {
+            return fmt.Errorf("error unmarshaling response: %v (body: %s)", err, string(body))
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return &imageResponse, nil
+}
@@ -0,0 +1,376 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	beego "github.com/beego/beego/v2/server/web"
+	"golang.org/x/time/rate"
+)
+
+// ProviderUsagePath keeps the call counts and last quota headers of every provider, so a
+// restarted server or CLI run picks up where the previous one left the quota
+const ProviderUsagePath = "data/provider_usage.json"
+
+// headerRequestsLimit is the RapidAPI plan quota that x-ratelimit-requests-remaining counts down
+const headerRequestsLimit = "x-ratelimit-requests-limit"
+
+// usageSaveDelay batches the usage file writes of the responses arriving close together
+const usageSaveDelay = 2 * time.Second
+
+// usageRetention is how many days of call counts the usage file keeps
+const usageRetention = 62
+
+const usageDayLayout = "2006-01-02"
+
+// providerRateLimits are the base limits of each listings provider; recorded files need none
+var providerRateLimits = map[string]RateLimiterConfig{
+	ProviderRapidAPI: LenientRateLimiter,
+	ProviderFile:     {Limit: rate.Inf, BurstSize: 1},
+}
+
+// ProviderUsage is the persisted call accounting of one provider
+type ProviderUsage struct {
+	// Days counts the responses received per UTC day
+	Days map[string]int `json:"days"`
+	// Limit, Remaining and ResetAt are the quota headers of the last response that had them
+	Limit     int       `json:"limit,omitempty"`
+	Remaining *int      `json:"remaining,omitempty"`
+	ResetAt   time.Time `json:"reset_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Today returns the calls made on the UTC day of now
+func (u ProviderUsage) Today(now time.Time) int {
+	return u.Days[now.UTC().Format(usageDayLayout)]
+}
+
+// Month returns the calls made in the UTC calendar month of now
+func (u ProviderUsage) Month(now time.Time) int {
+	prefix := now.UTC().Format("2006-01")
+	total := 0
+	for day, calls := range u.Days {
+		if strings.HasPrefix(day, prefix) {
+			total += calls
+		}
+	}
+	return total
+}
+
+// AdaptiveLimiter paces the calls to one provider. It starts at the provider's base rate and
+// adjusts to the quota headers of every response: a quota that would run out before it resets
+// is spread evenly over what is left of its window, hourly or monthly, and a used up quota
+// pauses all callers until it resets.
+type AdaptiveLimiter struct {
+	Provider string
+	// DailyQuota and MonthlyQuota cap the calls counted in the usage file; 0 means no cap
+	DailyQuota   int
+	MonthlyQuota int
+	// MaxPause is the longest quota reset Wait sleeps through; longer pauses fail straight
+	// away with ErrQuotaExhausted
+	MaxPause time.Duration
+	// UsagePath persists the usage shortly after each response, or on Flush; empty keeps it
+	// in memory
+	UsagePath string
+
+	mu      sync.Mutex
+	base    RateLimiterConfig
+	limiter *rate.Limiter
+	usage   ProviderUsage
+	now     func() time.Time
+	// unsaved is set by Observe until the usage is written again
+	unsaved bool
+
+	// saveMu keeps the writes of one limiter in order
+	saveMu sync.Mutex
+}
+
+// NewAdaptiveLimiter returns a limiter running at base until responses say otherwise
+func NewAdaptiveLimiter(provider string, base RateLimiterConfig) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		Provider: provider,
+		base:     base,
+		limiter:  NewRateLimiter(base.Limit, base.BurstSize),
+		usage:    ProviderUsage{Days: map[string]int{}},
+		now:      time.Now,
+	}
+}
+
+// Wait blocks until the next call may go out. It returns an error wrapping ErrQuotaExhausted
+// without waiting when a configured quota is used up or the provider quota resets later
+// than MaxPause.
+func (l *AdaptiveLimiter) Wait(ctx context.Context) error {
+	pause, err := l.quotaPause()
+	if err != nil {
+		return err
+	}
+	if pause > 0 {
+		fmt.Printf("Provider %s quota used up; pausing %v until it resets\n", l.Provider, pause.Round(time.Second))
+		if err := SleepContext(ctx, pause); err != nil {
+			return err
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// quotaPause returns how long callers must wait for the provider quota to reset
+func (l *AdaptiveLimiter) quotaPause() (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.DailyQuota > 0 && l.usage.Today(now) >= l.DailyQuota {
+		return 0, fmt.Errorf("%w: %d %s calls today reach ingest::daily_quota", ErrQuotaExhausted, l.usage.Today(now), l.Provider)
+	}
+	if l.MonthlyQuota > 0 && l.usage.Month(now) >= l.MonthlyQuota {
+		return 0, fmt.Errorf("%w: %d %s calls this month reach ingest::monthly_quota", ErrQuotaExhausted, l.usage.Month(now), l.Provider)
+	}
+
+	if l.usage.Remaining == nil || *l.usage.Remaining > 0 {
+		return 0, nil
+	}
+	pause := l.usage.ResetAt.Sub(now)
+	if pause <= 0 {
+		// The quota has reset since; the next response reports the new count
+		l.usage.Remaining = nil
+		return 0, nil
+	}
+	if pause > l.MaxPause {
+		return 0, fmt.Errorf("%w: %s quota resets in %v", ErrQuotaExhausted, l.Provider, pause.Round(time.Second))
+	}
+	return pause, nil
+}
+
+// Observe counts a response against the provider's usage and adapts the rate to its quota
+// headers
+func (l *AdaptiveLimiter) Observe(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.usage.Days[now.UTC().Format(usageDayLayout)]++
+	l.usage.UpdatedAt = now
+	if remaining, ok := headerInt(resp.Header, headerRequestsRemaining); ok {
+		l.usage.Remaining = &remaining
+		l.usage.ResetAt = time.Time{}
+		if reset := parseSeconds(resp.Header.Get(headerRequestsReset)); reset > 0 {
+			l.usage.ResetAt = now.Add(reset)
+		}
+		if limit, ok := headerInt(resp.Header, headerRequestsLimit); ok {
+			l.usage.Limit = limit
+		}
+	}
+
+	l.adapt(now)
+	pruneUsage(l.usage.Days, now)
+	if l.UsagePath != "" && !l.unsaved {
+		l.unsaved = true
+		time.AfterFunc(usageSaveDelay, l.Flush)
+	}
+}
+
+// Flush writes the usage to UsagePath if a response arrived since the last write. The usage
+// is copied under the lock and written outside it, so callers never wait on the disk.
+func (l *AdaptiveLimiter) Flush() {
+	l.saveMu.Lock()
+	defer l.saveMu.Unlock()
+
+	l.mu.Lock()
+	unsaved := l.unsaved
+	l.unsaved = false
+	l.mu.Unlock()
+	if !unsaved || l.UsagePath == "" {
+		return
+	}
+	if err := saveProviderUsage(l.UsagePath, l.Provider, l.Usage()); err != nil {
+		fmt.Printf("Error saving provider usage: %v\n", err)
+	}
+}
+
+// adapt sets the limiter to the base rate, or slower when the remaining quota would not last
+// until its reset at the base rate
+func (l *AdaptiveLimiter) adapt(now time.Time) {
+	limit, burst := l.base.Limit, l.base.BurstSize
+	if l.usage.Remaining != nil && *l.usage.Remaining > 0 {
+		remaining := *l.usage.Remaining
+		window := l.usage.ResetAt.Sub(now)
+		if window > 0 {
+			if pace := rate.Limit(float64(remaining) / window.Seconds()); pace < limit {
+				limit = pace
+			}
+		}
+		if remaining < burst {
+			burst = remaining
+		}
+	}
+
+	if limit != l.limiter.Limit() {
+		if limit < l.base.Limit {
+			fmt.Printf("Provider %s has %d requests left for %v; slowing to %.1f requests per minute\n",
+				l.Provider, *l.usage.Remaining, l.usage.ResetAt.Sub(now).Round(time.Second), float64(limit)*60)
+		} else {
+			fmt.Printf("Provider %s back at its base rate\n", l.Provider)
+		}
+		l.limiter.SetLimitAt(now, limit)
+	}
+	if burst != l.limiter.Burst() {
+		l.limiter.SetBurstAt(now, burst)
+	}
+}
+
+// Usage returns a copy of the provider's usage
+func (l *AdaptiveLimiter) Usage() ProviderUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	usage := l.usage
+	usage.Days = make(map[string]int, len(l.usage.Days))
+	for day, calls := range l.usage.Days {
+		usage.Days[day] = calls
+	}
+	if l.usage.Remaining != nil {
+		remaining := *l.usage.Remaining
+		usage.Remaining = &remaining
+	}
+	return usage
+}
+
+// restore takes over usage loaded from the usage file and adapts the rate to it
+func (l *AdaptiveLimiter) restore(usage ProviderUsage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if usage.Days == nil {
+		usage.Days = map[string]int{}
+	}
+	l.usage = usage
+	l.adapt(l.now())
+}
+
+func headerInt(header http.Header, key string) (int, bool) {
+	value, err := strconv.Atoi(strings.TrimSpace(header.Get(key)))
+	if err != nil || value < 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+// pruneUsage drops the call counts older than usageRetention days
+func pruneUsage(days map[string]int, now time.Time) {
+	oldest := now.UTC().AddDate(0, 0, -usageRetention).Format(usageDayLayout)
+	for day := range days {
+		if day < oldest {
+			delete(days, day)
+		}
+	}
+}
+
+// usageFileMu serializes the read-modify-write of the usage file shared by all providers
+var usageFileMu sync.Mutex
+
+// LoadProviderUsage reads the usage of every provider from path; a missing file is empty
+func LoadProviderUsage(path string) (map[string]ProviderUsage, error) {
+	usage := map[string]ProviderUsage{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading provider usage: %v", err)
+	}
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, fmt.Errorf("error parsing provider usage: %v", err)
+	}
+	return usage, nil
+}
+
+func saveProviderUsage(path, provider string, usage ProviderUsage) error {
+	usageFileMu.Lock()
+	defer usageFileMu.Unlock()
+
+	all, err := LoadProviderUsage(path)
+	if err != nil {
+		return err
+	}
+	all[provider] = usage
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding provider usage: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating usage directory: %v", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ProviderNames returns the providers with a rate limit preset, sorted
+func ProviderNames() []string {
+	names := make([]string, 0, len(providerRateLimits))
+	for name := range providerRateLimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	providerLimitersMu sync.Mutex
+	providerLimiters   = map[string]*AdaptiveLimiter{}
+)
+
+// ProviderLimiter returns the limiter shared by every service calling provider, so
+// concurrent stages and workers together stay within the provider's limit. The first call
+// restores the provider's usage from ProviderUsagePath and reads the ingest quotas.
+func ProviderLimiter(provider string) *AdaptiveLimiter {
+	providerLimitersMu.Lock()
+	defer providerLimitersMu.Unlock()
+
+	if limiter, ok := providerLimiters[provider]; ok {
+		return limiter
+	}
+	config, ok := providerRateLimits[provider]
+	if !ok {
+		config = LenientRateLimiter
+	}
+
+	limiter := NewAdaptiveLimiter(provider, config)
+	limiter.DailyQuota = beego.AppConfig.DefaultInt("ingest::daily_quota", 0)
+	limiter.MonthlyQuota = beego.AppConfig.DefaultInt("ingest::monthly_quota", 0)
+	limiter.MaxPause = time.Duration(beego.AppConfig.DefaultInt("ingest::max_quota_pause", 120)) * time.Second
+	limiter.UsagePath = ProviderUsagePath
+
+	usage, err := LoadProviderUsage(ProviderUsagePath)
+	if err != nil {
+		fmt.Printf("Warning: %v; starting %s usage from zero\n", err, provider)
+	} else if stored, ok := usage[provider]; ok {
+		limiter.restore(stored)
+	}
+
+	providerLimiters[provider] = limiter
+	return limiter
+}
+
+// FlushProviderUsage writes the usage every provider limiter has not saved yet; commands call
+// it before they exit
+func FlushProviderUsage() {
+	providerLimitersMu.Lock()
+	limiters := make([]*AdaptiveLimiter, 0, len(providerLimiters))
+	for _, limiter := range providerLimiters {
+		limiters = append(limiters, limiter)
+	}
+	providerLimitersMu.Unlock()
+
+	for _, limiter := range limiters {
+		limiter.Flush()
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/time/rate"
+)
+
+// TestAdaptiveLimiter checks that quota headers slow down or pause the limiter and that
+// usage survives a restart
+func TestAdaptiveLimiter(t *testing.T) {
+	Convey("Subject: adaptive provider limiter\n", t, func() {
+		now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		usagePath := filepath.Join(t.TempDir(), "provider_usage.json")
+
+		newLimiter := func() *AdaptiveLimiter {
+			limiter := NewAdaptiveLimiter(ProviderRapidAPI, LenientRateLimiter)
+			limiter.UsagePath = usagePath
+			limiter.MaxPause = time.Minute
+			limiter.now = func() time.Time { return now }
+			return limiter
+		}
+		response := func(remaining, reset string) *http.Response {
+			header := http.Header{}
+			if remaining != "" {
+				header.Set(headerRequestsRemaining, remaining)
+				header.Set(headerRequestsReset, reset)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: header}
+		}
+		limiter := newLimiter()
+		// Leaves the pending save nothing to write once the temp dir is gone
+		Reset(limiter.Flush)
+
+		Convey("Plenty of quota keeps the base rate", func() {
+			limiter.Observe(response("900", "3600"))
+			So(limiter.limiter.Limit(), ShouldEqual, LenientRateLimiter.Limit)
+		})
+
+		Convey("A short window with few requests left slows the rate down", func() {
+			limiter.Observe(response("3", "60"))
+			So(limiter.limiter.Limit(), ShouldAlmostEqual, rate.Limit(3.0/60), 0.0001)
+			So(limiter.limiter.Burst(), ShouldEqual, 3)
+		})
+
+		Convey("A monthly quota that would run out before its reset slows the rate down", func() {
+			limiter.Observe(response("100", "2592000"))
+			So(limiter.limiter.Limit(), ShouldAlmostEqual, rate.Limit(100.0/2592000), 0.0000001)
+		})
+
+		Convey("A used up quota with a distant reset refuses calls", func() {
+			limiter.Observe(response("0", "86400"))
+			err := limiter.Wait(context.Background())
+			So(errors.Is(err, ErrQuotaExhausted), ShouldBeTrue)
+		})
+
+		Convey("A used up quota resumes once the reset has passed", func() {
+			limiter.Observe(response("0", "30"))
+			now = now.Add(31 * time.Second)
+			So(limiter.Wait(context.Background()), ShouldBeNil)
+		})
+
+		Convey("The daily quota counts responses across restarts", func() {
+			limiter.Observe(response("", ""))
+			limiter.Observe(response("", ""))
+			limiter.Flush()
+
+			usage, err := LoadProviderUsage(usagePath)
+			So(err, ShouldBeNil)
+
+			restarted := newLimiter()
+			restarted.DailyQuota = 2
+			restarted.restore(usage[ProviderRapidAPI])
+			So(restarted.Usage().Today(now), ShouldEqual, 2)
+
+			err = restarted.Wait(context.Background())
+			So(errors.Is(err, ErrQuotaExhausted), ShouldBeTrue)
+
+			now = now.Add(24 * time.Hour)
+			So(restarted.Wait(context.Background()), ShouldBeNil)
+		})
+	})
+}
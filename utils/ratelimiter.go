@@ -19,6 +19,19 @@ func WaitForRateLimit(ctx context.Context, limiter *rate.Limiter) error {
     return limiter.Wait(ctx)
 }
 
+// SleepContext pauses for d, returning early with ctx.Err() if ctx is cancelled
+func SleepContext(ctx context.Context, d time.Duration) error {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-timer.C:
+        return nil
+    }
+}
+
 // Predefined configurations
 var (
     LenientRateLimiter = RateLimiterConfig{
@@ -31,6 +44,7 @@ var (
         BurstSize: 5,
     }
 )
+
 // package utils
 
 // import (
@@ -0,0 +1,368 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// DefaultResponseCacheDir holds the cached provider responses when cache::dir is not set
+const DefaultResponseCacheDir = "data/cache"
+
+// CacheMode is how a provider uses the response cache
+type CacheMode int
+
+const (
+	// CacheUse serves fresh cached responses and stores the others
+	CacheUse CacheMode = iota
+	// CacheRefresh always calls the provider and stores the responses
+	CacheRefresh
+	// CacheOff neither reads nor writes the cache
+	CacheOff
+)
+
+// CacheModeSetter is implemented by providers with a response cache
+type CacheModeSetter interface {
+	SetCacheMode(mode CacheMode)
+}
+
+// SetCacheMode applies mode to provider when it caches responses
+func SetCacheMode(provider ListingsProvider, mode CacheMode) {
+	if setter, ok := provider.(CacheModeSetter); ok {
+		setter.SetCacheMode(mode)
+	}
+}
+
+// defaultCacheTTLs is how long the response of each endpoint stays fresh. Endpoints missing
+// here are not cached; searches are missing because their prices are stored as quotes taken
+// when the search ran, which a cached response would misdate.
+var defaultCacheTTLs = map[string]time.Duration{
+	autoCompletePath:   30 * 24 * time.Hour,
+	staysDetailPath:    7 * 24 * time.Hour,
+	descriptionPath:    30 * 24 * time.Hour,
+	webStayDetailsPath: 7 * 24 * time.Hour,
+}
+
+// cacheTTLKeys are the cache:: settings overriding defaultCacheTTLs
+var cacheTTLKeys = map[string]string{
+	autoCompletePath:   "ttl_auto_complete",
+	staysDetailPath:    "ttl_detail",
+	descriptionPath:    "ttl_description",
+	webStayDetailsPath: "ttl_photos",
+}
+
+// cacheKeyParams are the only query parameters keying the responses of some endpoints. The
+// ingest stages send stays/detail and web/stays/details a stay 30 days out because the
+// endpoints require one, but the listing and photos they read do not depend on it; the
+// availability crawl and stay lookups, which need the prices of their dates, bypass the cache.
+var cacheKeyParams = map[string][]string{
+	staysDetailPath:    {"hotelId", "units"},
+	webStayDetailsPath: {"id"},
+}
+
+// CacheStats counts the lookups of one endpoint
+type CacheStats struct {
+	Hits      int `json:"hits"`
+	Misses    int `json:"misses"`
+	Expired   int `json:"expired"`
+	Stores    int `json:"stores"`
+	Evictions int `json:"evictions"`
+}
+
+// HitRate is the share of lookups served from the cache
+func (s CacheStats) HitRate() float64 {
+	if lookups := s.Hits + s.Misses + s.Expired; lookups > 0 {
+		return float64(s.Hits) / float64(lookups)
+	}
+	return 0
+}
+
+func (s *CacheStats) add(other CacheStats) {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Expired += other.Expired
+	s.Stores += other.Stores
+	s.Evictions += other.Evictions
+}
+
+// cacheEntry is one cached response on disk
+type cacheEntry struct {
+	URL      string    `json:"url"`
+	StoredAt time.Time `json:"stored_at"`
+	Body     []byte    `json:"body"`
+}
+
+// ResponseCache stores successful provider responses on disk under the SHA-256 of the
+// method and full URL, so the same request made by another run is answered without a call.
+// Entries expire per endpoint and the oldest are evicted once the cache outgrows MaxBytes.
+type ResponseCache struct {
+	Dir string
+	// MaxBytes caps the size of Dir; 0 means no cap
+	MaxBytes int64
+	// TTLs is the freshness of each endpoint path; paths without one are not cached
+	TTLs map[string]time.Duration
+
+	mu    sync.Mutex
+	size  int64
+	sized bool
+	stats map[string]*CacheStats
+	now   func() time.Time
+}
+
+// NewResponseCache returns a cache in dir with the default TTLs
+func NewResponseCache(dir string, maxBytes int64) *ResponseCache {
+	ttls := make(map[string]time.Duration, len(defaultCacheTTLs))
+	for path, ttl := range defaultCacheTTLs {
+		ttls[path] = ttl
+	}
+	return &ResponseCache{
+		Dir:      dir,
+		MaxBytes: maxBytes,
+		TTLs:     ttls,
+		stats:    map[string]*CacheStats{},
+		now:      time.Now,
+	}
+}
+
+var (
+	sharedCacheOnce sync.Once
+	sharedCache     *ResponseCache
+)
+
+// SharedResponseCache returns the cache configured under [cache], or nil when
+// cache::enabled is false
+func SharedResponseCache() *ResponseCache {
+	sharedCacheOnce.Do(func() {
+		if !beego.AppConfig.DefaultBool("cache::enabled", true) {
+			return
+		}
+		dir := beego.AppConfig.DefaultString("cache::dir", DefaultResponseCacheDir)
+		maxBytes := int64(beego.AppConfig.DefaultInt("cache::max_size_mb", 512)) << 20
+		cache := NewResponseCache(dir, maxBytes)
+		for path, key := range cacheTTLKeys {
+			raw := beego.AppConfig.DefaultString("cache::"+key, "")
+			if raw == "" {
+				continue
+			}
+			ttl, err := time.ParseDuration(raw)
+			if err != nil {
+				fmt.Printf("Warning: invalid cache::%s %q, keeping %v\n", key, raw, cache.TTLs[path])
+				continue
+			}
+			cache.TTLs[path] = ttl
+		}
+		sharedCache = cache
+	})
+	return sharedCache
+}
+
+// cacheable reports whether responses to req are cached at all
+func (c *ResponseCache) cacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet && c.TTLs[req.URL.Path] > 0
+}
+
+// cacheKey hashes the method and URL with its keying query parameters in sorted order;
+// headers, including the API key, are not part of it
+func cacheKey(req *http.Request) string {
+	u := *req.URL
+	query := u.Query()
+	if params, ok := cacheKeyParams[u.Path]; ok {
+		keyed := url.Values{}
+		for _, param := range params {
+			if values, ok := query[param]; ok {
+				keyed[param] = values
+			}
+		}
+		query = keyed
+	}
+	u.RawQuery = query.Encode()
+	sum := sha256.Sum256([]byte(req.Method + " " + u.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *ResponseCache) entryPath(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".json")
+}
+
+// Get returns the cached body of req if it is still fresh
+func (c *ResponseCache) Get(req *http.Request) ([]byte, bool) {
+	if !c.cacheable(req) {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.entryPath(cacheKey(req)))
+	var entry cacheEntry
+	if err == nil {
+		err = json.Unmarshal(data, &entry)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.endpointStats(req.URL.Path)
+	switch {
+	case err != nil:
+		stats.Misses++
+		return nil, false
+	case c.now().Sub(entry.StoredAt) > c.TTLs[req.URL.Path]:
+		stats.Expired++
+		return nil, false
+	}
+	stats.Hits++
+	return entry.Body, true
+}
+
+// Put stores body as the response to req, evicting the oldest entries when the cache grows
+// past MaxBytes
+func (c *ResponseCache) Put(req *http.Request, body []byte) error {
+	if !c.cacheable(req) {
+		return nil
+	}
+
+	storedAt := c.now()
+	data, err := json.Marshal(cacheEntry{URL: req.URL.String(), StoredAt: storedAt, Body: body})
+	if err != nil {
+		return fmt.Errorf("error encoding cache entry: %v", err)
+	}
+	path := c.entryPath(cacheKey(req))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.measure(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating cache directory: %v", err)
+	}
+	if info, err := os.Stat(path); err == nil {
+		c.size -= info.Size()
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing cache entry: %v", err)
+	}
+	// Eviction goes by modification time, so it follows the cache clock
+	os.Chtimes(path, storedAt, storedAt)
+	c.size += int64(len(data))
+	c.endpointStats(req.URL.Path).Stores++
+
+	if c.MaxBytes > 0 && c.size > c.MaxBytes {
+		return c.evict(req.URL.Path)
+	}
+	return nil
+}
+
+// measure sums the size of Dir once per process
+func (c *ResponseCache) measure() error {
+	if c.sized {
+		return nil
+	}
+	c.size = 0
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		c.size += info.Size()
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error measuring response cache: %v", err)
+	}
+	c.sized = true
+	return nil
+}
+
+// evict deletes the least recently stored entries until the cache is back under
+// MaxBytes; evictions are counted against the endpoint whose store triggered them
+func (c *ResponseCache) evict(endpoint string) error {
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, file{path, info.Size(), info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error scanning response cache: %v", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if c.size <= c.MaxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			return fmt.Errorf("error evicting cache entry: %v", err)
+		}
+		c.size -= f.size
+		c.endpointStats(endpoint).Evictions++
+	}
+	return nil
+}
+
+func (c *ResponseCache) endpointStats(path string) *CacheStats {
+	if c.stats == nil {
+		c.stats = map[string]*CacheStats{}
+	}
+	stats, ok := c.stats[path]
+	if !ok {
+		stats = &CacheStats{}
+		c.stats[path] = stats
+	}
+	return stats
+}
+
+// Stats returns the lookups of this process per endpoint path
+func (c *ResponseCache) Stats() map[string]CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make(map[string]CacheStats, len(c.stats))
+	for path, s := range c.stats {
+		stats[path] = *s
+	}
+	return stats
+}
+
+// Totals sums Stats over all endpoints
+func (c *ResponseCache) Totals() CacheStats {
+	var total CacheStats
+	for _, s := range c.Stats() {
+		total.add(s)
+	}
+	return total
+}
+
+// Size returns the bytes the cache takes on disk
+func (c *ResponseCache) Size() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.measure(); err != nil {
+		return 0, err
+	}
+	return c.size, nil
+}
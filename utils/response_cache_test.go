@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestResponseCache checks that ApiClient answers repeated requests from the cache and that
+// the TTLs, the size cap and the cache modes apply
+func TestResponseCache(t *testing.T) {
+	Convey("Subject: provider response cache\n", t, func() {
+		var calls int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if r.URL.Query().Get("hotelId") == "404" {
+				fmt.Fprint(w, `{"status":false,"message":"hotel not found","data":[]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"data":{"hotel_id":%s,"hotel_name":"call %d"}}`, r.URL.Query().Get("hotelId"), calls)
+		}))
+		defer srv.Close()
+
+		now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		cache := NewResponseCache(t.TempDir(), 0)
+		cache.now = func() time.Time { return now }
+		client := &ApiClient{
+			BaseURL:    srv.URL,
+			Headers:    map[string]string{"x-rapidapi-key": "one"},
+			HTTPClient: srv.Client(),
+			Cache:      cache,
+		}
+		details := func(hotelID int) string {
+			response, err := client.FetchPropertyDetails(context.Background(), hotelID, "2026-11-17", "2026-11-18")
+			So(err, ShouldBeNil)
+			return response.Data.HotelName
+		}
+
+		Convey("A repeated request is served from disk", func() {
+			first := details(1)
+			client.Headers["x-rapidapi-key"] = "two"
+			So(details(1), ShouldEqual, first)
+			So(calls, ShouldEqual, 1)
+			So(cache.Stats()[staysDetailPath], ShouldResemble, CacheStats{Hits: 1, Misses: 1, Stores: 1})
+		})
+
+		Convey("Details are keyed on the hotel, not on the stay dates", func() {
+			first := details(1)
+			response, err := client.FetchPropertyDetails(context.Background(), 1, "2026-11-18", "2026-11-19")
+			So(err, ShouldBeNil)
+			So(response.Data.HotelName, ShouldEqual, first)
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("A 200 response reporting status false is not cached", func() {
+			for i := 0; i < 2; i++ {
+				_, err := client.FetchPropertyDetails(context.Background(), 404, "2026-11-17", "2026-11-18")
+				So(err, ShouldBeNil)
+			}
+			So(calls, ShouldEqual, 2)
+			So(cache.Stats()[staysDetailPath].Stores, ShouldEqual, 0)
+		})
+
+		Convey("Entries older than the endpoint TTL are fetched again", func() {
+			details(1)
+			now = now.Add(cache.TTLs[staysDetailPath] + time.Minute)
+			details(1)
+			So(calls, ShouldEqual, 2)
+			So(cache.Stats()[staysDetailPath].Expired, ShouldEqual, 1)
+		})
+
+		Convey("Refresh skips the lookup but stores the new response", func() {
+			details(1)
+			client.SetCacheMode(CacheRefresh)
+			refreshed := details(1)
+			client.SetCacheMode(CacheUse)
+			So(details(1), ShouldEqual, refreshed)
+			So(calls, ShouldEqual, 2)
+		})
+
+		Convey("Off neither reads nor writes", func() {
+			client.SetCacheMode(CacheOff)
+			details(1)
+			details(1)
+			So(calls, ShouldEqual, 2)
+			So(cache.Totals(), ShouldResemble, CacheStats{})
+		})
+
+		Convey("The oldest entries are evicted past the size cap", func() {
+			details(1)
+			size, err := cache.Size()
+			So(err, ShouldBeNil)
+			cache.MaxBytes = 2*size + size/2
+
+			now = now.Add(time.Second)
+			details(2)
+			now = now.Add(time.Second)
+			details(3)
+			So(cache.Stats()[staysDetailPath].Evictions, ShouldEqual, 1)
+			details(3)
+			So(calls, ShouldEqual, 3)
+			details(1)
+			So(calls, ShouldEqual, 4)
+		})
+	})
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error kinds of provider calls; test with errors.Is
+var (
+	// ErrQuotaExhausted means the provider refuses calls until the quota resets
+	ErrQuotaExhausted = errors.New("provider quota exhausted")
+	// ErrNotFound means the provider has no such city or property
+	ErrNotFound = errors.New("not found at provider")
+	// ErrUpstream means the provider failed or rejected the request
+	ErrUpstream = errors.New("upstream error")
+)
+
+// RapidAPI quota headers
+const (
+	headerRequestsRemaining = "x-ratelimit-requests-remaining"
+	headerRequestsReset     = "x-ratelimit-requests-reset"
+)
+
+// APIError is a failed provider response. It unwraps to ErrQuotaExhausted, ErrNotFound or
+// ErrUpstream.
+type APIError struct {
+	Kind       error
+	StatusCode int
+	URL        string
+	// RetryAfter is how long the provider asked callers to wait, if it said
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%v: %s returned %d", e.Kind, e.URL, e.StatusCode)
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(" (retry after %v)", e.RetryAfter)
+	}
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// RetryPolicy controls how RetryTransport retries failed calls
+type RetryPolicy struct {
+	// MaxAttempts counts the first call; 1 disables retries
+	MaxAttempts int
+	// BaseDelay doubles after every attempt up to MaxDelay; the actual wait is jittered
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxRetryAfter is the longest Retry-After or quota reset worth waiting for; longer
+	// waits fail the call straight away
+	MaxRetryAfter time.Duration
+	// AttemptTimeout bounds each attempt, including reading its body; waits between attempts
+	// do not count, so an honored Retry-After never runs into it. 0 disables it.
+	AttemptTimeout time.Duration
+}
+
+// DefaultRetryPolicy retries a call up to three times over roughly half a minute, giving
+// each attempt a minute
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	BaseDelay:      time.Second,
+	MaxDelay:       30 * time.Second,
+	MaxRetryAfter:  2 * time.Minute,
+	AttemptTimeout: time.Minute,
+}
+
+// RetryTransport retries GET requests that failed with a network error, 408, 429 or a 5xx
+// status, waiting for Retry-After or the RapidAPI quota reset when the provider sends one
+// and for exponential backoff with jitter otherwise. Other responses are returned as they are.
+type RetryTransport struct {
+	Base   http.RoundTripper
+	Policy RetryPolicy
+	// Observe, when set, sees every response including the retried ones
+	Observe func(resp *http.Response)
+	// sleep is replaced in tests
+	sleep func(req *http.Request, d time.Duration) error
+}
+
+// NewRetryTransport wraps base, or http.DefaultTransport when base is nil
+func NewRetryTransport(base http.RoundTripper, policy RetryPolicy) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RetryTransport{Base: base, Policy: policy}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := t.Policy.MaxAttempts
+	if attempts < 1 || req.Body != nil {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.roundTrip(req)
+		if err == nil && t.Observe != nil {
+			t.Observe(resp)
+		}
+		if attempt >= attempts || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			wait = t.backoff(attempt)
+		case retryableStatus(resp.StatusCode):
+			wait = retryWait(resp)
+			if wait > t.Policy.MaxRetryAfter {
+				return resp, nil
+			}
+			if wait == 0 {
+				wait = t.backoff(attempt)
+			}
+			// Drain so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		fmt.Printf("Retrying %s in %v (attempt %d/%d): %s\n", req.URL.Path, wait.Round(time.Millisecond), attempt+1, attempts, failure(resp, err))
+		if err := t.wait(req, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// roundTrip makes one attempt under Policy.AttemptTimeout; the deadline is released when
+// the body is closed
+func (t *RetryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if t.Policy.AttemptTimeout <= 0 {
+		return t.Base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.Policy.AttemptTimeout)
+	resp, err := t.Base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the attempt deadline of a response body
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func (t *RetryTransport) wait(req *http.Request, d time.Duration) error {
+	if t.sleep != nil {
+		return t.sleep(req, d)
+	}
+	return SleepContext(req.Context(), d)
+}
+
+// backoff returns BaseDelay * 2^(attempt-1), capped at MaxDelay, with jitter over its upper half
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	d := t.Policy.BaseDelay << (attempt - 1)
+	if d <= 0 || (t.Policy.MaxDelay > 0 && d > t.Policy.MaxDelay) {
+		d = t.Policy.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func failure(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryWait is how long the response asks callers to wait: Retry-After, or the quota reset
+// when the RapidAPI quota is used up. It is 0 when the response says neither.
+func retryWait(resp *http.Response) time.Duration {
+	if wait := parseRetryAfter(resp.Header.Get("Retry-After")); wait > 0 {
+		return wait
+	}
+	if quotaExhausted(resp) {
+		return parseSeconds(resp.Header.Get(headerRequestsReset))
+	}
+	return 0
+}
+
+// quotaExhausted reports whether the RapidAPI quota headers say no requests are left
+func quotaExhausted(resp *http.Response) bool {
+	return strings.TrimSpace(resp.Header.Get(headerRequestsRemaining)) == "0"
+}
+
+// parseRetryAfter reads Retry-After as seconds or an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if d := parseSeconds(value); d > 0 {
+		return d
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// checkResponse turns a non-2xx response into an *APIError
+func checkResponse(resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	apiErr := &APIError{
+		Kind:       ErrUpstream,
+		StatusCode: resp.StatusCode,
+		URL:        resp.Request.URL.Path,
+		RetryAfter: retryWait(resp),
+		Body:       truncate(strings.TrimSpace(string(body)), 200),
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		apiErr.Kind = ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests && quotaExhausted(resp):
+		apiErr.Kind = ErrQuotaExhausted
+	}
+	return apiErr
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestRetryTransport checks status classification, Retry-After handling and the typed errors
+func TestRetryTransport(t *testing.T) {
+	Convey("Subject: ApiClient retrying transport\n", t, func() {
+		var calls int
+		var waits []time.Duration
+		respond := func(w http.ResponseWriter, r *http.Request, calls int) {}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			respond(w, r, calls)
+		}))
+		defer srv.Close()
+
+		transport := NewRetryTransport(nil, RetryPolicy{
+			MaxAttempts:   3,
+			BaseDelay:     time.Second,
+			MaxDelay:      4 * time.Second,
+			MaxRetryAfter: time.Minute,
+		})
+		transport.sleep = func(req *http.Request, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		}
+		client := &ApiClient{BaseURL: srv.URL, HTTPClient: &http.Client{Transport: transport}}
+
+		fetch := func() ([]byte, error) {
+			req, err := client.newRequest("/stays/detail", nil)
+			So(err, ShouldBeNil)
+			return client.get(req)
+		}
+
+		Convey("5xx responses are retried with jittered exponential backoff", func() {
+			respond = func(w http.ResponseWriter, r *http.Request, calls int) {
+				if calls < 3 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.Write([]byte(`{"data":{}}`))
+			}
+			body, err := fetch()
+			So(err, ShouldBeNil)
+			So(string(body), ShouldEqual, `{"data":{}}`)
+			So(calls, ShouldEqual, 3)
+			So(len(waits), ShouldEqual, 2)
+			So(waits[0], ShouldBeBetweenOrEqual, 500*time.Millisecond, time.Second)
+			So(waits[1], ShouldBeBetweenOrEqual, time.Second, 2*time.Second)
+		})
+
+		Convey("A hung attempt times out on its own and the call is retried", func() {
+			transport.Policy.AttemptTimeout = 50 * time.Millisecond
+			respond = func(w http.ResponseWriter, r *http.Request, calls int) {
+				if calls == 1 {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(5 * time.Second):
+					}
+					return
+				}
+				w.Write([]byte(`{}`))
+			}
+			body, err := fetch()
+			So(err, ShouldBeNil)
+			So(string(body), ShouldEqual, `{}`)
+			So(calls, ShouldEqual, 2)
+			So(len(waits), ShouldEqual, 1)
+		})
+
+		Convey("Retry-After is honored", func() {
+			respond = func(w http.ResponseWriter, r *http.Request, calls int) {
+				if calls == 1 {
+					w.Header().Set("Retry-After", "7")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.Write([]byte(`{}`))
+			}
+			_, err := fetch()
+			So(err, ShouldBeNil)
+			So(waits, ShouldResemble, []time.Duration{7 * time.Second})
+		})
+
+		Convey("An exhausted RapidAPI quota fails without waiting for a distant reset", func() {
+			respond = func(w http.ResponseWriter, r *http.Request, calls int) {
+				w.Header().Set("x-ratelimit-requests-remaining", "0")
+				w.Header().Set("x-ratelimit-requests-reset", "86400")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+			_, err := fetch()
+			So(errors.Is(err, ErrQuotaExhausted), ShouldBeTrue)
+			So(calls, ShouldEqual, 1)
+
+			var apiErr *APIError
+			So(errors.As(err, &apiErr), ShouldBeTrue)
+			So(apiErr.RetryAfter, ShouldEqual, 24*time.Hour)
+		})
+
+		Convey("404 is not retried and reports ErrNotFound", func() {
+			respond = func(w http.ResponseWriter, r *http.Request, calls int) {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			_, err := fetch()
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("Persistent 5xx ends as ErrUpstream after the last attempt", func() {
+			respond = func(w http.ResponseWriter, r *http.Request, calls int) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			_, err := fetch()
+			So(errors.Is(err, ErrUpstream), ShouldBeTrue)
+			So(calls, ShouldEqual, 3)
+		})
+	})
+}
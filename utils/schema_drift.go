@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"backend_rental/models"
+)
+
+// DecodeChecked unmarshals body into out and reports where body drifted from the type of
+// out. Values of the wrong JSON type are reported and left zero instead of failing the
+// decode; only malformed JSON is an error.
+func DecodeChecked(body []byte, out interface{}) (models.SchemaDrift, error) {
+	var drift models.SchemaDrift
+
+	var raw interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return drift, fmt.Errorf("invalid JSON: %v", err)
+	}
+	checker := driftChecker{seen: map[string]bool{}}
+	checker.walk("", raw, reflect.TypeOf(out))
+	drift = checker.drift
+	sort.Strings(drift.Unknown)
+	sort.Strings(drift.Missing)
+	sort.Strings(drift.Mismatched)
+
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(body, out); err != nil && !errors.As(err, &typeErr) {
+		return drift, err
+	}
+	return drift, nil
+}
+
+type driftChecker struct {
+	drift models.SchemaDrift
+	// seen dedupes the paths of array elements
+	seen map[string]bool
+}
+
+func (c *driftChecker) add(kind string, list *[]string, path string) {
+	if c.seen[kind+" "+path] {
+		return
+	}
+	c.seen[kind+" "+path] = true
+	*list = append(*list, path)
+}
+
+func (c *driftChecker) walk(path string, value interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if value == nil {
+		return
+	}
+
+	// The API sends [] for an empty object
+	if items, ok := value.([]interface{}); ok && len(items) == 0 && (t.Kind() == reflect.Struct || t.Kind() == reflect.Map) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			c.add("mismatched", &c.drift.Mismatched, path)
+			return
+		}
+		known := map[string]bool{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := jsonFieldName(field)
+			if name == "" {
+				continue
+			}
+			known[name] = true
+			fieldValue, present := object[name]
+			if fieldValue == nil && field.Tag.Get("schema") == "required" {
+				c.add("missing", &c.drift.Missing, joinPath(path, name))
+			}
+			if present {
+				c.walk(joinPath(path, name), fieldValue, field.Type)
+			}
+		}
+		for name := range object {
+			if !known[name] {
+				c.add("unknown", &c.drift.Unknown, joinPath(path, name))
+			}
+		}
+
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			c.add("mismatched", &c.drift.Mismatched, path)
+			return
+		}
+		for _, item := range items {
+			c.walk(path+"[]", item, t.Elem())
+		}
+
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			c.add("mismatched", &c.drift.Mismatched, path)
+			return
+		}
+		for _, item := range object {
+			c.walk(path+".*", item, t.Elem())
+		}
+
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			c.add("mismatched", &c.drift.Mismatched, path)
+		}
+
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			c.add("mismatched", &c.drift.Mismatched, path)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			c.add("mismatched", &c.drift.Mismatched, path)
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(float64); !ok {
+			c.add("mismatched", &c.drift.Mismatched, path)
+		}
+	}
+}
+
+// jsonFieldName returns the JSON key of an exported field, or "" when it is not encoded
+func jsonFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// DriftSummary counts the responses in which each drifted path appeared over a run
+type DriftSummary struct {
+	Responses  int
+	Drifted    int
+	Unknown    map[string]int
+	Missing    map[string]int
+	Mismatched map[string]int
+}
+
+// NewDriftSummary returns an empty summary
+func NewDriftSummary() *DriftSummary {
+	return &DriftSummary{Unknown: map[string]int{}, Missing: map[string]int{}, Mismatched: map[string]int{}}
+}
+
+// Add counts one response
+func (s *DriftSummary) Add(drift models.SchemaDrift) {
+	s.Responses++
+	if drift.Empty() {
+		return
+	}
+	s.Drifted++
+	for _, path := range drift.Unknown {
+		s.Unknown[path]++
+	}
+	for _, path := range drift.Missing {
+		s.Missing[path]++
+	}
+	for _, path := range drift.Mismatched {
+		s.Mismatched[path]++
+	}
+}
+
+// Report prints the summary under title, or nothing when no response drifted
+func (s *DriftSummary) Report(title string) {
+	if s.Drifted == 0 {
+		return
+	}
+	fmt.Printf("%s schema drift in %d of %d responses\n", title, s.Drifted, s.Responses)
+	for _, group := range []struct {
+		label string
+		paths map[string]int
+	}{{"missing", s.Missing}, {"mismatched", s.Mismatched}, {"unknown", s.Unknown}} {
+		paths := make([]string, 0, len(group.paths))
+		for path := range group.paths {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			fmt.Printf("  %-10s %s (%d)\n", group.label, path, group.paths[path])
+		}
+	}
+}
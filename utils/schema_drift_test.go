@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"testing"
+
+	"backend_rental/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestDecodeChecked checks that stays/detail drift is reported without losing the fields
+// that still decode
+func TestDecodeChecked(t *testing.T) {
+	Convey("Subject: stays/detail schema drift\n", t, func() {
+		Convey("A payload matching the types decodes without drift", func() {
+			var response models.StayDetailResponse
+			drift, err := DecodeChecked([]byte(`{"status":true,"data":{
+				"hotel_id":26263,"hotel_name":"Canal House","url":"https://www.booking.com/hotel/nl/canal.html",
+				"accommodation_type_name":"Hotels","latitude":52.37,"longitude":4.88,"class":4,
+				"checkin":{"from":"15:00","until":"23:00"},"checkout":{"from":"","until":"11:00"},
+				"facilities_block":{"facilities":[{"name":"Free Wifi"}]},
+				"hotel_important_information_with_codes":[{"phrase":"Pets are not allowed.","sentence_id":1}]}}`), &response)
+			So(err, ShouldBeNil)
+			So(drift.Empty(), ShouldBeTrue)
+			So(response.Data.Checkin.From, ShouldEqual, "15:00")
+			So(response.Data.Class, ShouldEqual, 4)
+			So(response.Data.ImportantInformation[0].Phrase, ShouldEqual, "Pets are not allowed.")
+		})
+
+		Convey("Unknown, missing and mismatched fields are reported and the rest still decodes", func() {
+			var response models.StayDetailResponse
+			drift, err := DecodeChecked([]byte(`{"data":{
+				"hotel_id":26263,"hotel_name":"Canal House","accommodation_type_name":"Hotels",
+				"class":"4 stars","review_nr":812,"address":"Herengracht 1",
+				"facilities":[{"name":"Free Wifi","facilitytype_id":47},{"name":"Bar","facilitytype_id":3}]}}`), &response)
+			So(err, ShouldBeNil)
+			So(drift.Missing, ShouldResemble, []string{"data.url"})
+			So(drift.Mismatched, ShouldResemble, []string{"data.class"})
+			So(drift.Unknown, ShouldResemble, []string{"data.facilities[].facilitytype_id", "data.review_nr"})
+			So(drift.Breaking(), ShouldBeTrue)
+			So(response.Data.Address, ShouldEqual, "Herengracht 1")
+			So(len(response.Data.Facilities), ShouldEqual, 2)
+		})
+
+		Convey("Malformed JSON is an error", func() {
+			var response models.StayDetailResponse
+			_, err := DecodeChecked([]byte(`{"data":`), &response)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,588 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"backend_rental/models"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/config"
+)
+
+// Seed modes selectable with db::seed_mode in app.conf
+const (
+	// SeedModeIfEmpty loads a table from its JSON file only while the table has no rows
+	SeedModeIfEmpty = "seed-if-empty"
+	// SeedModeUpsert inserts new properties and updates changed ones, keyed on property_id
+	SeedModeUpsert = "upsert"
+	// SeedModeReplace deletes every row and reloads the JSON file
+	SeedModeReplace = "replace"
+)
+
+// Seed files loaded into the database
+const (
+	RentalPropertySeedPath  = "data/RentalProperty.json"
+	PropertyDetailsSeedPath = "data/PropertyDetails.json"
+	PropertyPhotosSeedPath  = "data/property_images.json"
+	PropertyUnitsSeedPath   = "data/property_details.json"
+	PropertyPricesSeedPath  = "data/properties.json"
+)
+
+// SeedStats counts what a seeding run did to one table
+type SeedStats struct {
+	Table     string `json:"table"`
+	Mode      string `json:"mode"`
+	Inserted  int    `json:"inserted"`
+	Updated   int    `json:"updated"`
+	Unchanged int    `json:"unchanged"`
+	Deleted   int    `json:"deleted"`
+	Skipped   bool   `json:"skipped"`
+}
+
+func (s SeedStats) String() string {
+	if s.Skipped {
+		return fmt.Sprintf("%s (%s): skipped", s.Table, s.Mode)
+	}
+	return fmt.Sprintf("%s (%s): %d inserted, %d updated, %d unchanged, %d deleted",
+		s.Table, s.Mode, s.Inserted, s.Updated, s.Unchanged, s.Deleted)
+}
+
+// SeedModeFromConfig returns db::seed_mode, defaulting to seed-if-empty
+func SeedModeFromConfig() (string, error) {
+	mode := config.DefaultString("db::seed_mode", SeedModeIfEmpty)
+	switch mode {
+	case SeedModeIfEmpty, SeedModeUpsert, SeedModeReplace:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown db::seed_mode %q; use %s, %s or %s", mode, SeedModeIfEmpty, SeedModeUpsert, SeedModeReplace)
+}
+
+// SeedDatabase loads rental_property, property_details, property_photo, property_unit,
+// property_price and currency_rate from their files
+func SeedDatabase(mode string) ([]SeedStats, error) {
+	var all []SeedStats
+
+	stats, err := SeedRentalProperties(mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rental property data: %v", err)
+	}
+	all = append(all, *stats)
+
+	stats, err = SeedPropertyDetails(mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load property details: %v", err)
+	}
+	all = append(all, *stats)
+
+	stats, err = SeedPropertyPhotos(mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load property photos: %v", err)
+	}
+	all = append(all, *stats)
+
+	stats, err = SeedPropertyUnits(mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load property units: %v", err)
+	}
+	all = append(all, *stats)
+
+	stats, err = SeedPropertyPrices(mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load property prices: %v", err)
+	}
+	all = append(all, *stats)
+
+	stats, err = SeedCurrencyRates(mode, CurrencyRatesPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load currency rates: %v", err)
+	}
+	all = append(all, *stats)
+
+	for _, stats := range all {
+		fmt.Printf("Seeded %s\n", stats)
+	}
+	return all, nil
+}
+
+// seedTx runs seed in a transaction after applying the mode: seed-if-empty skips a
+// non-empty table and replace clears it first
+func seedTx(table, mode string, seed func(tx *sql.Tx, stats *SeedStats) error) (*SeedStats, error) {
+	stats := &SeedStats{Table: table, Mode: mode}
+
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	switch mode {
+	case SeedModeIfEmpty:
+		var count int
+		if err := tx.QueryRow(fmt.Sprintf("SELECT count(*) FROM %s", table)).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %v", table, err)
+		}
+		if count > 0 {
+			stats.Skipped = true
+			return stats, nil
+		}
+	case SeedModeReplace:
+		result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to clear existing data: %v", err)
+		}
+		deleted, _ := result.RowsAffected()
+		stats.Deleted = int(deleted)
+	}
+
+	if err := seed(tx, stats); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit %s: %v", table, err)
+	}
+	return stats, nil
+}
+
+// upsertRentalPropertySQL inserts a listing or updates it when a column differs. No row is
+// returned for an unchanged listing; xmax = 0 tells a fresh insert from an update.
+const upsertRentalPropertySQL = `
+	INSERT INTO rental_property (city_id, property_id, name, property_type, bedrooms, bathrooms, max_occupancy)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (property_id) DO UPDATE SET
+		city_id = EXCLUDED.city_id,
+		name = EXCLUDED.name,
+		property_type = EXCLUDED.property_type,
+		bedrooms = EXCLUDED.bedrooms,
+		bathrooms = EXCLUDED.bathrooms,
+		max_occupancy = EXCLUDED.max_occupancy
+	WHERE (rental_property.city_id, rental_property.name, rental_property.property_type,
+		rental_property.bedrooms, rental_property.bathrooms, rental_property.max_occupancy)
+		IS DISTINCT FROM
+		(EXCLUDED.city_id, EXCLUDED.name, EXCLUDED.property_type, EXCLUDED.bedrooms, EXCLUDED.bathrooms,
+		EXCLUDED.max_occupancy)
+	RETURNING (xmax = 0)`
+
+// SeedRentalProperties loads data/RentalProperty.json and the amenities of each listing
+func SeedRentalProperties(mode string) (*SeedStats, error) {
+	var properties []models.RentalProperty
+	if err := readSeedFile(RentalPropertySeedPath, &properties); err != nil {
+		if os.IsNotExist(err) {
+			// If file doesn't exist, it's not an error - just skip loading
+			fmt.Println("RentalProperty.json not found. Skipping data loading.")
+			return &SeedStats{Table: "rental_property", Mode: mode, Skipped: true}, nil
+		}
+		return nil, err
+	}
+	fmt.Printf("Loaded %d properties from JSON\n", len(properties))
+
+	return seedTx("rental_property", mode, func(tx *sql.Tx, stats *SeedStats) error {
+		if mode == SeedModeReplace {
+			if _, err := tx.Exec("DELETE FROM property_amenity"); err != nil {
+				return fmt.Errorf("failed to clear existing amenity links: %v", err)
+			}
+		}
+
+		for _, prop := range properties {
+			var inserted bool
+			err := tx.QueryRow(upsertRentalPropertySQL,
+				prop.CityID, prop.PropertyID, prop.Name, prop.PropertyType, prop.Bedrooms, prop.Bathrooms,
+				prop.MaxOccupancy,
+			).Scan(&inserted)
+			changed := err == nil
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to upsert property %v: %v", prop.PropertyID, err)
+			}
+
+			amenitiesChanged, err := amenitiesDiffer(tx, prop.PropertyID, prop.Amenities)
+			if err != nil {
+				return err
+			}
+			if amenitiesChanged {
+				if err := SavePropertyAmenities(tx, prop.PropertyID, prop.Amenities); err != nil {
+					return err
+				}
+			}
+
+			switch {
+			case changed && inserted:
+				stats.Inserted++
+			case changed || amenitiesChanged:
+				stats.Updated++
+			default:
+				stats.Unchanged++
+			}
+		}
+		return nil
+	})
+}
+
+// amenitiesDiffer reports whether the stored amenity slugs of a property differ from amenities
+func amenitiesDiffer(tx *sql.Tx, propertyID int64, amenities []models.Amenity) (bool, error) {
+	var stored string
+	err := tx.QueryRow(`
+		SELECT coalesce(string_agg(a.slug, ',' ORDER BY a.slug), '')
+		FROM property_amenity pa JOIN amenity a ON a.id = pa.amenity_id
+		WHERE pa.property_id = $1`, propertyID).Scan(&stored)
+	if err != nil {
+		return false, fmt.Errorf("failed to read amenities of property %d: %v", propertyID, err)
+	}
+
+	slugs := make([]string, len(amenities))
+	for i, amenity := range amenities {
+		slugs[i] = amenity.Slug
+	}
+	sort.Strings(slugs)
+	return stored != strings.Join(slugs, ","), nil
+}
+
+const upsertPropertyDetailsSQL = `
+	INSERT INTO property_details
+		(property_id, description, review_score, review_count, review_score_word, image_type, image_urls)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (property_id) DO UPDATE SET
+		description = EXCLUDED.description,
+		review_score = EXCLUDED.review_score,
+		review_count = EXCLUDED.review_count,
+		review_score_word = EXCLUDED.review_score_word,
+		image_type = EXCLUDED.image_type,
+		image_urls = EXCLUDED.image_urls
+	WHERE (property_details.description, property_details.review_score, property_details.review_count,
+		property_details.review_score_word, property_details.image_type, property_details.image_urls)
+		IS DISTINCT FROM
+		(EXCLUDED.description, EXCLUDED.review_score, EXCLUDED.review_count,
+		EXCLUDED.review_score_word, EXCLUDED.image_type, EXCLUDED.image_urls)
+	RETURNING (xmax = 0)`
+
+// SeedPropertyDetails loads data/PropertyDetails.json
+func SeedPropertyDetails(mode string) (*SeedStats, error) {
+	var propertyDetails []models.PropertyDetails
+	if err := readSeedFile(PropertyDetailsSeedPath, &propertyDetails); err != nil {
+		return nil, fmt.Errorf("failed to read PropertyDetails.json: %v", err)
+	}
+	fmt.Printf("Loaded %d property details from JSON\n", len(propertyDetails))
+
+	return seedTx("property_details", mode, func(tx *sql.Tx, stats *SeedStats) error {
+		for _, detail := range propertyDetails {
+			// Convert ImageUrls to JSON string
+			imageUrlsJSON, err := json.Marshal(detail.ImageUrls)
+			if err != nil {
+				return fmt.Errorf("failed to marshal image URLs: %v", err)
+			}
+
+			var inserted bool
+			err = tx.QueryRow(upsertPropertyDetailsSQL,
+				detail.PropertyID,
+				detail.Description,
+				detail.ReviewScore,
+				detail.ReviewCount,
+				detail.ReviewScoreWord,
+				detail.ImageType,
+				string(imageUrlsJSON),
+			).Scan(&inserted)
+			switch {
+			case err == sql.ErrNoRows:
+				stats.Unchanged++
+			case err != nil:
+				return fmt.Errorf("failed to upsert property detail %v: %v", detail.PropertyID, err)
+			case inserted:
+				stats.Inserted++
+			default:
+				stats.Updated++
+			}
+		}
+		return nil
+	})
+}
+
+// SeedPropertyPhotos loads the photos of data/property_images.json into property_photo. In
+// upsert mode the photos of each property in the file replace the stored ones when they differ.
+func SeedPropertyPhotos(mode string) (*SeedStats, error) {
+	var images []models.PropertyImage
+	if err := readSeedFile(PropertyPhotosSeedPath, &images); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("property_images.json not found. Skipping photo loading.")
+			return &SeedStats{Table: "property_photo", Mode: mode, Skipped: true}, nil
+		}
+		return nil, err
+	}
+
+	photos := map[int64][]models.PropertyPhoto{}
+	var propertyIDs []int64
+	for _, image := range images {
+		propertyID := int64(image.PropertyID)
+		if _, ok := photos[propertyID]; !ok {
+			propertyIDs = append(propertyIDs, propertyID)
+		}
+		photos[propertyID] = append(photos[propertyID], photosOf(image)...)
+	}
+	fmt.Printf("Loaded photos of %d properties from JSON\n", len(propertyIDs))
+
+	return seedTx("property_photo", mode, func(tx *sql.Tx, stats *SeedStats) error {
+		for _, propertyID := range propertyIDs {
+			stored, err := storedPhotos(tx, propertyID)
+			if err != nil {
+				return err
+			}
+			fresh := photos[propertyID]
+			if photosEqual(stored, fresh) {
+				stats.Unchanged++
+				continue
+			}
+
+			if _, err := tx.Exec("DELETE FROM property_photo WHERE property_id = $1", propertyID); err != nil {
+				return fmt.Errorf("failed to clear photos of property %d: %v", propertyID, err)
+			}
+			for _, photo := range fresh {
+				_, err := tx.Exec(`
+					INSERT INTO property_photo
+						(property_id, photo_id, kind, room_id, room_name, caption, large_url, thumb_url, position)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+					propertyID, photo.PhotoID, photo.Kind, photo.RoomID, photo.RoomName,
+					photo.Caption, photo.LargeURL, photo.ThumbURL, photo.Position)
+				if err != nil {
+					return fmt.Errorf("failed to insert photo of property %d: %v", propertyID, err)
+				}
+			}
+			if len(stored) == 0 {
+				stats.Inserted++
+			} else {
+				stats.Updated++
+			}
+		}
+		return nil
+	})
+}
+
+// SeedPropertyUnits loads the units of data/property_details.json into property_unit. As with
+// photos, the units of each property in the file replace the stored ones when they differ;
+// details fetched before units were parsed leave the stored units alone.
+func SeedPropertyUnits(mode string) (*SeedStats, error) {
+	var details []models.PropertyDetail
+	if err := readSeedFile(PropertyUnitsSeedPath, &details); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("property_details.json not found. Skipping unit loading.")
+			return &SeedStats{Table: "property_unit", Mode: mode, Skipped: true}, nil
+		}
+		return nil, err
+	}
+
+	units := map[int64][]models.PropertyUnit{}
+	var propertyIDs []int64
+	for _, detail := range details {
+		if len(detail.Units) == 0 {
+			continue
+		}
+		propertyID := int64(detail.HotelID)
+		if _, ok := units[propertyID]; !ok {
+			propertyIDs = append(propertyIDs, propertyID)
+		}
+		units[propertyID] = detail.Units
+	}
+	fmt.Printf("Loaded units of %d properties from JSON\n", len(propertyIDs))
+
+	return seedTx("property_unit", mode, func(tx *sql.Tx, stats *SeedStats) error {
+		for _, propertyID := range propertyIDs {
+			stored, err := storedUnits(tx, propertyID)
+			if err != nil {
+				return err
+			}
+			fresh := units[propertyID]
+			if unitsEqual(stored, fresh) {
+				stats.Unchanged++
+				continue
+			}
+
+			if _, err := tx.Exec("DELETE FROM property_unit WHERE property_id = $1", propertyID); err != nil {
+				return fmt.Errorf("failed to clear units of property %d: %v", propertyID, err)
+			}
+			for i, unit := range fresh {
+				_, err := tx.Exec(`
+					INSERT INTO property_unit
+						(property_id, room_id, name, bedrooms, bathrooms, bathroom_type,
+						bed_configuration, max_occupancy, size_m2, position)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+					propertyID, unit.RoomID, unit.Name, unit.Bedrooms, unit.Bathrooms, unit.BathroomType,
+					unit.BedConfiguration, unit.MaxOccupancy, unit.SizeM2, i)
+				if err != nil {
+					return fmt.Errorf("failed to insert unit of property %d: %v", propertyID, err)
+				}
+			}
+			if len(stored) == 0 {
+				stats.Inserted++
+			} else {
+				stats.Updated++
+			}
+		}
+		return nil
+	})
+}
+
+func storedUnits(tx *sql.Tx, propertyID int64) ([]models.PropertyUnit, error) {
+	rows, err := tx.Query(`
+		SELECT room_id, name, bedrooms, bathrooms, bathroom_type, bed_configuration, max_occupancy, size_m2
+		FROM property_unit WHERE property_id = $1 ORDER BY position`, propertyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read units of property %d: %v", propertyID, err)
+	}
+	defer rows.Close()
+
+	var units []models.PropertyUnit
+	for rows.Next() {
+		unit := models.PropertyUnit{PropertyID: propertyID}
+		err := rows.Scan(&unit.RoomID, &unit.Name, &unit.Bedrooms, &unit.Bathrooms, &unit.BathroomType,
+			&unit.BedConfiguration, &unit.MaxOccupancy, &unit.SizeM2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read unit of property %d: %v", propertyID, err)
+		}
+		unit.Position = len(units)
+		units = append(units, unit)
+	}
+	return units, rows.Err()
+}
+
+func unitsEqual(a, b []models.PropertyUnit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		x.ID, y.ID = 0, 0
+		x.PropertyID, y.PropertyID = 0, 0
+		x.Position, y.Position = i, i
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+// SeedPropertyPrices appends the prices quoted in data/properties.json to property_price.
+// Prices are a history, so every mode upserts: quotes already stored are left alone and
+// nothing is cleared.
+func SeedPropertyPrices(mode string) (*SeedStats, error) {
+	var properties []models.Property
+	if err := readSeedFile(PropertyPricesSeedPath, &properties); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("properties.json not found. Skipping price loading.")
+			return &SeedStats{Table: "property_price", Mode: mode, Skipped: true}, nil
+		}
+		return nil, err
+	}
+	prices := QuotedPrices(properties)
+	fmt.Printf("Loaded %d prices from JSON\n", len(prices))
+
+	return seedTx("property_price", SeedModeUpsert, func(tx *sql.Tx, stats *SeedStats) error {
+		inserted, err := SavePropertyPrices(tx, prices)
+		if err != nil {
+			return err
+		}
+		stats.Inserted = inserted
+		stats.Unchanged = len(prices) - inserted
+		return nil
+	})
+}
+
+// SeedCurrencyRates loads the rates file at path, .json or .csv, into currency_rate
+func SeedCurrencyRates(mode, path string) (*SeedStats, error) {
+	rates, err := ReadCurrencyRatesFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("%s not found. Skipping currency rate loading.\n", path)
+			return &SeedStats{Table: "currency_rate", Mode: mode, Skipped: true}, nil
+		}
+		return nil, err
+	}
+	fmt.Printf("Loaded %d currency rates from %s\n", len(rates), path)
+
+	stats, err := seedTx("currency_rate", mode, func(tx *sql.Tx, stats *SeedStats) error {
+		return SaveCurrencyRates(tx, rates, stats)
+	})
+	InvalidateCurrencyRates()
+	return stats, err
+}
+
+// photosOf returns the photos of an image set; sets recorded before photo metadata was
+// kept only have thumbnail URLs
+func photosOf(image models.PropertyImage) []models.PropertyPhoto {
+	kind := models.PhotoKindHotel
+	if image.ImageType == "room_photos" {
+		kind = models.PhotoKindRoom
+	}
+	if len(image.Photos) > 0 {
+		photos := make([]models.PropertyPhoto, len(image.Photos))
+		for i, photo := range image.Photos {
+			photo.ID = 0
+			photo.PropertyID = int64(image.PropertyID)
+			photo.Kind = kind
+			photos[i] = photo
+		}
+		return photos
+	}
+
+	photos := make([]models.PropertyPhoto, len(image.ImageURLs))
+	for i, imageURL := range image.ImageURLs {
+		photos[i] = models.PropertyPhoto{
+			PropertyID: int64(image.PropertyID),
+			Kind:       kind,
+			ThumbURL:   imageURL,
+			Position:   i,
+		}
+	}
+	return photos
+}
+
+func storedPhotos(tx *sql.Tx, propertyID int64) ([]models.PropertyPhoto, error) {
+	rows, err := tx.Query(`
+		SELECT photo_id, kind, room_id, room_name, caption, large_url, thumb_url, position
+		FROM property_photo WHERE property_id = $1 ORDER BY id`, propertyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read photos of property %d: %v", propertyID, err)
+	}
+	defer rows.Close()
+
+	var photos []models.PropertyPhoto
+	for rows.Next() {
+		photo := models.PropertyPhoto{PropertyID: propertyID}
+		err := rows.Scan(&photo.PhotoID, &photo.Kind, &photo.RoomID, &photo.RoomName,
+			&photo.Caption, &photo.LargeURL, &photo.ThumbURL, &photo.Position)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read photo of property %d: %v", propertyID, err)
+		}
+		photos = append(photos, photo)
+	}
+	return photos, rows.Err()
+}
+
+func photosEqual(a, b []models.PropertyPhoto) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		x.ID, y.ID = 0, 0
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+func readSeedFile(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return nil
+}